@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// TestCycle runs the 16x16 and 64x64 boards for far more turns than could be computed,
+// skipping the cycles they settle into, and checks the number of cells alive at the end
+// against the CSVs in check/alive, whose last turn is at the same point of the cycle.
+func TestCycle(t *testing.T) {
+	for _, size := range []int{16, 64} {
+		p := gol.Params{
+			Turns:       10000000000,
+			Threads:     8,
+			ImageWidth:  size,
+			ImageHeight: size,
+			SkipCycles:  true,
+		}
+		alive := readAliveCounts(p.ImageWidth, p.ImageHeight)
+		events := make(chan gol.Event)
+		gol.Run(p, events, nil)
+
+		var cycle *gol.CycleDetected
+		var final gol.FinalTurnComplete
+		for event := range events {
+			switch e := event.(type) {
+			case gol.CycleDetected:
+				cycle = &e
+			case gol.FinalTurnComplete:
+				final = e
+			}
+		}
+
+		if cycle == nil {
+			t.Errorf("%vx%v: no cycle was detected", size, size)
+			continue
+		}
+		//The CSVs start after turn 0
+		if before, ok := alive[cycle.CompletedTurns-cycle.Period]; ok && alive[cycle.CompletedTurns] != before {
+			t.Errorf("%vx%v: cycle of period %v on turn %v, but %v cells were alive then and %v a period before",
+				size, size, cycle.Period, cycle.CompletedTurns, alive[cycle.CompletedTurns], before)
+		}
+		if final.CompletedTurns != p.Turns {
+			t.Errorf("%vx%v: final turn is %v, expected %v", size, size, final.CompletedTurns, p.Turns)
+		}
+		if len(final.Alive) != alive[10000] {
+			t.Errorf("%vx%v: %v cells alive on the final turn, expected %v", size, size, len(final.Alive), alive[10000])
+		}
+	}
+}
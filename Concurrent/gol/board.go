@@ -0,0 +1,249 @@
+package gol
+
+import (
+	"math/bits"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+//bitBoard holds a strip of the world packed 64 cells to a word, with a bit set for
+//each alive cell. Cell x of a row is bit x%64 of word x/64, and the bits past the end
+//of a row are always 0. Dying cells of a Generations rule are neither alive nor dead,
+//so their gray levels are kept beside the bits.
+type bitBoard struct {
+	width  int
+	height int
+	rows   [][]uint64
+	//Bits set for the cells that are dying, nil for a life-like rule
+	dying [][]uint64
+	//Gray levels of the dying cells, nil for a life-like rule
+	levels [][]byte
+}
+
+//Returns the number of words needed to pack n cells
+func wordsFor(n int) int {
+	return (n + 63) / 64
+}
+
+func getBit(words []uint64, i int) bool {
+	return words[i/64]&(1<<uint(i%64)) != 0
+}
+
+func setBit(words []uint64, i int, value bool) {
+	if value {
+		words[i/64] |= 1 << uint(i%64)
+	} else {
+		words[i/64] &^= 1 << uint(i%64)
+	}
+}
+
+func newBitBoard(width int, height int, rule Rule) bitBoard {
+	b := bitBoard{width: width, height: height, rows: make([][]uint64, height)}
+	for y := range b.rows {
+		b.rows[y] = make([]uint64, wordsFor(width))
+	}
+	if rule.States > 2 {
+		b.dying = make([][]uint64, height)
+		b.levels = make([][]byte, height)
+		for y := range b.dying {
+			b.dying[y] = make([]uint64, wordsFor(width))
+			b.levels[y] = make([]byte, width)
+		}
+	}
+	return b
+}
+
+//Packs a strip of gray levels, as read from an image
+func packBoard(world [][]byte, width int, rule Rule) bitBoard {
+	b := newBitBoard(width, len(world), rule)
+	for y, row := range world {
+		for x, level := range row {
+			setBit(b.rows[y], x, level == 255)
+			if b.dying != nil && level != 0 && level != 255 {
+				setBit(b.dying[y], x, true)
+				b.levels[y][x] = level
+			}
+		}
+	}
+	return b
+}
+
+//Returns the gray level of a cell
+func (b bitBoard) level(x int, y int) byte {
+	if getBit(b.rows[y], x) {
+		return 255
+	}
+	if b.dying != nil && getBit(b.dying[y], x) {
+		return b.levels[y][x]
+	}
+	return 0
+}
+
+//Sets the gray level of a cell, as read from an image
+func (b bitBoard) setLevel(x int, y int, level byte) {
+	setBit(b.rows[y], x, level == 255)
+	if b.dying != nil {
+		setBit(b.dying[y], x, level != 0 && level != 255)
+		b.levels[y][x] = level
+	}
+}
+
+//Unpacks the board into gray levels
+func (b bitBoard) unpack() [][]byte {
+	world := make([][]byte, b.height)
+	for y := range world {
+		world[y] = make([]byte, b.width)
+		for x := range world[y] {
+			world[y][x] = b.level(x, y)
+		}
+	}
+	return world
+}
+
+//Returns the cells whose bits are set, with startY added to their rows
+func setCells(rows [][]uint64, startY int) []util.Cell {
+	count := 0
+	for _, row := range rows {
+		for _, word := range row {
+			count += bits.OnesCount64(word)
+		}
+	}
+	cells := make([]util.Cell, 0, count)
+	for y, row := range rows {
+		for i, word := range row {
+			for word != 0 {
+				cells = append(cells, util.Cell{X: i*64 + bits.TrailingZeros64(word), Y: y + startY})
+				word &= word - 1
+			}
+		}
+	}
+	return cells
+}
+
+//Adds a word of bits to 64 four bit counters at once. Bit i of s0 is the lowest bit
+//of counter i, and bit i of s3 its highest.
+func addBits(s0 *uint64, s1 *uint64, s2 *uint64, s3 *uint64, x uint64) {
+	carry := *s0 & x
+	*s0 ^= x
+	x = carry
+	carry = *s1 & x
+	*s1 ^= x
+	x = carry
+	carry = *s2 & x
+	*s2 ^= x
+	*s3 |= carry
+}
+
+//Returns a word with a bit set for every counter that is one of the counts set in mask
+func countsIn(mask uint16, s0 uint64, s1 uint64, s2 uint64, s3 uint64) uint64 {
+	matches := uint64(0)
+	for n := uint(0); n <= 8; n++ {
+		if mask&(1<<n) == 0 {
+			continue
+		}
+		match := ^uint64(0)
+		for i, s := range [4]uint64{s0, s1, s2, s3} {
+			if n&(1<<uint(i)) != 0 {
+				match &= s
+			} else {
+				match &^= s
+			}
+		}
+		matches |= match
+	}
+	return matches
+}
+
+//Returns word i of a row shifted so that each bit holds the cell to its left,
+//taking the cell left of the row from the halo
+func westOf(row []uint64, i int, left bool) uint64 {
+	word := row[i] << 1
+	if i > 0 {
+		word |= row[i-1] >> 63
+	} else if left {
+		word |= 1
+	}
+	return word
+}
+
+//Returns word i of a row shifted so that each bit holds the cell to its right,
+//taking the cell right of the row, which is width cells long, from the halo
+func eastOf(row []uint64, i int, right bool, width int) uint64 {
+	word := row[i] >> 1
+	if i < len(row)-1 {
+		word |= row[i+1] << 63
+	} else if right {
+		word |= 1 << uint((width-1)%64)
+	}
+	return word
+}
+
+//Executes one turn of the board into next, given the cells just outside it. Counts the alive
+//neighbours of 64 cells at once, by adding up the 8 rows shifted onto each cell's position.
+func (b bitBoard) step(next bitBoard, rule Rule, halo filler) {
+	birth, survival := rule.Birth, rule.Survival
+	if birth == 0 && survival == 0 {
+		birth, survival = Conway.Birth, Conway.Survival
+	}
+	last := wordsFor(b.width) - 1
+	lastMask := ^uint64(0) >> uint(64*(last+1)-b.width)
+	for y := 0; y < b.height; y++ {
+		above := halo.upperLine
+		if y > 0 {
+			above = b.rows[y-1]
+		}
+		below := halo.lowerLine
+		if y < b.height-1 {
+			below = b.rows[y+1]
+		}
+		row := b.rows[y]
+		//The halo's columns start on the line above the strip
+		aboveLeft, aboveRight := getBit(halo.leftColumn, y), getBit(halo.rightColumn, y)
+		left, right := getBit(halo.leftColumn, y+1), getBit(halo.rightColumn, y+1)
+		belowLeft, belowRight := getBit(halo.leftColumn, y+2), getBit(halo.rightColumn, y+2)
+		for i := 0; i <= last; i++ {
+			var s0, s1, s2, s3 uint64
+			addBits(&s0, &s1, &s2, &s3, westOf(above, i, aboveLeft))
+			addBits(&s0, &s1, &s2, &s3, above[i])
+			addBits(&s0, &s1, &s2, &s3, eastOf(above, i, aboveRight, b.width))
+			addBits(&s0, &s1, &s2, &s3, westOf(row, i, left))
+			addBits(&s0, &s1, &s2, &s3, eastOf(row, i, right, b.width))
+			addBits(&s0, &s1, &s2, &s3, westOf(below, i, belowLeft))
+			addBits(&s0, &s1, &s2, &s3, below[i])
+			addBits(&s0, &s1, &s2, &s3, eastOf(below, i, belowRight, b.width))
+
+			alive := row[i]
+			fading := uint64(0)
+			if b.dying != nil {
+				fading = b.dying[y][i]
+			}
+			word := countsIn(survival, s0, s1, s2, s3)&alive | countsIn(birth, s0, s1, s2, s3)&^alive&^fading
+			if i == last {
+				word &= lastMask
+			}
+			next.rows[y][i] = word
+			if b.dying != nil {
+				next.dying[y][i] = b.fade(next, rule, y, i, alive&^word, fading)
+			}
+		}
+	}
+}
+
+//Moves the dying cells in word i of row y on by one state, starting the cells that have
+//just stopped being alive at the first dying state. Returns the cells still dying.
+func (b bitBoard) fade(next bitBoard, rule Rule, y int, i int, died uint64, fading uint64) uint64 {
+	dying := uint64(0)
+	for cells := died | fading; cells != 0; cells &= cells - 1 {
+		bit := uint(bits.TrailingZeros64(cells))
+		x := i*64 + int(bit)
+		level := rule.Level(2)
+		if died&(1<<bit) == 0 {
+			level = rule.Next(b.levels[y][x], 0)
+		}
+		if level != 0 {
+			dying |= 1 << bit
+			next.levels[y][x] = level
+		}
+	}
+	return dying
+}
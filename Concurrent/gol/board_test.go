@@ -0,0 +1,140 @@
+package gol
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func randomWorld(p Params, seed int64) [][]byte {
+	random := rand.New(rand.NewSource(seed))
+	world := make([][]byte, p.ImageHeight)
+	for y := range world {
+		world[y] = make([]byte, p.ImageWidth)
+		for x := range world[y] {
+			if random.Intn(3) == 0 {
+				world[y][x] = 255
+			}
+		}
+	}
+	return world
+}
+
+// TestBitBoard runs random worlds that span several words, with a partly used word at the end of
+// each row, under every topology and both a life-like and a Generations rule. Every turn must
+// match a reference implementation that counts the neighbours of each cell one by one.
+func TestBitBoard(t *testing.T) {
+	for _, rule := range []string{"B3/S23", "B36/S23", "B2/S/C4"} {
+		for _, topology := range []Topology{Torus, Plane, Cylinder, KleinBottle, ProjectivePlane} {
+			p := Params{ImageWidth: 130, ImageHeight: 70, Threads: 3, Rule: mustParseRule(t, rule), Topology: topology}
+			name := fmt.Sprintf("%v on a %v", p.Rule, topology)
+			world := randomWorld(p, 1)
+			for turn := 1; turn <= 10; turn++ {
+				expected := referenceStep(p, world)
+				world = step(p, world)
+				assertEqualWorld(t, name, world, expected)
+				if t.Failed() {
+					t.Fatalf("%v: differs from the reference on turn %v", name, turn)
+				}
+			}
+		}
+	}
+}
+
+//Executes one turn of a strip stored a byte to a cell, as the workers did before their
+//boards were packed, counting the neighbours of each cell one by one. The strip is the
+//whole world on a torus, so the rows beyond it are its own last and first rows.
+func byteStep(world [][]byte, next [][]byte, rule Rule) {
+	h, w := len(world), len(world[0])
+	for y := range world {
+		for x, cell := range world[y] {
+			n := 0
+			for dy := -1; dy <= 1; dy++ {
+				row := world[(y+dy+h)%h]
+				for dx := -1; dx <= 1; dx++ {
+					if (dx != 0 || dy != 0) && row[(x+dx+w)%w] == 255 {
+						n++
+					}
+				}
+			}
+			next[y][x] = rule.Next(cell, n)
+		}
+	}
+}
+
+//Executes turns of a single strip, as one worker does, leaving out the distributor. The
+//packed board is compared with a board of a byte to a cell, which sends the same events.
+func BenchmarkTurn(b *testing.B) {
+	for _, size := range []int{64, 512, 5120} {
+		p := Params{ImageWidth: size, ImageHeight: size, Threads: 1}
+		events := make(chan Event, 1000)
+		go func() {
+			for range events {
+			}
+		}()
+		c := workerChannels{events: events}
+		b.Run(fmt.Sprintf("%vx%v/bytes", size, size), func(b *testing.B) {
+			world := randomWorld(p, 1)
+			next := randomWorld(p, 2)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				byteStep(world, next, p.Rule)
+				aliveCount := 0
+				for y, row := range next {
+					for x, cell := range row {
+						if cell == 255 {
+							aliveCount++
+						}
+						if cell != world[y][x] {
+							sendFlippedEvent(x, y, cell, i, c)
+						}
+					}
+				}
+				world, next = next, world
+			}
+		})
+		b.Run(fmt.Sprintf("%vx%v/bits", size, size), func(b *testing.B) {
+			wp := workerParams{EndY: size, ImageWidth: size, ImageHeight: size, Rule: p.Rule}
+			board := packBoard(randomWorld(p, 1), size, p.Rule)
+			next := newBitBoard(size, size, p.Rule)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				halo := buildHalos(p, []filler{stripEdges(board, 0)})[0]
+				calculateNextState(0, wp, board, next, c, i, halo)
+				board, next = next, board
+			}
+		})
+		close(events)
+	}
+}
+
+//Executes turns of a single strip without sending any events, to time the neighbour counting
+//alone, on a board of a byte to a cell and on a packed board
+func BenchmarkStep(b *testing.B) {
+	for _, size := range []int{64, 512, 5120} {
+		p := Params{ImageWidth: size, ImageHeight: size, Threads: 1}
+		b.Run(fmt.Sprintf("%vx%v/bytes", size, size), func(b *testing.B) {
+			world := randomWorld(p, 1)
+			next := randomWorld(p, 2)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				byteStep(world, next, p.Rule)
+				world, next = next, world
+			}
+		})
+		b.Run(fmt.Sprintf("%vx%v/bits", size, size), func(b *testing.B) {
+			board := packBoard(randomWorld(p, 1), size, p.Rule)
+			next := newBitBoard(size, size, p.Rule)
+			halo := buildHalos(p, []filler{stripEdges(board, 0)})[0]
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				board.step(next, p.Rule, halo)
+				board, next = next, board
+			}
+		})
+	}
+}
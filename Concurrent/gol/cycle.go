@@ -0,0 +1,107 @@
+package gol
+
+import "uk.ac.bris.cs/gameoflife/util"
+
+//Longest cycle that is looked for. The hashes of the boards of this many of the latest
+//turns are kept.
+const maxCyclePeriod = 1024
+
+//Returns a random looking key for a cell at a gray level, which is 0 for a dead cell. The
+//hash of a board is the XOR of the keys of its cells, so that it can be kept up to date
+//from the flipped cells without going over the whole board.
+func cellKey(cell util.Cell, level uint8) uint64 {
+	if level == 0 {
+		return 0
+	}
+	//The finaliser of splitmix64
+	z := uint64(uint32(cell.X))<<32 | uint64(uint32(cell.Y))
+	z += uint64(level) * 0x9e3779b97f4a7c15
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+//Returns the hash of a board of gray levels
+func boardHash(board [][]byte) uint64 {
+	hash := uint64(0)
+	for y, row := range board {
+		for x, level := range row {
+			hash ^= cellKey(util.Cell{X: x, Y: y}, level)
+		}
+	}
+	return hash
+}
+
+//cycleDetector finds the first turn on which the board is the same as on one of the turns
+//shortly before it. From then on the game only repeats those turns. Different boards can
+//have the same hash, so a repeated hash is only a candidate cycle, which is found once
+//the board after another period is exactly the board the hash repeated on.
+type cycleDetector struct {
+	hash uint64
+	//Latest turn that each of the recent hashes was seen on
+	seen map[uint64]int
+	//Hashes of the latest turns, indexed by the turn modulo maxCyclePeriod
+	recent []uint64
+	found  bool
+	//Turn that the hash of the candidate cycle repeated on, and its period
+	candidate, period int
+	//Levels on the candidate turn of the cells that have changed since. Nil if there is no candidate.
+	changed map[util.Cell]uint8
+}
+
+//Returns a detector starting from a board with the given hash on a turn
+func newCycleDetector(hash uint64, turn int) *cycleDetector {
+	d := &cycleDetector{hash: hash, seen: map[uint64]int{}, recent: make([]uint64, maxCyclePeriod)}
+	d.record(turn)
+	return d
+}
+
+//Updates the hash of the board for a cell that has changed
+func (d *cycleDetector) flip(cell util.Cell, before uint8, after uint8) {
+	d.hash ^= cellKey(cell, before) ^ cellKey(cell, after)
+	if d.changed == nil {
+		return
+	}
+	if level, ok := d.changed[cell]; !ok {
+		d.changed[cell] = before
+	} else if level == after {
+		delete(d.changed, cell)
+	}
+}
+
+//Checks the board after a turn against the boards of the recent turns. Returns the period
+//of the cycle the first time that one is found.
+func (d *cycleDetector) turnComplete(turn int) (int, bool) {
+	if d.found {
+		return 0, false
+	}
+	if d.changed != nil && turn == d.candidate+d.period {
+		if len(d.changed) == 0 {
+			d.found = true
+			return d.period, true
+		}
+		//The hash collided with the hash of another board
+		d.changed = nil
+	}
+	if last, ok := d.seen[d.hash]; ok && d.changed == nil {
+		d.candidate, d.period = turn, turn-last
+		d.changed = map[util.Cell]uint8{}
+	}
+	d.record(turn)
+	return 0, false
+}
+
+func (d *cycleDetector) record(turn int) {
+	slot := turn % maxCyclePeriod
+	if last, ok := d.seen[d.recent[slot]]; ok && last == turn-maxCyclePeriod {
+		delete(d.seen, d.recent[slot])
+	}
+	d.seen[d.hash] = turn
+	d.recent[slot] = d.hash
+}
+
+//Returns the turns that can be skipped once a cycle of a period has been found on a turn,
+//which are the whole cycles that fit before the last turn
+func cycleSkip(p Params, turn int, period int) int {
+	return period * ((p.Turns - turn) / period)
+}
@@ -0,0 +1,101 @@
+package gol
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestCycleDetected runs a block and a blinker with the engines that look for cycles,
+// checking that the cycle of period 2, whose hash repeats on turn 2, is found once the
+// board repeats again on turn 4, and that skipping the cycles leaves the blinker in the
+// right phase on the last turn
+func TestCycleDetected(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	input := filepath.Join(dir, "blinker.cells")
+	if err := ioutil.WriteFile(input, []byte("OO\nOO\n\n\n\n\n.OOO\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	block := []util.Cell{{X: 0, Y: 0}, {X: 1, Y: 0}, {X: 0, Y: 1}, {X: 1, Y: 1}}
+	vertical := []util.Cell{{X: 2, Y: 5}, {X: 2, Y: 6}, {X: 2, Y: 7}}
+
+	for _, engine := range []Engine{StripEngine, SparseEngine} {
+		p := Params{
+			Turns:       1<<40 + 1,
+			Threads:     4,
+			ImageWidth:  16,
+			ImageHeight: 16,
+			Rule:        Conway,
+			Engine:      engine,
+			Input:       input,
+			OutputDir:   dir,
+			SkipCycles:  true,
+		}
+		events := make(chan Event)
+		go Run(p, events, nil)
+
+		cycles := []CycleDetected{}
+		var final FinalTurnComplete
+		for event := range events {
+			switch e := event.(type) {
+			case CycleDetected:
+				cycles = append(cycles, e)
+			case FinalTurnComplete:
+				final = e
+			}
+		}
+
+		if len(cycles) != 1 || cycles[0] != (CycleDetected{CompletedTurns: 4, Period: 2}) {
+			t.Errorf("%v: detected %v, expected a cycle of period 2 on turn 4", engine, cycles)
+		}
+		if final.CompletedTurns != p.Turns {
+			t.Errorf("%v: final turn is %v, expected %v", engine, final.CompletedTurns, p.Turns)
+		}
+		if !sameCells(final.Alive, append(append([]util.Cell{}, block...), vertical...)) {
+			t.Errorf("%v: final board is %v, expected the block and a vertical blinker", engine, final.Alive)
+		}
+	}
+}
+
+// TestCycleCollision gives a board the hash of the empty board of turn 0, as if the two
+// had the same hash, and checks that no cycle is found until the board really repeats.
+func TestCycleCollision(t *testing.T) {
+	a, b := util.Cell{X: 1, Y: 2}, util.Cell{X: 3, Y: 4}
+	d := newCycleDetector(0, 0)
+
+	//Turn 1 has a cell alive but its hash collides with turn 0
+	d.flip(a, 0, 255)
+	d.hash = 0
+	if period, ok := d.turnComplete(1); ok {
+		t.Fatalf("found a cycle of period %v on turn 1 from a collision", period)
+	}
+	d.hash = cellKey(a, 255)
+	//Turn 2 is not the board of turn 1 either
+	d.flip(a, 255, 0)
+	d.flip(b, 0, 255)
+	if period, ok := d.turnComplete(2); ok {
+		t.Fatalf("found a cycle of period %v on turn 2 from a collision", period)
+	}
+
+	//From turn 3 the board goes back and forth between the two cells, which is a real
+	//cycle of period 2. The board of turn 2 comes back on turn 4, and again on turn 6.
+	for turn := 3; turn <= 6; turn++ {
+		x, y := a, b
+		if turn%2 == 0 {
+			x, y = b, a
+		}
+		d.flip(y, 255, 0)
+		d.flip(x, 0, 255)
+		period, ok := d.turnComplete(turn)
+		if ok != (turn == 6) || ok && period != 2 {
+			t.Errorf("turn %v: got a cycle %v of period %v", turn, ok, period)
+		}
+	}
+}
@@ -12,81 +12,59 @@ type distributorChannels struct {
 	ioCommand            chan<- ioCommand
 	ioIdle               <-chan bool
 	input                <-chan uint8
+	inputError           <-chan error
 	output               chan<- uint8
 	filename             chan<- string
+	region               chan<- Region
 	keyPresses           <-chan rune
+	edits                <-chan Edit
+	seeks                <-chan int
 	workerEvents         chan Event
 	workerKeyPresses     []chan rune
+	workerEdits          []chan stripEdit
 	fillers              []chan filler
 	globalFiller         chan filler
-	turnFinishedChannels []chan bool
+	turnFinishedChannels []chan int
+	//Receives the cells changed by each turn if the statistics are written, nil otherwise
+	stats     chan<- statsChanges
+	statsDone <-chan bool
 }
 
 // distributor divides the work between workers and interacts with other goroutines.
 func distributor(p Params, c distributorChannels) {
-
-	//Create a 2D slice to store the world.
-	world := make([][]byte, p.ImageHeight)
-	for i := range world {
-		world[i] = make([]byte, p.ImageWidth)
-	}
-
-	//TODO: This is implementation uses busy waiting and is bad.
-	c.ioCommand <- ioCheckIdle
-	//fmt.Println("Sent idle check")
-	for {
-		idle := false
-		select {
-		case x := <-c.ioIdle:
-			idle = x
-		}
-		if idle {
-			break
-		}
-	}
-
-	c.ioCommand <- ioInput
-
-	s := fmt.Sprintf("%dx%d", p.ImageWidth, p.ImageHeight)
-	c.filename <- s
-
-	//TODO: Fix
-	for i := 0; i < p.ImageHeight; i++ {
-		for j := 0; j < p.ImageWidth; j++ {
-			select {
-			case b := <-c.input:
-				world[i][j] = b
-			}
-		}
+	world, err := readWorld(p, c)
+	if err != nil {
+		c.events <- ErrorOccurred{0, err}
+		finish(c, 0)
+		return
 	}
 
 	//TODO: Initialise semaphores for locking finished workers
 	turn := 0
-	threadHeight := float32(p.ImageHeight) / float32(p.Threads)
 
 	//TODO: Split image, send worker goroutines
 	for t := 0; t < p.Threads; t++ {
-		endY := int(float32(t+1) * threadHeight)
-		if t == p.Threads-1 {
-			endY = p.ImageHeight
-		}
-		startY := int(float32(t) * threadHeight)
+		startY, endY := stripBounds(p, t)
 
 		fillerElement := make(chan filler, p.Threads)
 		c.fillers[t] = fillerElement
 
-		finishedChannel := make(chan bool)
+		finishedChannel := make(chan int)
 		c.turnFinishedChannels[t] = finishedChannel
 
 		keyPress := make(chan rune, 10)
 		c.workerKeyPresses[t] = keyPress
 
+		edits := make(chan stripEdit, 10)
+		c.workerEdits[t] = edits
+
 		workerParams := workerParams{
 			StartY:      startY,
 			EndY:        endY,
 			ImageWidth:  p.ImageWidth,
 			ImageHeight: endY - startY,
 			Turns:       p.Turns,
+			Rule:        p.Rule,
 		}
 		workerChannels := workerChannels{
 			events:          c.workerEvents,
@@ -94,106 +72,272 @@ func distributor(p Params, c distributorChannels) {
 			workerFiller:    fillerElement,
 			finishedChannel: finishedChannel,
 			keyPresses:      keyPress,
+			edits:           edits,
 		}
 		//fmt.Println("Sent worker", t)
 		go worker(world[startY:endY], workerParams, workerChannels, t)
 	}
 
-	turn = handleChannels(p, c)
+	turn = handleChannels(p, c, world)
+	finish(c, turn)
+}
+
+//Waits for any output to be written, then tells the SDL goroutine that the game is over
+func finish(c distributorChannels, turn int) {
 	// Make sure that the Io has finished any output before exiting.
 	c.ioCommand <- ioCheckIdle
 	<-c.ioIdle
+	if c.stats != nil {
+		close(c.stats)
+		<-c.statsDone
+	}
 
 	c.events <- StateChange{turn, Quitting}
 	// Close the channel to stop the SDL goroutine gracefully. Removing may cause deadlock.
 	close(c.events)
 }
 
-func sendLinesToWorker(p Params, f filler, c distributorChannels) {
-	worker := f.workerID
-	upperWorker := (worker + 1) % p.Threads
-	lowerWorker := (worker - 1)
-	if lowerWorker < 0 {
-		lowerWorker = lowerWorker + p.Threads
+//Reads the world from the input image
+func readWorld(p Params, c distributorChannels) ([][]byte, error) {
+	//Create a 2D slice to store the world.
+	world := make([][]byte, p.ImageHeight)
+	for i := range world {
+		world[i] = make([]byte, p.ImageWidth)
+	}
+
+	//TODO: This is implementation uses busy waiting and is bad.
+	c.ioCommand <- ioCheckIdle
+	//fmt.Println("Sent idle check")
+	for {
+		idle := false
+		select {
+		case x := <-c.ioIdle:
+			idle = x
+		}
+		if idle {
+			break
+		}
 	}
-	c.fillers[upperWorker] <- filler{lowerLine: nil, upperLine: f.upperLine, workerID: worker}
-	c.fillers[lowerWorker] <- filler{lowerLine: f.lowerLine, upperLine: nil, workerID: worker}
+
+	c.ioCommand <- ioInput
+
+	s := fmt.Sprintf("%dx%d", p.ImageWidth, p.ImageHeight)
+	c.filename <- s
+
+	//TODO: Fix
+	for i := 0; i < p.ImageHeight; i++ {
+		for j := 0; j < p.ImageWidth; j++ {
+			select {
+			case b := <-c.input:
+				world[i][j] = p.Rule.Level(p.Rule.State(b))
+			case err := <-c.inputError:
+				return nil, err
+			}
+		}
+	}
+	return world, nil
+}
+
+//Returns the rows of the world that a worker works on, from startY up to but not including endY
+func stripBounds(p Params, t int) (int, int) {
+	threadHeight := float32(p.ImageHeight) / float32(p.Threads)
+	endY := int(float32(t+1) * threadHeight)
+	if t == p.Threads-1 {
+		endY = p.ImageHeight
+	}
+	return int(float32(t) * threadHeight), endY
+}
+
+//Sends each worker the cells just outside its strip, once every worker has sent its edges.
+//The edges of the world are joined as the topology says.
+func sendLinesToWorkers(p Params, edges []filler, c distributorChannels) {
+	for t, halo := range buildHalos(p, edges) {
+		c.fillers[t] <- halo
+	}
+}
+
+//Returns the cells just outside each worker's strip, from the edges of all strips
+func buildHalos(p Params, edges []filler) []filler {
+	halos := make([]filler, p.Threads)
+	for t := range halos {
+		startY, endY := stripBounds(p, t)
+		halo := filler{
+			lowerLine:   make([]uint64, wordsFor(p.ImageWidth)),
+			upperLine:   make([]uint64, wordsFor(p.ImageWidth)),
+			leftColumn:  make([]uint64, wordsFor(endY-startY+2)),
+			rightColumn: make([]uint64, wordsFor(endY-startY+2)),
+			workerID:    t,
+		}
+		for x := 0; x < p.ImageWidth; x++ {
+			setBit(halo.upperLine, x, edgeCell(p, edges, x, startY-1))
+			setBit(halo.lowerLine, x, edgeCell(p, edges, x, endY))
+		}
+		for y := startY - 1; y <= endY; y++ {
+			setBit(halo.leftColumn, y-startY+1, edgeCell(p, edges, -1, y))
+			setBit(halo.rightColumn, y-startY+1, edgeCell(p, edges, p.ImageWidth, y))
+		}
+		halos[t] = halo
+	}
+	return halos
+}
+
+//Returns whether a cell next to a strip is alive. It is either on the edge of another
+//strip or, once it has been wrapped onto the world, on the edge of the world.
+func edgeCell(p Params, edges []filler, x int, y int) bool {
+	x, y, ok := p.Topology.Wrap(x, y, p.ImageWidth, p.ImageHeight)
+	if !ok {
+		return false
+	}
+	for t, edge := range edges {
+		startY, endY := stripBounds(p, t)
+		switch {
+		case y < startY || y >= endY:
+			continue
+		case y == startY:
+			return getBit(edge.lowerLine, x)
+		case y == endY-1:
+			return getBit(edge.upperLine, x)
+		case x == 0:
+			return getBit(edge.leftColumn, y-startY)
+		default:
+			return getBit(edge.rightColumn, y-startY)
+		}
+	}
+	return false
 }
 
-func handleChannels(p Params, c distributorChannels) int {
+func handleChannels(p Params, c distributorChannels, world [][]byte) int {
 	isDone := false
 	aliveCells := []util.Cell{}
 	savingAliveCells := []util.Cell{}
+	dyingCells := map[util.Cell]uint8{}
+	savingDyingCells := map[util.Cell]uint8{}
 
 	workersCompletedTurn := 0
+	edges := make([]filler, p.Threads)
+	workersSentEdges := 0
 	workersFinished := 0
 	turn := 0
 	isPaused := false
 	isSaving := false
 	imageStripsSaved := 0
 
-	ticker := time.NewTicker(2 * time.Second)
+	ticker := time.NewTicker(reportInterval(p))
+	telemetry := newTelemetryMeter(p.Threads, 0)
 
 	prevTurnAliveCellCount := 0
 	workingAliveCellCount := 0
 
+	//Gray levels of the board after the last turn, kept up to date from the flipped cells
+	board := make([][]byte, p.ImageHeight)
+	for y := range board {
+		board[y] = append([]byte{}, world[y]...)
+	}
+	past := newHistory(p.History)
+	turnChanges := []cellChange{}
+	//Turn that the workers pause on once it is complete, 0 if they are not to pause
+	stopAt := 0
+	cycles := newCycleDetector(boardHash(board), 0)
+	sendStats(c, 0, boardChanges(board), true)
+
 	for {
 		select {
 		case event := <-c.workerEvents:
 			switch e := event.(type) {
 			case WorkerTurnComplete:
+				telemetry.turnComplete(e.WorkerID)
 				workersCompletedTurn++
 				workingAliveCellCount += e.CellsCount
 				if workersCompletedTurn == p.Threads {
 					workersCompletedTurn = 0
 					prevTurnAliveCellCount = workingAliveCellCount
 					workingAliveCellCount = 0
+					past.add(historyEntry{from: turn, to: turn + 1, changes: turnChanges})
+					sendStats(c, turn+1, turnChanges, false)
+					turnChanges = []cellChange{}
 					c.events <- TurnComplete{CompletedTurns: turn}
 					(turn)++
+					if period, ok := cycles.turnComplete(turn); ok {
+						c.events <- CycleDetected{CompletedTurns: turn, Period: period}
+						//Steps and seeks run to their turn, however many cycles fit before it
+						if skip := cycleSkip(p, turn, period); p.SkipCycles && stopAt == 0 && skip > 0 {
+							past.add(historyEntry{from: turn, to: turn + skip})
+							turn += skip
+						}
+					}
+					//The workers take the pause before they are told to start the next turn
+					if turn == stopAt {
+						for _, kp := range c.workerKeyPresses {
+							kp <- 'p'
+						}
+						stopAt = 0
+						isPaused = true
+						c.events <- StateChange{turn, Paused}
+					}
 					//Send all clear to workers to start next turn
 					for i := 0; i < p.Threads; i++ {
-						c.turnFinishedChannels[i] <- true
+						c.turnFinishedChannels[i] <- turn
 					}
 					//fmt.Println("======TURN COMPLETE========")
 				}
 			case WorkerFinalTurnComplete:
 				workersFinished++
 				aliveCells = append(aliveCells, e.Alive...)
+				for cell, value := range e.Dying {
+					dyingCells[cell] = value
+				}
 				if workersFinished == p.Threads {
 					//TODO: Retrieve alive cells from workers
 					c.events <- FinalTurnComplete{CompletedTurns: turn, Alive: aliveCells}
 					isDone = true
-					outputImage(p, c, aliveCells, turn)
+					outputImage(p, c, aliveCells, dyingCells, turn)
 				}
 			case CellFlipped:
+				if before := board[e.Cell.Y][e.Cell.X]; before != e.Value {
+					turnChanges = append(turnChanges, cellChange{cell: e.Cell, before: before, after: e.Value})
+					board[e.Cell.Y][e.Cell.X] = e.Value
+					cycles.flip(e.Cell, before, e.Value)
+				}
 				c.events <- event
 			case WorkerSaveImage:
 				savingAliveCells = append(savingAliveCells, e.Alive...)
+				for cell, value := range e.Dying {
+					savingDyingCells[cell] = value
+				}
 				imageStripsSaved++
 				if imageStripsSaved == p.Threads {
 					//fmt.Println("Received alive Cells,", savingAliveCells)
-					outputImage(p, c, savingAliveCells, turn)
+					outputImage(p, c, savingAliveCells, savingDyingCells, turn)
 					imageStripsSaved = 0
 					isSaving = false
 				}
 			}
 		case f := <-c.globalFiller:
-			sendLinesToWorker(p, f, c)
+			edges[f.workerID] = f
+			telemetry.edgesReceived(f.workerID)
+			workersSentEdges++
+			if workersSentEdges == p.Threads {
+				workersSentEdges = 0
+				sendLinesToWorkers(p, edges, c)
+				telemetry.halosSent()
+			}
 		case <-ticker.C:
 			c.events <- AliveCellsCount{CompletedTurns: turn, CellsCount: prevTurnAliveCellCount}
+			c.events <- telemetry.report(turn)
 		case k := <-c.keyPresses:
 			switch k {
 			case 'p':
-				for _, kp := range c.workerKeyPresses {
-					kp <- k
-				}
-				isPaused = !isPaused
 				if isPaused {
-					c.events <- StateChange{turn, Paused}
-				} else {
+					for _, kp := range c.workerKeyPresses {
+						kp <- k
+					}
+					isPaused = false
 					c.events <- StateChange{turn, Executing}
+				} else {
+					//The workers are running the next turn, and pause once it is complete, so that
+					//they all stop on the same turn
+					stopAt = turn + 1
 				}
-				//TODO: Toggle pause and print turn
 			case 's':
 				if !isSaving {
 					for _, kp := range c.workerKeyPresses {
@@ -214,6 +358,43 @@ func handleChannels(p Params, c distributorChannels) int {
 				c.events <- StateChange{turn, Quitting}
 				return turn
 				//TODO: Save turn as pgm image then quit
+			case 'n':
+				if isPaused {
+					turn, stopAt = seekStrips(p, c, board, past, turn, turn+1)
+					isPaused = stopAt == 0
+					prevTurnAliveCellCount = countAlive(board)
+					cycles = newCycleDetector(boardHash(board), turn)
+				}
+			case 'b':
+				if !isPaused {
+					break
+				}
+				if entry, ok := past.stepBack(); ok {
+					levels := map[util.Cell]uint8{}
+					entry.undo(levels)
+					turn = entry.from
+					setStripCells(c, board, levels, turn)
+					prevTurnAliveCellCount = countAlive(board)
+					cycles = newCycleDetector(boardHash(board), turn)
+				}
+				c.events <- StateChange{turn, Paused}
+			}
+		case target := <-c.seeks:
+			if isPaused {
+				turn, stopAt = seekStrips(p, c, board, past, turn, target)
+				isPaused = stopAt == 0
+				prevTurnAliveCellCount = countAlive(board)
+				cycles = newCycleDetector(boardHash(board), turn)
+			}
+		case edit := <-c.edits:
+			if isPaused {
+				entry := historyEntry{from: turn, to: turn, changes: edit.applyTo(p, board)}
+				past.add(entry)
+				levels := map[util.Cell]uint8{}
+				entry.redo(levels)
+				setStripCells(c, board, levels, turn)
+				prevTurnAliveCellCount = countAlive(board)
+				cycles = newCycleDetector(boardHash(board), turn)
 			}
 		}
 		if isDone {
@@ -235,12 +416,71 @@ func saveImage(p Params, c distributorChannels, turns int) {
 			}
 		}
 	}
-	outputImage(p, c, aliveCells, turns)
+	outputImage(p, c, aliveCells, nil, turns)
 }
 
-func outputImage(p Params, c distributorChannels, aliveCells []util.Cell, turns int) {
+//Moves a paused game towards a target turn, stepping through the history as far as it
+//reaches, then resuming the workers to run the rest of the way. Returns the turn reached
+//and the turn the workers pause on, which is 0 if they were not resumed.
+func seekStrips(p Params, c distributorChannels, board [][]byte, past *history, turn int, target int) (int, int) {
+	if target > p.Turns {
+		target = p.Turns
+	}
+	if target < 0 {
+		target = 0
+	}
+	levels, reached := past.seek(turn, target)
+	if reached != turn || len(levels) > 0 {
+		setStripCells(c, board, levels, reached)
+	}
+	if reached < target {
+		for _, kp := range c.workerKeyPresses {
+			kp <- 'p'
+		}
+		c.events <- StateChange{reached, Executing}
+		return reached, target
+	}
+	if reached > target {
+		fmt.Println("Error: turn", target, "is no longer in the history, stopped at turn", reached)
+	}
+	c.events <- StateChange{reached, Paused}
+	return reached, 0
+}
+
+//Sets cells of a paused game to new gray levels and moves it to a turn, drawing the cells
+//and sending each worker the cells of its strip
+func setStripCells(c distributorChannels, board [][]byte, levels map[util.Cell]uint8, turn int) {
+	changes := make([]cellChange, 0, len(levels))
+	for cell, level := range levels {
+		changes = append(changes, cellChange{cell: cell, before: board[cell.Y][cell.X], after: level})
+		board[cell.Y][cell.X] = level
+		c.events <- CellFlipped{CompletedTurns: turn, Cell: cell, Value: level}
+	}
+	sendStats(c, turn, changes, true)
+	//Each worker takes the edit after its pause and before its resume, as keys arrive in order
+	for t, kp := range c.workerKeyPresses {
+		c.workerEdits[t] <- stripEdit{turn: turn, levels: levels}
+		kp <- 'e'
+	}
+}
+
+//Returns the number of alive cells on a board of gray levels
+func countAlive(board [][]byte) int {
+	count := 0
+	for _, row := range board {
+		for _, level := range row {
+			if level == 255 {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+//Writes the alive cells to a pgm image, along with the gray levels of any dying cells
+func outputImage(p Params, c distributorChannels, aliveCells []util.Cell, dyingCells map[util.Cell]uint8, turns int) {
 	c.ioCommand <- ioOutput
-	s := fmt.Sprintf("%dx%dx%d", p.ImageWidth, p.ImageHeight, turns)
+	s := outputName(p.OutputName, p, p.ImageWidth, p.ImageHeight, 0, 0, turns)
 	c.filename <- s
 
 	world := make([][]byte, p.ImageHeight)
@@ -254,6 +494,9 @@ func outputImage(p Params, c distributorChannels, aliveCells []util.Cell, turns
 	for _, cell := range aliveCells {
 		world[cell.Y][cell.X] = 255
 	}
+	for cell, value := range dyingCells {
+		world[cell.Y][cell.X] = value
+	}
 
 	for i := 0; i < p.ImageHeight; i++ {
 		for j := 0; j < p.ImageWidth; j++ {
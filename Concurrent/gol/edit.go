@@ -0,0 +1,66 @@
+package gol
+
+import "uk.ac.bris.cs/gameoflife/util"
+
+//Edit changes cells of a paused game. The cells are toggled, or made alive when Alive
+//is set, as when a pattern is pasted onto the board.
+type Edit struct {
+	Cells []util.Cell
+	Alive bool
+}
+
+//Returns whether a cell is alive after the edit, given whether it was alive before
+func (e Edit) apply(alive bool) bool {
+	return e.Alive || !alive
+}
+
+//Returns the edit with its cells wrapped onto the board as the topology joins its edges,
+//leaving out any cells that fall off it
+func (e Edit) onBoard(p Params) Edit {
+	cells := make([]util.Cell, 0, len(e.Cells))
+	for _, cell := range e.Cells {
+		if x, y, ok := p.Topology.Wrap(cell.X, cell.Y, p.ImageWidth, p.ImageHeight); ok {
+			cells = append(cells, util.Cell{X: x, Y: y})
+		}
+	}
+	return Edit{Cells: cells, Alive: e.Alive}
+}
+
+//Makes the edit to a board of gray levels, and returns the changes it made. A dying cell
+//is taken as dead, and toggling it makes it alive.
+func (e Edit) applyTo(p Params, board [][]byte) []cellChange {
+	changes := []cellChange{}
+	for _, cell := range e.onBoard(p).Cells {
+		before := board[cell.Y][cell.X]
+		after := uint8(0)
+		if e.apply(before == 255) {
+			after = 255
+		}
+		if after != before {
+			changes = append(changes, cellChange{cell: cell, before: before, after: after})
+			board[cell.Y][cell.X] = after
+		}
+	}
+	return changes
+}
+
+//PatternCells reads a pattern file, as the game reads its input, and returns its alive
+//cells with the top left corner of the pattern at (0, 0)
+func PatternCells(path string, rule Rule, threshold uint8) ([]util.Cell, error) {
+	if threshold == 0 {
+		threshold = DefaultThreshold
+	}
+	world, err := readPattern(path, rule, threshold)
+	if err != nil {
+		return nil, err
+	}
+	var cells []util.Cell
+	for y, row := range world {
+		for x, level := range row {
+			if level == 255 {
+				cells = append(cells, util.Cell{X: x, Y: y})
+			}
+		}
+	}
+	return cells, nil
+}
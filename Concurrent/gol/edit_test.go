@@ -0,0 +1,109 @@
+package gol
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestEdit pauses an empty board with each engine, draws a block and pastes a blinker onto it,
+// then resumes and checks that both are still on the board after the last turn
+func TestEdit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	input := filepath.Join(dir, "empty.cells")
+	if err := ioutil.WriteFile(input, []byte(".\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	block := []util.Cell{{X: 3, Y: 3}, {X: 4, Y: 3}, {X: 3, Y: 4}, {X: 4, Y: 4}}
+	horizontal := []util.Cell{{X: 10, Y: 11}, {X: 11, Y: 11}, {X: 12, Y: 11}}
+	vertical := []util.Cell{{X: 11, Y: 10}, {X: 11, Y: 11}, {X: 11, Y: 12}}
+	edits := []Edit{
+		{Cells: block},
+		//Pasting over a cell that is already alive leaves it alive
+		{Cells: append([]util.Cell{{X: 3, Y: 3}}, horizontal...), Alive: true},
+	}
+
+	for _, engine := range []Engine{StripEngine, HashlifeEngine, SparseEngine} {
+		p := Params{
+			Turns:       201,
+			Threads:     4,
+			ImageWidth:  16,
+			ImageHeight: 16,
+			Rule:        Conway,
+			Engine:      engine,
+			Input:       input,
+			OutputDir:   dir,
+		}
+		if engine == HashlifeEngine {
+			//Each power of 2 is a separate jump, leaving time for the pause to be taken
+			p.Turns = 1<<40 - 1
+		}
+		events := make(chan Event)
+		keyPresses := make(chan rune, 10)
+		editChannel := make(chan Edit, 10)
+		keyPresses <- 'p'
+		go RunInteractive(p, events, keyPresses, editChannel, nil)
+
+		flipped := map[util.Cell]uint8{}
+		resumed := false
+		var final FinalTurnComplete
+		for event := range events {
+			switch e := event.(type) {
+			case StateChange:
+				if e.NewState == Paused {
+					for _, edit := range edits {
+						editChannel <- edit
+					}
+				}
+			case CellFlipped:
+				if _, ok := flipped[e.Cell]; !ok {
+					flipped[e.Cell] = e.Value
+				}
+				//Resumes once every edited cell has flipped, as a resume may be taken before an edit
+				if len(flipped) == len(block)+len(horizontal) && !resumed {
+					keyPresses <- 'p'
+					resumed = true
+				}
+			case FinalTurnComplete:
+				final = e
+			}
+		}
+
+		//The board is empty until the edits, so the first flip of each cell is made by them
+		for _, cell := range append(block, horizontal...) {
+			if flipped[cell] != 255 {
+				t.Errorf("%v: no flip of %v to alive", engine, cell)
+			}
+		}
+		//The blinker may be in either phase, as the turn the game paused on is not known
+		if !sameCells(final.Alive, append(append([]util.Cell{}, block...), horizontal...)) &&
+			!sameCells(final.Alive, append(append([]util.Cell{}, block...), vertical...)) {
+			t.Errorf("%v: final board is %v, expected the block and the blinker", engine, final.Alive)
+		}
+	}
+}
+
+//Returns whether two lists hold the same cells, in any order
+func sameCells(a []util.Cell, b []util.Cell) bool {
+	set := map[util.Cell]bool{}
+	for _, cell := range a {
+		set[cell] = true
+	}
+	if len(set) != len(a) || len(a) != len(b) {
+		return false
+	}
+	for _, cell := range b {
+		if !set[cell] {
+			return false
+		}
+	}
+	return true
+}
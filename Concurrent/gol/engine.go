@@ -0,0 +1,69 @@
+package gol
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+//Engine decides how the turns of the game are executed. The zero Engine splits
+//the board into strips, one for each worker thread, and executes every turn.
+type Engine uint8
+
+const (
+	//StripEngine executes every turn, with the board split between worker threads
+	StripEngine Engine = iota
+	//HashlifeEngine jumps ahead many turns at once, by building the board as a quadtree
+	//and remembering the result of every square it has seen. Fast for periodic or sparse
+	//patterns, but only for life-like rules on square tori with sides a power of 2.
+	HashlifeEngine
+	//SparseEngine keeps only the alive cells, on an unbounded plane instead of a torus,
+	//so patterns can grow and move away from the image without wrapping around
+	SparseEngine
+)
+
+var engineNames = []string{"strips", "hashlife", "sparse"}
+
+//ParseEngine reads an engine from its name: strips, hashlife or sparse
+func ParseEngine(s string) (Engine, error) {
+	for i, name := range engineNames {
+		if strings.ToLower(strings.TrimSpace(s)) == name {
+			return Engine(i), nil
+		}
+	}
+	return StripEngine, errors.New(fmt.Sprintf("unknown engine %q, must be one of %v", s, strings.Join(engineNames, ", ")))
+}
+
+func (e Engine) String() string {
+	if int(e) < len(engineNames) {
+		return engineNames[e]
+	}
+	return fmt.Sprintf("Engine(%d)", e)
+}
+
+//Set parses an engine given on the command line, so that an Engine can be used with flag.Var
+func (e *Engine) Set(s string) error {
+	engine, err := ParseEngine(s)
+	if err != nil {
+		return err
+	}
+	*e = engine
+	return nil
+}
+
+//Ends a game that the engine cannot run with an ErrorOccurred event, in place of the distributor
+func refuseEngine(c distributorChannels, err error) {
+	c.events <- ErrorOccurred{0, err}
+	finish(c, 0)
+}
+
+//Returns an error if the game cannot be run with the engine
+func (e Engine) check(p Params) error {
+	switch e {
+	case HashlifeEngine:
+		return checkHashlife(p)
+	case SparseEngine:
+		return checkSparse(p)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package gol
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestEngineTurns runs a glider for 20 turns with each engine, checking that they number
+// their turns alike: TurnComplete counts from 0 up to 19 and FinalTurnComplete reports 20.
+// The strip and sparse engines report every turn, while hashlife only reports the last
+// turn of each jump. The cells flipped by a turn carry the same number as its TurnComplete.
+func TestEngineTurns(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	input := filepath.Join(dir, "glider.cells")
+	if err := ioutil.WriteFile(input, []byte(".O.\n..O\nOOO\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	const turns = 20
+	var stripTurns []int
+	for _, engine := range []Engine{StripEngine, SparseEngine, HashlifeEngine} {
+		p := Params{
+			Turns:       turns,
+			Threads:     4,
+			ImageWidth:  16,
+			ImageHeight: 16,
+			Rule:        Conway,
+			Engine:      engine,
+			Input:       input,
+			OutputDir:   dir,
+		}
+		events := make(chan Event)
+		go Run(p, events, nil)
+
+		completed := []int{}
+		//Turns of the cells flipped since the last TurnComplete
+		flipped := []int{}
+		final := -1
+		for event := range events {
+			switch e := event.(type) {
+			case CellFlipped:
+				flipped = append(flipped, e.CompletedTurns)
+			case TurnComplete:
+				//The cells of the initial board come before the first turn, as turn 0
+				for _, turn := range flipped {
+					if turn != e.CompletedTurns && (len(completed) > 0 || turn != 0) {
+						t.Errorf("%v: cell flipped on turn %v before turn %v completed", engine, turn, e.CompletedTurns)
+					}
+				}
+				flipped = flipped[:0]
+				completed = append(completed, e.CompletedTurns)
+			case FinalTurnComplete:
+				final = e.CompletedTurns
+			}
+		}
+
+		if final != turns {
+			t.Errorf("%v: final turn is %v, expected %v", engine, final, turns)
+		}
+		switch engine {
+		case StripEngine:
+			stripTurns = completed
+			for i, turn := range completed {
+				if turn != i {
+					t.Fatalf("strips: turns completed are %v, expected 0 to %v", completed, turns-1)
+				}
+			}
+		case SparseEngine:
+			if len(completed) != len(stripTurns) {
+				t.Errorf("sparse: turns completed are %v, expected %v", completed, stripTurns)
+				break
+			}
+			for i := range completed {
+				if completed[i] != stripTurns[i] {
+					t.Errorf("sparse: turns completed are %v, expected %v", completed, stripTurns)
+					break
+				}
+			}
+		case HashlifeEngine:
+			if len(completed) == 0 || completed[len(completed)-1] != turns-1 {
+				t.Errorf("hashlife: turns completed are %v, expected them to end on %v", completed, turns-1)
+			}
+			for i := 1; i < len(completed); i++ {
+				if completed[i] <= completed[i-1] {
+					t.Errorf("hashlife: turns completed are %v, expected them to increase", completed)
+					break
+				}
+			}
+		}
+	}
+}
@@ -2,6 +2,7 @@ package gol
 
 import (
 	"fmt"
+	"time"
 
 	"uk.ac.bris.cs/gameoflife/util"
 )
@@ -29,6 +30,13 @@ type ImageOutputComplete struct { // implements Event
 	Filename       string
 }
 
+// ErrorOccurred is an Event notifying the user about an error that stops the game,
+// such as an input image that cannot be read. A Quitting StateChange follows it.
+type ErrorOccurred struct { // implements Event
+	CompletedTurns int
+	Err            error
+}
+
 // State represents a change in the state of execution.
 type State int
 
@@ -48,9 +56,13 @@ type StateChange struct { // implements Event
 // CellFlipped is an Event notifying the GUI about a change of state of a single cell.
 // This even should be sent every time a cell changes state.
 // Make sure to send this event for all cells that are alive when the image is loaded in.
+// The sparse engine has no edges, so its cells may lie outside the image, even at negative coordinates.
 type CellFlipped struct { // implements Event
 	CompletedTurns int
 	Cell           util.Cell
+	//Gray level of the cell after the change: 255 if alive, 0 if dead,
+	//and in between for the dying states of a Generations rule
+	Value uint8
 }
 
 // TurnComplete is an Event notifying the GUI about turn completion.
@@ -60,9 +72,31 @@ type TurnComplete struct { // implements Event
 	CompletedTurns int
 }
 
+// CycleDetected is an Event notifying the user that the board has come back to how it was
+// on an earlier turn, so repeats itself every Period turns from CompletedTurns on.
+// A Period of 1 is a still life. This Event is sent once, for the first cycle found.
+type CycleDetected struct { // implements Event
+	CompletedTurns int
+	Period         int
+}
+
+// Telemetry is an Event reporting how fast the game is running, sent along with each
+// AliveCellsCount. The times are averages over the turns since the last report, with one
+// for each worker's strip, so that a strip holding the others up stands out. The engines
+// without workers only report the turns per second.
+type Telemetry struct { // implements Event
+	CompletedTurns int
+	TurnsPerSecond float64
+	//Time each worker took to run its strip of a turn, once it had been sent its halo
+	TurnTimes []time.Duration
+	//Time each worker waited for its halo, from sending its edges until every worker had sent theirs
+	HaloWaits []time.Duration
+}
+
 type WorkerTurnComplete struct {
 	CompletedTurns int
 	CellsCount     int
+	WorkerID       int
 }
 
 // FinalTurnComplete is an Event notifying the testing framework about the new world state after execution finished.
@@ -76,11 +110,13 @@ type FinalTurnComplete struct {
 type WorkerFinalTurnComplete struct {
 	CompletedTurns int
 	Alive          []util.Cell
+	Dying          map[util.Cell]uint8
 }
 
 type WorkerSaveImage struct {
 	CompletedTurns int
 	Alive          []util.Cell
+	Dying          map[util.Cell]uint8
 }
 
 // String methods allow the different types of Events and States to be printed.
@@ -122,6 +158,14 @@ func (event ImageOutputComplete) GetCompletedTurns() int {
 	return event.CompletedTurns
 }
 
+func (event ErrorOccurred) String() string {
+	return fmt.Sprintf("Error: %v", event.Err)
+}
+
+func (event ErrorOccurred) GetCompletedTurns() int {
+	return event.CompletedTurns
+}
+
 func (event CellFlipped) String() string {
 	return fmt.Sprintf("")
 }
@@ -146,6 +190,28 @@ func (event FinalTurnComplete) GetCompletedTurns() int {
 	return event.CompletedTurns
 }
 
+func (event CycleDetected) String() string {
+	if event.Period == 1 {
+		return fmt.Sprintf("Still life")
+	}
+	return fmt.Sprintf("Cycle of period %v", event.Period)
+}
+
+func (event CycleDetected) GetCompletedTurns() int {
+	return event.CompletedTurns
+}
+
+func (event Telemetry) String() string {
+	if len(event.TurnTimes) == 0 {
+		return fmt.Sprintf("%.1f turns/s", event.TurnsPerSecond)
+	}
+	return fmt.Sprintf("%.1f turns/s, strip times %v, halo waits %v", event.TurnsPerSecond, event.TurnTimes, event.HaloWaits)
+}
+
+func (event Telemetry) GetCompletedTurns() int {
+	return event.CompletedTurns
+}
+
 func (event WorkerTurnComplete) String() string {
 	return fmt.Sprintf("")
 }
@@ -1,44 +1,129 @@
 package gol
 
+import (
+	"time"
+)
+
 // Params provides the details of how to run the Game of Life and which image to load.
 type Params struct {
 	Turns       int
 	Threads     int
 	ImageWidth  int
 	ImageHeight int
+	Rule        Rule
+	Topology    Topology
+	Engine      Engine
+	//Part of the plane written to images by the sparse engine
+	Region Region
+	//Pattern file to load, as a pgm, png, rle or cells file. Empty to load images/<W>x<H>.pgm
+	Input string
+	//Where the top left corner of the loaded pattern is placed on the board
+	OffsetX int
+	OffsetY int
+	//Format that images are saved in
+	Format Format
+	//Gray level from which pixels of a netpbm or png input are alive. 0 for DefaultThreshold
+	Threshold uint8
+	//Directory that images are saved in. Empty to save them in out
+	OutputDir string
+	//Template of the names images are saved under, see DefaultOutputName
+	OutputName string
+	//Animated gif file that the turns are recorded to. Empty not to record
+	Record string
+	//Number of turns between the frames of the recording. 0 to record every turn
+	RecordEvery int
+	//Width and height in pixels of each cell of the recording. 0 for 1
+	RecordScale int
+	//Number of turns and edits kept to step back through while paused. 0 for DefaultHistory,
+	//negative to keep none
+	History int
+	//Whether to skip the whole cycles that fit before the last turn once the board repeats
+	//itself. The hashlife engine jumps ahead anyway, so does not look for cycles.
+	SkipCycles bool
+	//File that the statistics of every turn are written to, as JSON Lines if it ends in .jsonl
+	//and csv otherwise. Empty not to write any. The hashlife engine cannot write them.
+	Stats string
+	//How often the alive cells and telemetry are reported. 0 for DefaultReportInterval
+	ReportInterval time.Duration
 }
 
 // Run starts the processing of Game of Life. It should initialise channels and goroutines.
 func Run(p Params, events chan<- Event, keyPresses <-chan rune) {
+	RunInteractive(p, events, keyPresses, nil, nil)
+}
+
+//RunInteractive runs the game like Run, also changing cells of the board as edits arrive
+//while the game is paused, and moving the paused game to the turns that arrive on seeks.
+//Edits and seeks that arrive while it is running are ignored.
+func RunInteractive(p Params, events chan<- Event, keyPresses <-chan rune, edits <-chan Edit, seeks <-chan int) {
 
 	ioCommand := make(chan ioCommand)
 	ioIdle := make(chan bool)
 	input := make(chan byte)
+	inputError := make(chan error)
 	output := make(chan byte)
 	filename := make(chan string)
+	region := make(chan Region)
+	var frames chan [][]byte
+	saved := make(chan bool)
+	if p.Record != "" {
+		//The recorder sits between the distributor and SDL, copying the flipped cells
+		recorded := make(chan Event)
+		frames = make(chan [][]byte, 100)
+		go record(p, recorded, events, frames, saved)
+		events = recorded
+	}
+	var stats chan statsChanges
+	statsDone := make(chan bool)
+	if p.Stats != "" {
+		stats = make(chan statsChanges, statsBuffer)
+		go writeStats(p, stats, statsDone)
+	}
 
 	distributorChannels := distributorChannels{
 		events,
 		ioCommand,
 		ioIdle,
 		input,
+		inputError,
 		output,
 		filename,
+		region,
 		keyPresses,
+		edits,
+		seeks,
 		make(chan Event),
 		make([]chan rune, p.Threads),
+		make([]chan stripEdit, p.Threads),
 		make([]chan filler, p.Threads),
 		make(chan filler),
-		make([]chan bool, p.Threads),
+		make([]chan int, p.Threads),
+		stats,
+		statsDone,
+	}
+	err := p.Engine.check(p)
+	switch {
+	case err != nil:
+		go refuseEngine(distributorChannels, err)
+	case p.Engine == HashlifeEngine:
+		go hashlifeDistributor(p, distributorChannels)
+	case p.Engine == SparseEngine:
+		go sparseDistributor(p, distributorChannels)
+	default:
+		go distributor(p, distributorChannels)
 	}
-	go distributor(p, distributorChannels)
 
 	ioChannels := ioChannels{
 		command:  ioCommand,
 		idle:     ioIdle,
 		filename: filename,
+		region:   region,
 		output:   output,
 		input:    input,
+		//Sends the error instead of the input when it cannot be read
+		inputError: inputError,
+		frames:     frames,
+		saved:      saved,
 	}
 	go startIo(p, ioChannels)
 
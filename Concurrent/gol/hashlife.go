@@ -0,0 +1,462 @@
+package gol
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+//Number of nodes and results a universe may hold before it is rebuilt with only the
+//nodes of the current board, so that the squares of past turns can be freed
+const maxNodes = 1 << 21
+
+//How long a jump may take before the next one is made a level smaller, so that keys
+//and the ticker are still seen while the game runs
+const maxJumpTime = 100 * time.Millisecond
+
+//A square of 2^level by 2^level cells in a quadtree. Squares holding the same cells
+//are always the same node, so the result of each square is only ever worked out once.
+type node struct {
+	level          int
+	nw, ne, sw, se *node
+	population     int
+}
+
+type resultKey struct {
+	n *node
+	j int
+}
+
+//universe holds every node built by hashlife, and the results worked out for them
+type universe struct {
+	rule    Rule
+	leaves  [2]*node
+	empty   []*node
+	nodes   map[[4]*node]*node
+	results map[resultKey]*node
+}
+
+func newUniverse(rule Rule) *universe {
+	dead := &node{}
+	return &universe{
+		rule:    rule,
+		leaves:  [2]*node{dead, {population: 1}},
+		empty:   []*node{dead},
+		nodes:   make(map[[4]*node]*node),
+		results: make(map[resultKey]*node),
+	}
+}
+
+//Returns the node made of four nodes of the level below
+func (u *universe) join(nw, ne, sw, se *node) *node {
+	key := [4]*node{nw, ne, sw, se}
+	if n, ok := u.nodes[key]; ok {
+		return n
+	}
+	n := &node{
+		level:      nw.level + 1,
+		nw:         nw,
+		ne:         ne,
+		sw:         sw,
+		se:         se,
+		population: nw.population + ne.population + sw.population + se.population,
+	}
+	u.nodes[key] = n
+	return n
+}
+
+func (u *universe) emptyNode(level int) *node {
+	for len(u.empty) <= level {
+		e := u.empty[len(u.empty)-1]
+		u.empty = append(u.empty, u.join(e, e, e, e))
+	}
+	return u.empty[level]
+}
+
+//Returns the square in the middle of a node, half its size
+func (u *universe) centre(n *node) *node {
+	return u.join(n.nw.se, n.ne.sw, n.sw.ne, n.se.nw)
+}
+
+//Returns the square straddling two nodes side by side, the size of one of them
+func (u *universe) centreHorizontal(w, e *node) *node {
+	return u.join(w.ne, e.nw, w.se, e.sw)
+}
+
+//Returns the square straddling two nodes one above the other, the size of one of them
+func (u *universe) centreVertical(n, s *node) *node {
+	return u.join(n.sw, n.se, s.nw, s.ne)
+}
+
+//Returns whether a cell of a node is alive
+func (n *node) alive(x, y int) bool {
+	for n.level > 0 {
+		half := 1 << uint(n.level-1)
+		switch {
+		case x < half && y < half:
+			n = n.nw
+		case y < half:
+			n, x = n.ne, x-half
+		case x < half:
+			n, y = n.sw, y-half
+		default:
+			n, x, y = n.se, x-half, y-half
+		}
+	}
+	return n.population == 1
+}
+
+//Executes one turn on a 4x4 node, returning its middle 2x2 square
+func (u *universe) base(n *node) *node {
+	next := [4]*node{}
+	for i, cell := range [4][2]int{{1, 1}, {2, 1}, {1, 2}, {2, 2}} {
+		neighbours := 0
+		for dy := -1; dy <= 1; dy++ {
+			for dx := -1; dx <= 1; dx++ {
+				if (dx != 0 || dy != 0) && n.alive(cell[0]+dx, cell[1]+dy) {
+					neighbours++
+				}
+			}
+		}
+		if u.rule.NextState(n.alive(cell[0], cell[1]), neighbours) {
+			next[i] = u.leaves[1]
+		} else {
+			next[i] = u.leaves[0]
+		}
+	}
+	return u.join(next[0], next[1], next[2], next[3])
+}
+
+//Returns the square in the middle of a node, half its size, after 2^j turns.
+//Cells outside the node cannot reach the middle in that time as long as j <= level-2.
+func (u *universe) successor(n *node, j int) *node {
+	//The population of a large tiling of a torus overflows, so empty nodes are found by identity
+	if n == u.emptyNode(n.level) {
+		return u.emptyNode(n.level - 1)
+	}
+	key := resultKey{n, j}
+	if r, ok := u.results[key]; ok {
+		return r
+	}
+	var r *node
+	if n.level == 2 {
+		r = u.base(n)
+	} else {
+		//Nine overlapping squares, each half the size of the node
+		squares := [9]*node{
+			n.nw, u.centreHorizontal(n.nw, n.ne), n.ne,
+			u.centreVertical(n.nw, n.sw), u.centre(n), u.centreVertical(n.ne, n.se),
+			n.sw, u.centreHorizontal(n.sw, n.se), n.se,
+		}
+		//At full speed both halves of the jump run 2^(j-1) turns, otherwise the first runs all 2^j
+		first := j
+		if j == n.level-2 {
+			first = j - 1
+		}
+		for i, s := range squares {
+			squares[i] = u.successor(s, first)
+		}
+		quarters := [4]*node{
+			u.join(squares[0], squares[1], squares[3], squares[4]),
+			u.join(squares[1], squares[2], squares[4], squares[5]),
+			u.join(squares[3], squares[4], squares[6], squares[7]),
+			u.join(squares[4], squares[5], squares[7], squares[8]),
+		}
+		for i, q := range quarters {
+			if j == n.level-2 {
+				quarters[i] = u.successor(q, j-1)
+			} else {
+				quarters[i] = u.centre(q)
+			}
+		}
+		r = u.join(quarters[0], quarters[1], quarters[2], quarters[3])
+	}
+	u.results[key] = r
+	return r
+}
+
+//Returns a node of the given level covered by copies of a torus, which is one node of a lower level
+func (u *universe) tile(torus *node, level int) *node {
+	if level == torus.level {
+		return torus
+	}
+	t := u.tile(torus, level-1)
+	return u.join(t, t, t, t)
+}
+
+//Returns a torus after 2^j turns. A torus behaves as an endless plane covered by copies of
+//itself, so the middle of a large enough square of copies holds the whole torus after the jump.
+func (u *universe) advance(torus *node, j int) *node {
+	level := j + 2
+	if level <= torus.level {
+		level = torus.level + 1
+	}
+	middle := u.successor(u.tile(torus, level), j)
+	if level == torus.level+1 {
+		//The middle starts half way across a copy, so its quarters are swapped around
+		return u.join(middle.se, middle.sw, middle.ne, middle.nw)
+	}
+	//The middle starts on a corner of a copy
+	for middle.level > torus.level {
+		middle = middle.nw
+	}
+	return middle
+}
+
+//Returns a node holding the cells of a square of the world
+func (u *universe) build(world [][]byte, x, y, level int) *node {
+	if level == 0 {
+		if world[y][x] == 255 {
+			return u.leaves[1]
+		}
+		return u.leaves[0]
+	}
+	half := 1 << uint(level-1)
+	return u.join(
+		u.build(world, x, y, level-1),
+		u.build(world, x+half, y, level-1),
+		u.build(world, x, y+half, level-1),
+		u.build(world, x+half, y+half, level-1))
+}
+
+//Returns a copy of a node from another universe, made in this one
+func (u *universe) copy(n *node, copied map[*node]*node) *node {
+	if n.level == 0 {
+		return u.leaves[n.population]
+	}
+	if c, ok := copied[n]; ok {
+		return c
+	}
+	c := u.join(u.copy(n.nw, copied), u.copy(n.ne, copied), u.copy(n.sw, copied), u.copy(n.se, copied))
+	copied[n] = c
+	return c
+}
+
+//Appends the alive cells of a node, whose top left corner is at (x, y)
+func (n *node) aliveCells(cells []util.Cell, x, y int) []util.Cell {
+	if n.population == 0 {
+		return cells
+	}
+	if n.level == 0 {
+		return append(cells, util.Cell{X: x, Y: y})
+	}
+	half := 1 << uint(n.level-1)
+	cells = n.nw.aliveCells(cells, x, y)
+	cells = n.ne.aliveCells(cells, x+half, y)
+	cells = n.sw.aliveCells(cells, x, y+half)
+	return n.se.aliveCells(cells, x+half, y+half)
+}
+
+//Sends a CellFlipped event for every cell that differs between two nodes. Squares
+//that did not change are the same node, so are skipped without looking inside.
+func sendNodeFlips(previous, next *node, x, y, turn int, c distributorChannels) {
+	if previous == next {
+		return
+	}
+	if next.level == 0 {
+		c.events <- CellFlipped{CompletedTurns: turn, Cell: util.Cell{X: x, Y: y}, Value: byte(255 * next.population)}
+		return
+	}
+	half := 1 << uint(next.level-1)
+	sendNodeFlips(previous.nw, next.nw, x, y, turn, c)
+	sendNodeFlips(previous.ne, next.ne, x+half, y, turn, c)
+	sendNodeFlips(previous.sw, next.sw, x, y+half, turn, c)
+	sendNodeFlips(previous.se, next.se, x+half, y+half, turn, c)
+}
+
+//Returns a node for the board after an edit, built again from its cells
+func editNode(p Params, u *universe, board *node, edit Edit) *node {
+	world := make([][]byte, p.ImageHeight)
+	for y := range world {
+		world[y] = make([]byte, p.ImageWidth)
+	}
+	for _, cell := range board.aliveCells(nil, 0, 0) {
+		world[cell.Y][cell.X] = 255
+	}
+	edit.applyTo(p, world)
+	return u.build(world, 0, 0, board.level)
+}
+
+//Steps through the history from a turn towards a target turn, as far as it reaches.
+//Returns the board and the turn reached.
+func seekNode(past *history, board *node, turn int, target int) (*node, int) {
+	for turn > target {
+		entry, ok := past.stepBack()
+		if !ok {
+			break
+		}
+		board, turn = entry.before, entry.from
+	}
+	for turn <= target {
+		entry, ok := past.stepForward(target)
+		if !ok {
+			break
+		}
+		board, turn = entry.after, entry.to
+	}
+	return board, turn
+}
+
+//Returns an error if the game cannot be run with the hashlife engine
+func checkHashlife(p Params) error {
+	switch {
+	case p.ImageWidth != p.ImageHeight || p.ImageWidth < 4 || bits.OnesCount(uint(p.ImageWidth)) != 1:
+		return errors.New(fmt.Sprintf("hashlife needs a square board with sides a power of 2 of at least 4, not %vx%v", p.ImageWidth, p.ImageHeight))
+	case p.Topology != Torus:
+		return errors.New(fmt.Sprintf("hashlife needs a torus, not a %v", p.Topology))
+	case p.Rule.States > 2:
+		return errors.New(fmt.Sprintf("hashlife cannot run the Generations rule %v", p.Rule))
+	case p.Stats != "":
+		return errors.New("hashlife jumps over turns, so cannot write the statistics of every turn")
+	}
+	return nil
+}
+
+//Runs the game with the hashlife engine, in place of the distributor and its workers.
+//Each jump runs the largest power of 2 turns that does not go past the last turn, or the
+//turn that a step or seek of the paused game runs to, and is at most one level larger
+//than the last jump. A jump that took longer than maxJumpTime is followed by a smaller one.
+func hashlifeDistributor(p Params, c distributorChannels) {
+	world, err := readWorld(p, c)
+	if err != nil {
+		c.events <- ErrorOccurred{0, err}
+		finish(c, 0)
+		return
+	}
+	u := newUniverse(p.Rule)
+	board := u.build(world, 0, 0, bits.Len(uint(p.ImageWidth))-1)
+	for _, cell := range board.aliveCells(nil, 0, 0) {
+		c.events <- CellFlipped{CompletedTurns: 0, Cell: cell, Value: 255}
+	}
+
+	turn := 0
+	isPaused := false
+	ticker := time.NewTicker(reportInterval(p))
+	telemetry := newTelemetryMeter(0, 0)
+	//Closed, so that a jump is always ready unless the game is paused
+	running := make(chan bool)
+	close(running)
+	past := newHistory(p.History)
+	//Turn that the game pauses on once it is reached, 0 if it is not to pause
+	stopAt := 0
+	//Level of the last jump, which 2^level turns were run by
+	level := 0
+	//Moves the paused game towards a turn, through the history and then by running to it
+	seek := func(target int) {
+		if target > p.Turns {
+			target = p.Turns
+		}
+		if target < 0 {
+			target = 0
+		}
+		next, reached := seekNode(past, board, turn, target)
+		sendNodeFlips(board, next, 0, 0, reached, c)
+		board, turn = next, reached
+		if reached < target {
+			isPaused = false
+			stopAt = target
+			c.events <- StateChange{turn, Executing}
+			return
+		}
+		if reached > target {
+			fmt.Println("Error: turn", target, "is no longer in the history, stopped at turn", reached)
+		}
+		c.events <- StateChange{turn, Paused}
+	}
+
+	for turn < p.Turns || isPaused {
+		jump := running
+		if isPaused {
+			jump = nil
+		}
+		select {
+		case <-ticker.C:
+			c.events <- AliveCellsCount{CompletedTurns: turn, CellsCount: board.population}
+			c.events <- telemetry.report(turn)
+		case k := <-c.keyPresses:
+			switch k {
+			case 'p':
+				isPaused = !isPaused
+				stopAt = 0
+				if isPaused {
+					c.events <- StateChange{turn, Paused}
+				} else {
+					c.events <- StateChange{turn, Executing}
+				}
+			case 'n':
+				if isPaused {
+					seek(turn + 1)
+				}
+			case 'b':
+				if !isPaused {
+					break
+				}
+				if entry, ok := past.stepBack(); ok {
+					sendNodeFlips(board, entry.before, 0, 0, entry.from, c)
+					board, turn = entry.before, entry.from
+				}
+				c.events <- StateChange{turn, Paused}
+			case 's':
+				outputImage(p, c, board.aliveCells(nil, 0, 0), nil, turn)
+			case 'q':
+				outputImage(p, c, board.aliveCells(nil, 0, 0), nil, turn)
+				ticker.Stop()
+				finish(c, turn)
+				return
+			}
+		case target := <-c.seeks:
+			if isPaused {
+				seek(target)
+			}
+		case edit := <-c.edits:
+			if isPaused {
+				next := editNode(p, u, board, edit)
+				sendNodeFlips(board, next, 0, 0, turn, c)
+				past.add(historyEntry{from: turn, to: turn, before: board, after: next})
+				board = next
+			}
+		case <-jump:
+			last := p.Turns
+			if stopAt != 0 {
+				last = stopAt
+			}
+			j := bits.Len(uint(last-turn)) - 1
+			if j > level+1 {
+				j = level + 1
+			}
+			start := time.Now()
+			next := u.advance(board, j)
+			level = j
+			if time.Since(start) > maxJumpTime && j > 0 {
+				level = j - 1
+			}
+			past.add(historyEntry{from: turn, to: turn + 1<<uint(j), before: board, after: next})
+			//The strip engine numbers each turn from 0 as it completes, so the jump is numbered
+			//as its last turn, with its changes sent before it like the changes of a turn
+			sendNodeFlips(board, next, 0, 0, turn+1<<uint(j)-1, c)
+			c.events <- TurnComplete{CompletedTurns: turn + 1<<uint(j) - 1}
+			turn += 1 << uint(j)
+			board = next
+			if turn == stopAt {
+				isPaused = true
+				stopAt = 0
+				c.events <- StateChange{turn, Paused}
+			}
+			if len(u.nodes)+len(u.results) > maxNodes {
+				u = newUniverse(p.Rule)
+				board = u.copy(board, make(map[*node]*node))
+				//The boards in the history would keep the old universe from being freed
+				past = newHistory(p.History)
+			}
+		}
+	}
+	ticker.Stop()
+
+	alive := board.aliveCells([]util.Cell{}, 0, 0)
+	c.events <- FinalTurnComplete{CompletedTurns: turn, Alive: alive}
+	outputImage(p, c, alive, nil, turn)
+	finish(c, turn)
+}
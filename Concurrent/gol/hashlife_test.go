@@ -0,0 +1,97 @@
+package gol
+
+import (
+	"fmt"
+	"math/bits"
+	"testing"
+	"time"
+)
+
+//Runs turns with the hashlife engine, jumping as hashlifeDistributor does
+func hashlifeTurns(p Params, world [][]byte, turns int) [][]byte {
+	u := newUniverse(p.Rule)
+	board := u.build(world, 0, 0, bits.Len(uint(p.ImageWidth))-1)
+	for turns > 0 {
+		j := bits.Len(uint(turns)) - 1
+		board = u.advance(board, j)
+		turns -= 1 << uint(j)
+	}
+	next := make([][]byte, p.ImageHeight)
+	for y := range next {
+		next[y] = make([]byte, p.ImageWidth)
+	}
+	for _, cell := range board.aliveCells(nil, 0, 0) {
+		next[cell.Y][cell.X] = 255
+	}
+	return next
+}
+
+func TestParseEngine(t *testing.T) {
+	for _, engine := range []Engine{StripEngine, HashlifeEngine} {
+		parsed, err := ParseEngine(engine.String())
+		if err != nil || parsed != engine {
+			t.Errorf("%v parsed as %v, %v", engine, parsed, err)
+		}
+	}
+	if _, err := ParseEngine("gpu"); err == nil {
+		t.Errorf("gpu should not parse")
+	}
+}
+
+// TestHashlife checks jumps of many sizes, some longer than the board is wide, against
+// the strip engine on random tori, so that cells wrapping around the edges are covered.
+func TestHashlife(t *testing.T) {
+	for _, rule := range []string{"B3/S23", "B36/S23"} {
+		for _, size := range []int{4, 16, 64} {
+			p := Params{ImageWidth: size, ImageHeight: size, Threads: 2, Rule: mustParseRule(t, rule)}
+			expected := randomWorld(p, 2)
+			done := 0
+			for _, turns := range []int{0, 1, 2, 3, 7, 16, 33, 100} {
+				for ; done < turns; done++ {
+					expected = step(p, expected)
+				}
+				name := fmt.Sprintf("%v on %vx%v after %v turns", p.Rule, size, size, turns)
+				assertEqualWorld(t, name, hashlifeTurns(p, randomWorld(p, 2), turns), expected)
+			}
+		}
+	}
+}
+
+// TestHashlifeLongRun runs a glider for 10^10 turns. It moves one cell diagonally every
+// 4 turns, so comes back to where it started every 256 turns on a 64x64 torus.
+func TestHashlifeLongRun(t *testing.T) {
+	p := Params{ImageWidth: 64, ImageHeight: 64}
+	start := time.Now()
+	world := hashlifeTurns(p, glider(p, 10, 20), 10000000000)
+	assertEqualWorld(t, "glider after 10000000000 turns", world, glider(p, 10, 20))
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("took %v", elapsed)
+	}
+}
+
+// TestHashlifeHugeJump jumps a glider on a 16x16 torus by 2^40 turns at once. The tiling
+// of the torus for such a jump holds more alive cells than an int can count.
+func TestHashlifeHugeJump(t *testing.T) {
+	p := Params{ImageWidth: 16, ImageHeight: 16}
+	world := hashlifeTurns(p, glider(p, 3, 5), 1<<40)
+	assertEqualWorld(t, "glider after 2^40 turns", world, glider(p, 3, 5))
+}
+
+func TestCheckHashlife(t *testing.T) {
+	tests := []struct {
+		p  Params
+		ok bool
+	}{
+		{Params{ImageWidth: 512, ImageHeight: 512}, true},
+		{Params{ImageWidth: 512, ImageHeight: 256}, false},
+		{Params{ImageWidth: 100, ImageHeight: 100}, false},
+		{Params{ImageWidth: 2, ImageHeight: 2}, false},
+		{Params{ImageWidth: 64, ImageHeight: 64, Topology: Plane}, false},
+		{Params{ImageWidth: 64, ImageHeight: 64, Rule: mustParseRule(t, "B2/S/C3")}, false},
+	}
+	for _, test := range tests {
+		if err := checkHashlife(test.p); (err == nil) != test.ok {
+			t.Errorf("%vx%v %v %v: got %v", test.p.ImageWidth, test.p.ImageHeight, test.p.Topology, test.p.Rule, err)
+		}
+	}
+}
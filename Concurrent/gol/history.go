@@ -0,0 +1,146 @@
+package gol
+
+import "uk.ac.bris.cs/gameoflife/util"
+
+//DefaultHistory is the number of turns and edits kept for stepping back through a paused game
+const DefaultHistory = 100
+
+//Most cell changes kept in a history, so that the turns of a large board do not fill memory.
+//The oldest entries are dropped to keep under it, though the latest entry is always kept.
+const maxHistoryChanges = 1 << 20
+
+//Change of a cell's gray level made by a turn or an edit
+type cellChange struct {
+	cell   util.Cell
+	before uint8
+	after  uint8
+}
+
+//Changes made going from one turn to a later one. An edit goes from a turn to itself.
+type historyEntry struct {
+	from    int
+	to      int
+	changes []cellChange
+	//The hashlife engine keeps its boards before and after instead of the changes, as
+	//they share all the squares that did not change
+	before *node
+	after  *node
+}
+
+//Sets the gray levels of the cells that stepping back over the entry changes
+func (e historyEntry) undo(levels map[util.Cell]uint8) {
+	for i := len(e.changes) - 1; i >= 0; i-- {
+		levels[e.changes[i].cell] = e.changes[i].before
+	}
+}
+
+//Sets the gray levels of the cells that stepping forward over the entry changes
+func (e historyEntry) redo(levels map[util.Cell]uint8) {
+	for _, change := range e.changes {
+		levels[change.cell] = change.after
+	}
+}
+
+//history is a ring buffer of the changes made by the latest turns and edits of a game, so
+//that a paused game can be stepped back through them, then forward again without running
+//the turns a second time
+type history struct {
+	entries []historyEntry
+	oldest  int
+	//Number of entries before the current turn, which can be stepped back over
+	back int
+	//Number of entries after the current turn, which were stepped back over
+	forward int
+	//Number of cell changes in the entries before the current turn
+	changes int
+}
+
+//Returns a history of the given number of entries, DefaultHistory if it is 0 and none if it
+//is negative
+func newHistory(size int) *history {
+	if size == 0 {
+		size = DefaultHistory
+	}
+	if size < 0 {
+		size = 0
+	}
+	return &history{entries: make([]historyEntry, size)}
+}
+
+//Adds the changes made after the current turn. The entries that were stepped back over
+//are dropped, as the game has gone another way. Once the history is full, each entry
+//added drops the oldest one.
+func (h *history) add(entry historyEntry) {
+	if len(h.entries) == 0 {
+		return
+	}
+	h.forward = 0
+	if h.back == len(h.entries) {
+		h.dropOldest()
+	}
+	for h.back > 0 && h.changes+len(entry.changes) > maxHistoryChanges {
+		h.dropOldest()
+	}
+	h.entries[(h.oldest+h.back)%len(h.entries)] = entry
+	h.back++
+	h.changes += len(entry.changes)
+}
+
+func (h *history) dropOldest() {
+	h.changes -= len(h.entries[h.oldest].changes)
+	h.entries[h.oldest] = historyEntry{}
+	h.oldest = (h.oldest + 1) % len(h.entries)
+	h.back--
+}
+
+//Steps back over the entry before the current turn, if there is one
+func (h *history) stepBack() (historyEntry, bool) {
+	if h.back == 0 {
+		return historyEntry{}, false
+	}
+	h.back--
+	h.forward++
+	entry := h.entries[(h.oldest+h.back)%len(h.entries)]
+	h.changes -= len(entry.changes)
+	return entry, true
+}
+
+//Steps forward over the entry after the current turn, if there is one and it does not go
+//past the target turn
+func (h *history) stepForward(target int) (historyEntry, bool) {
+	if h.forward == 0 {
+		return historyEntry{}, false
+	}
+	entry := h.entries[(h.oldest+h.back)%len(h.entries)]
+	if entry.to > target {
+		return historyEntry{}, false
+	}
+	h.back++
+	h.forward--
+	h.changes += len(entry.changes)
+	return entry, true
+}
+
+//Steps through the history from a turn towards a target turn, as far as it reaches.
+//Returns the gray levels of the cells that were changed on the way, and the turn reached.
+//Going forward, the edits made on the target turn are made again.
+func (h *history) seek(turn int, target int) (map[util.Cell]uint8, int) {
+	levels := map[util.Cell]uint8{}
+	for turn > target {
+		entry, ok := h.stepBack()
+		if !ok {
+			break
+		}
+		entry.undo(levels)
+		turn = entry.from
+	}
+	for turn <= target {
+		entry, ok := h.stepForward(target)
+		if !ok {
+			break
+		}
+		entry.redo(levels)
+		turn = entry.to
+	}
+	return levels, turn
+}
@@ -0,0 +1,176 @@
+package gol
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+//Returns an entry for a turn that makes a single cell alive
+func turnEntry(turn int) historyEntry {
+	return historyEntry{from: turn, to: turn + 1, changes: []cellChange{{cell: util.Cell{X: turn}, before: 0, after: 255}}}
+}
+
+// TestHistory checks that the history steps back and forward through its entries, drops the
+// oldest once it is full, and drops the entries stepped back over once another is added.
+func TestHistory(t *testing.T) {
+	h := newHistory(3)
+	for turn := 0; turn < 5; turn++ {
+		h.add(turnEntry(turn))
+	}
+
+	levels, turn := h.seek(5, 0)
+	if turn != 2 {
+		t.Errorf("seeking turn 0 reached turn %v, expected 2 as turns 0 and 1 were dropped", turn)
+	}
+	if len(levels) != 3 || levels[util.Cell{X: 2}] != 0 || levels[util.Cell{X: 4}] != 0 {
+		t.Errorf("seeking turn 0 set %v, expected cells 2 to 4 dead", levels)
+	}
+
+	levels, turn = h.seek(2, 3)
+	if turn != 3 || len(levels) != 1 || levels[util.Cell{X: 2}] != 255 {
+		t.Errorf("seeking turn 3 reached turn %v and set %v, expected turn 3 with cell 2 alive", turn, levels)
+	}
+
+	//An edit on turn 3 replaces the turns after it
+	h.add(historyEntry{from: 3, to: 3})
+	if _, ok := h.stepForward(10); ok {
+		t.Error("stepped forward over a turn that an edit replaced")
+	}
+	if _, turn = h.seek(3, 0); turn != 2 {
+		t.Errorf("seeking turn 0 after the edit reached turn %v, expected 2", turn)
+	}
+
+	none := newHistory(-1)
+	none.add(turnEntry(0))
+	if _, ok := none.stepBack(); ok {
+		t.Error("stepped back through a history that keeps nothing")
+	}
+}
+
+//Returns the alive cells of a glider after a number of turns, on a torus of the given size
+func gliderCells(size int, turns int) map[util.Cell]bool {
+	p := Params{ImageWidth: size, ImageHeight: size, Rule: Conway}
+	world := glider(p, 0, 0)
+	//The glider moves one cell diagonally every 4 turns, so comes back after 4 times the size
+	for turn := 0; turn < turns%(4*size); turn++ {
+		world = referenceStep(p, world)
+	}
+	cells := map[util.Cell]bool{}
+	for y := range world {
+		for x := range world[y] {
+			if world[y][x] == 255 {
+				cells[util.Cell{X: x, Y: y}] = true
+			}
+		}
+	}
+	return cells
+}
+
+// TestStepBack pauses a glider with each engine, then seeks, steps forward and steps back
+// through its turns, checking that the board drawn after each move is the glider on that turn
+func TestStepBack(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	input := filepath.Join(dir, "glider.cells")
+	if err := ioutil.WriteFile(input, []byte(".O.\n..O\nOOO\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, engine := range []Engine{StripEngine, HashlifeEngine, SparseEngine} {
+		p := Params{
+			Turns:       100,
+			Threads:     4,
+			ImageWidth:  16,
+			ImageHeight: 16,
+			Rule:        Conway,
+			Engine:      engine,
+			Input:       input,
+			OutputDir:   dir,
+		}
+		size := 16
+		if engine == HashlifeEngine {
+			//Each power of 2 is a separate jump, leaving time for the pause to be taken
+			p.Turns = 1<<40 - 1
+		}
+		if engine == SparseEngine {
+			//The plane has no edges, so the glider is checked on a torus it does not go around
+			size = 128
+		}
+		events := make(chan Event)
+		keyPresses := make(chan rune, 10)
+		seeks := make(chan int, 10)
+		keyPresses <- 'p'
+		go RunInteractive(p, events, keyPresses, nil, seeks)
+
+		moves := []struct {
+			name string
+			move func()
+			turn int
+		}{
+			{"seek 0", func() { seeks <- 0 }, 0},
+			{"step", func() { keyPresses <- 'n' }, 1},
+			{"step", func() { keyPresses <- 'n' }, 2},
+			{"step", func() { keyPresses <- 'n' }, 3},
+			{"step back", func() { keyPresses <- 'b' }, 2},
+			{"seek 10", func() { seeks <- 10 }, 10},
+			{"seek 7", func() { seeks <- 7 }, 7},
+		}
+		moved := 0
+		drawn := map[util.Cell]bool{}
+		var final FinalTurnComplete
+		for event := range events {
+			switch e := event.(type) {
+			case CellFlipped:
+				if e.Value == 255 {
+					drawn[e.Cell] = true
+				} else {
+					delete(drawn, e.Cell)
+				}
+			case StateChange:
+				if e.NewState != Paused {
+					break
+				}
+				name := "pause"
+				if moved > 0 {
+					name = moves[moved-1].name
+					if e.CompletedTurns != moves[moved-1].turn {
+						t.Errorf("%v: %v reached turn %v, expected %v", engine, name, e.CompletedTurns, moves[moved-1].turn)
+					}
+				}
+				if expected := gliderCells(size, e.CompletedTurns); !sameCells(cellList(drawn), cellList(expected)) {
+					t.Errorf("%v: after %v, drawn %v on turn %v, expected %v", engine, name, cellList(drawn), e.CompletedTurns, cellList(expected))
+				}
+				if moved == len(moves) {
+					keyPresses <- 'p'
+				} else {
+					moves[moved].move()
+				}
+				moved++
+			case FinalTurnComplete:
+				final = e
+			}
+		}
+
+		if moved <= len(moves) {
+			t.Errorf("%v: the game finished after %v of %v moves", engine, moved, len(moves))
+		}
+		if expected := gliderCells(size, p.Turns); !sameCells(final.Alive, cellList(expected)) {
+			t.Errorf("%v: final board is %v, expected %v", engine, final.Alive, cellList(expected))
+		}
+	}
+}
+
+func cellList(cells map[util.Cell]bool) []util.Cell {
+	list := []util.Cell{}
+	for cell := range cells {
+		list = append(list, cell)
+	}
+	return list
+}
@@ -0,0 +1,348 @@
+package gol
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+func TestParseFormat(t *testing.T) {
+	for _, format := range []Format{PGM, RLE, Cells, PNG} {
+		parsed, err := ParseFormat(format.String())
+		if err != nil || parsed != format {
+			t.Errorf("%v parsed as %v, %v", format, parsed, err)
+		}
+	}
+	if parsed, err := ParseFormat(".RLE"); err != nil || parsed != RLE {
+		t.Errorf(".RLE parsed as %v, %v", parsed, err)
+	}
+	if _, err := ParseFormat("gif"); err == nil {
+		t.Errorf("gif should not parse")
+	}
+}
+
+// TestParseRLE reads a glider as LifeWiki writes it, and a Brian's Brain pattern in multistate letters.
+func TestParseRLE(t *testing.T) {
+	states, rule, err := parseRLE("#N Glider\r\n#O Richard K. Guy\r\nx = 3, y = 3, rule = B3/S23\r\nbob$2bo$3o!\r\n")
+	if err != nil || rule != "B3/S23" {
+		t.Fatalf("glider: %v, rule %q", err, rule)
+	}
+	assertEqualWorld(t, "glider", mustLevels(t, states, Conway), makeWorld(".#.", "..#", "###"))
+
+	states, rule, err = parseRLE("x = 4, y = 3, rule = B2/S/C3\n.AB2$\n3A!")
+	if err != nil || rule != "B2/S/C3" {
+		t.Fatalf("multistate: %v, rule %q", err, rule)
+	}
+	expected := [][]int{{0, 1, 2, 0}, {0, 0, 0, 0}, {1, 1, 1, 0}}
+	for y := range expected {
+		for x := range expected[y] {
+			if states[y][x] != expected[y][x] {
+				t.Errorf("multistate: cell (%v, %v) is %v, expected %v", x, y, states[y][x], expected[y][x])
+			}
+		}
+	}
+
+	states, _, err = parseRLE("x = 4, y = 1\npA2pBX!")
+	if err != nil {
+		t.Fatalf("prefixed states: %v", err)
+	}
+	for x, state := range []int{25, 26, 26, 24} {
+		if states[0][x] != state {
+			t.Errorf("prefixed states: cell (%v, 0) is %v, expected %v", x, states[0][x], state)
+		}
+	}
+
+	//Letters other than b, o and the multistate ones are not cells, and a prefix needs a letter after it
+	for _, bad := range []string{"", "x = 2\nbo!", "x = 2, y = 1\n3o!", "x = 2, y = 1\nbo", "x = 2, y = 1\nb?!",
+		"x = 2, y = 1\nbx!", "x = 2, y = 1\nza!", "x = 2, y = 1\npb!", "x = 2, y = 1\np2A!", "x = 2, y = 1\nzA!"} {
+		if _, _, err := parseRLE(bad); err == nil {
+			t.Errorf("%q should not parse", bad)
+		}
+	}
+}
+
+func TestParseCells(t *testing.T) {
+	states, err := parseCells("!Name: Glider\n!\n.O\n..O\nOOO\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqualWorld(t, "glider", mustLevels(t, states, Conway), makeWorld(".#.", "..#", "###"))
+	if _, err := parseCells(".O\nxx\n"); err == nil {
+		t.Errorf("x should not be a cell")
+	}
+}
+
+// TestPatternRoundTrip writes random worlds in every format and reads them back.
+// Cells cannot hold dying states, so only the life-like world goes through it.
+func TestPatternRoundTrip(t *testing.T) {
+	brain := mustParseRule(t, "B2/S/C3")
+	//States after X are written with a prefix, from pA for 25
+	long := mustParseRule(t, "B2/S/C60")
+	prefixed := randomWorld(Params{ImageWidth: 100, ImageHeight: 40}, 6)
+	for y := range prefixed {
+		for x := range prefixed[y] {
+			if prefixed[y][x] == 0 {
+				prefixed[y][x] = long.Level((x + y) % 60)
+			}
+		}
+	}
+	life := randomWorld(Params{ImageWidth: 100, ImageHeight: 40}, 4)
+	dying := randomWorld(Params{ImageWidth: 100, ImageHeight: 40}, 5)
+	for y := range dying {
+		for x := range dying[y] {
+			if x%3 == 0 && dying[y][x] == 0 {
+				dying[y][x] = brain.Level(2)
+			}
+		}
+		//Empty rows, and dead cells at the end of rows, are left out of the rle
+		if y%7 == 0 {
+			dying[y] = make([]byte, 100)
+		}
+		copy(dying[y][90:], make([]byte, 10))
+	}
+
+	tests := []struct {
+		name   string
+		format Format
+		world  [][]byte
+		rule   Rule
+	}{
+		{"pgm", PGM, dying, brain},
+		{"png", PNG, dying, brain},
+		{"life rle", RLE, life, Conway},
+		{"generations rle", RLE, dying, brain},
+		{"prefixed rle", RLE, prefixed, long},
+		{"cells", Cells, life, Conway},
+	}
+	dir, err := ioutil.TempDir("", "gol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, test := range tests {
+		var buffer bytes.Buffer
+		switch test.format {
+		case RLE:
+			err = writeRLE(&buffer, test.world, test.rule)
+			for _, line := range strings.Split(buffer.String(), "\n") {
+				if len(line) > 70 {
+					t.Errorf("%v: line %q is longer than 70 characters", test.name, line)
+				}
+			}
+		case Cells:
+			err = writeCells(&buffer, test.world, test.name)
+		case PNG:
+			err = writePng(&buffer, test.world)
+		default:
+			err = writePgm(&buffer, test.world)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, "pattern."+test.format.String())
+		if err := ioutil.WriteFile(path, buffer.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+		world, err := readPattern(path, test.rule, DefaultThreshold)
+		if err != nil {
+			t.Fatalf("%v: %v", test.name, err)
+		}
+		if test.format == RLE {
+			//The rle leaves out the dead cells at the end, but its header keeps the size
+			if len(world) != len(test.world) || len(world[0]) != len(test.world[0]) {
+				t.Fatalf("%v: read as %vx%v", test.name, len(world[0]), len(world))
+			}
+			if rule, ok := PatternRule(path); !ok || rule != test.rule {
+				t.Errorf("%v: rule read as %v, %v", test.name, rule, ok)
+			}
+		}
+		assertEqualWorld(t, test.name, world, test.world)
+	}
+}
+
+func TestPlacePattern(t *testing.T) {
+	world, err := placePattern(makeWorld(".#", "##"), 4, 3, 2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqualWorld(t, "placed", world, makeWorld("....", "...#", "..##"))
+	for _, offset := range [][2]int{{3, 0}, {0, 2}, {-1, 0}} {
+		if _, err := placePattern(makeWorld(".#", "##"), 4, 3, offset[0], offset[1]); err == nil {
+			t.Errorf("a 2x2 pattern should not fit on a 4x3 board at %v", offset)
+		}
+	}
+}
+
+func mustLevels(t *testing.T, states [][]int, rule Rule) [][]byte {
+	world, err := statesToLevels(states, rule)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return world
+}
+
+func TestBoardSize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"glider.rle":   "#N Glider\nx = 3, y = 4\nbo$2bo$3o!\n",
+		"glider.cells": "!Name: Glider\n.O\n..O\nOOO\n",
+		"board.pgm":    "P5\n5 2\n255\n" + string(make([]byte, 10)),
+	}
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var buffer bytes.Buffer
+	if err := writePng(&buffer, makeWorld("...", "...", "#..", "...")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "board.png"), buffer.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path          string
+		width, height int
+	}{
+		{"", 512, 512},
+		{filepath.Join(dir, "glider.rle"), 13, 24},
+		{filepath.Join(dir, "glider.cells"), 13, 23},
+		{filepath.Join(dir, "board.pgm"), 15, 22},
+		{filepath.Join(dir, "board.png"), 13, 24},
+	}
+	for _, test := range tests {
+		offset := 0
+		if test.path != "" {
+			offset = 10
+		}
+		width, height, err := BoardSize(test.path, offset, offset+10)
+		if err != nil || width != test.width || height != test.height {
+			t.Errorf("%q: %vx%v, %v, expected %vx%v", test.path, width, height, err, test.width, test.height)
+		}
+	}
+	if _, _, err := BoardSize(filepath.Join(dir, "missing.rle"), 0, 0); err == nil {
+		t.Errorf("a missing file should not have a size")
+	}
+}
+
+func TestOutputName(t *testing.T) {
+	p := Params{ImageWidth: 64, ImageHeight: 32, Rule: mustParseRule(t, "B36/S23"), Input: "patterns/acorn.rle"}
+	tests := []struct {
+		template string
+		expected string
+	}{
+		{"", "64x32x100"},
+		{"{input}-{rule}-{turns}", "acorn-B36_S23-100"},
+		{"run/{width}_{height}_{x}_{y}", "run/64_32_-3_4"},
+	}
+	for _, test := range tests {
+		if name := outputName(test.template, p, 64, 32, -3, 4, 100); name != test.expected {
+			t.Errorf("%q: got %q, expected %q", test.template, name, test.expected)
+		}
+	}
+	p.Input = ""
+	if name := outputName("{input}", p, 64, 32, 0, 0, 0); name != "64x32" {
+		t.Errorf("input of the default image named %q", name)
+	}
+}
+
+// TestNetpbm reads the same 3x2 board from each kind of netpbm file, with comments in the
+// header and pixels that look like whitespace, scaling any maxval and applying the threshold.
+func TestNetpbm(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"plain.pbm":  "P1\n# a comment\n3 2\n0 1 0\n1\n# between pixels\n01\n",
+		"plain.pgm":  "P2 3 2 # maxval next\n1000\n0 999 10\n600 400 1000\n",
+		"binary.pbm": "P4\n3 2\n" + string([]byte{0x40, 0xa0}),
+		"binary.pgm": "P5\n#\n3 #\n2\n32\n" + string([]byte{9, 32, 10, 32, 13, 32}),
+		"deep.pnm":   "P5 3 2 65535\n" + string([]byte{0, 0, 255, 255, 0, 1, 128, 0, 0, 0, 200, 0}),
+	}
+	expected := makeWorld(".#.", "#.#")
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		world, err := readPattern(path, Conway, DefaultThreshold)
+		if err != nil {
+			t.Errorf("%v: %v", name, err)
+			continue
+		}
+		assertEqualWorld(t, name, world, expected)
+	}
+}
+
+// TestNetpbmErrors checks that each broken image gives an InputError holding the right type of error
+func TestNetpbmErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	tests := []struct {
+		data  string
+		check func(error) bool
+	}{
+		{"P6\n1 1\n255\n\x00\x00\x00", func(err error) bool { _, ok := err.(util.FormatError); return ok }},
+		{"P5\n# no size\n", func(err error) bool { e, ok := err.(util.HeaderError); return ok && e.Field == "width" }},
+		{"P2\n3 -2\n255\n", func(err error) bool { e, ok := err.(util.HeaderError); return ok && e.Field == "height" }},
+		{"P5\n1 1\n0\n\x00", func(err error) bool { e, ok := err.(util.HeaderError); return ok && e.Field == "maxval" }},
+		{"P5\n2 2\n255\n\x00\x00\x00", func(err error) bool { e, ok := err.(util.PixelError); return ok && e.X == 1 && e.Y == 1 }},
+		{"P5\n2 1\n100\n\x00\x65", func(err error) bool { e, ok := err.(util.PixelError); return ok && e.X == 1 && e.Y == 0 }},
+		{"P2\n2 1\n255\n0 x", func(err error) bool { e, ok := err.(util.PixelError); return ok && e.X == 1 }},
+		{"P1\n2 1\n0 2", func(err error) bool { _, ok := err.(util.PixelError); return ok }},
+	}
+	path := filepath.Join(dir, "broken.pgm")
+	for _, test := range tests {
+		if err := ioutil.WriteFile(path, []byte(test.data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := readPattern(path, Conway, DefaultThreshold)
+		inputError, ok := err.(InputError)
+		if !ok || inputError.Path != path || !test.check(inputError.Err) {
+			t.Errorf("%q: got %#v", test.data, err)
+		}
+	}
+}
+
+// TestInputError checks that an input that cannot be read ends the game with an ErrorOccurred event
+func TestInputError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "broken.pgm")
+	if err := ioutil.WriteFile(path, []byte("P5\n4 4\n255\n\x00"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p := Params{Turns: 10, Threads: 1, ImageWidth: 4, ImageHeight: 4, Input: path, Rule: Conway, OutputDir: dir}
+	events := make(chan Event)
+	go Run(p, events, nil)
+	errorOccurred := false
+	for event := range events {
+		if e, ok := event.(ErrorOccurred); ok {
+			inputError, ok := e.Err.(InputError)
+			if !ok || inputError.Path != path {
+				t.Errorf("got the error %#v", e.Err)
+			}
+			errorOccurred = true
+		}
+	}
+	if !errorOccurred {
+		t.Error("no ErrorOccurred event was sent")
+	}
+}
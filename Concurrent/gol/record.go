@@ -0,0 +1,112 @@
+package gol
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"os"
+	"path/filepath"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+//Hundredths of a second that each frame of a recording is shown for
+const recordDelay = 5
+
+//Gray levels from black to white, so that the dying states of Generations rules are recorded
+var grayPalette = func() color.Palette {
+	palette := make(color.Palette, 256)
+	for i := range palette {
+		palette[i] = color.Gray{Y: uint8(i)}
+	}
+	return palette
+}()
+
+//Passes the events on, keeping a copy of the board up to date with the flipped cells.
+//After every RecordEvery turns the copy is sent to the io goroutine, which adds it to the
+//recording, so that encoding does not hold up the workers. Once the game is over and the
+//recording has been saved, the events channel is closed.
+func record(p Params, in <-chan Event, out chan<- Event, frames chan<- [][]byte, saved <-chan bool) {
+	//The sparse engine records its region of the plane, the other engines the whole board
+	region := Region{Width: p.ImageWidth, Height: p.ImageHeight}
+	if p.Engine == SparseEngine && p.Region != (Region{}) {
+		region = p.Region
+	}
+	every := p.RecordEvery
+	if every < 1 {
+		every = 1
+	}
+	board := make([][]byte, region.Height)
+	for i := range board {
+		board[i] = make([]byte, region.Width)
+	}
+
+	turns := 0
+	for event := range in {
+		switch e := event.(type) {
+		case CellFlipped:
+			if region.contains(e.Cell) {
+				board[e.Cell.Y-region.Y][e.Cell.X-region.X] = e.Value
+			}
+		case TurnComplete:
+			if turns%every == 0 {
+				frame := make([][]byte, len(board))
+				for i := range board {
+					frame[i] = append([]byte{}, board[i]...)
+				}
+				frames <- frame
+			}
+			turns++
+		}
+		out <- event
+	}
+	close(frames)
+	<-saved
+	close(out)
+}
+
+//Adds a frame of gray levels to the recording, drawing each cell as a square of RecordScale pixels
+func (io *ioState) addFrame(frame [][]byte) {
+	scale := io.params.RecordScale
+	if scale < 1 {
+		scale = 1
+	}
+	width := 0
+	if len(frame) > 0 {
+		width = len(frame[0])
+	}
+	img := image.NewPaletted(image.Rect(0, 0, width*scale, len(frame)*scale), grayPalette)
+	for y, row := range frame {
+		for x, level := range row {
+			for dy := 0; dy < scale; dy++ {
+				start := (y*scale+dy)*img.Stride + x*scale
+				for i := start; i < start+scale; i++ {
+					img.Pix[i] = level
+				}
+			}
+		}
+	}
+	io.recording.Image = append(io.recording.Image, img)
+	io.recording.Delay = append(io.recording.Delay, recordDelay)
+}
+
+//Writes the recording to the gif file named by the params
+func (io *ioState) saveRecording() {
+	if len(io.recording.Image) == 0 {
+		fmt.Println("No turns were recorded to", io.params.Record)
+		return
+	}
+	_ = os.MkdirAll(filepath.Dir(io.params.Record), os.ModePerm)
+	file, ioError := os.Create(io.params.Record)
+	util.Check(ioError)
+	defer file.Close()
+
+	ioError = gif.EncodeAll(file, &io.recording)
+	util.Check(ioError)
+
+	ioError = file.Sync()
+	util.Check(ioError)
+
+	fmt.Println("File", io.params.Record, "recording done!", len(io.recording.Image), "frames")
+}
@@ -0,0 +1,82 @@
+package gol
+
+import (
+	"image/gif"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestRecord records a glider with each engine, checking every frame of the gif against the sparse engine
+func TestRecord(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	input := filepath.Join(dir, "glider.cells")
+	if err := ioutil.WriteFile(input, []byte(".O\n..O\nOOO\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, engine := range []Engine{StripEngine, SparseEngine} {
+		path := filepath.Join(dir, engine.String(), "glider.gif")
+		p := Params{
+			Turns:       8,
+			Threads:     2,
+			ImageWidth:  8,
+			ImageHeight: 8,
+			Rule:        Conway,
+			Engine:      engine,
+			Input:       input,
+			OffsetX:     1,
+			OffsetY:     1,
+			OutputDir:   dir,
+			Record:      path,
+			RecordEvery: 3,
+			RecordScale: 2,
+		}
+		events := make(chan Event)
+		go Run(p, events, nil)
+		for range events {
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		recording, err := gif.DecodeAll(file)
+		file.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		//Frames are taken after turns 1, 4 and 7
+		if len(recording.Image) != 3 {
+			t.Fatalf("%v: recorded %v frames, expected 3", engine, len(recording.Image))
+		}
+		board := sparseBoard{{X: 2, Y: 1}: {}, {X: 3, Y: 2}: {}, {X: 1, Y: 3}: {}, {X: 2, Y: 3}: {}, {X: 3, Y: 3}: {}}
+		for i, frame := range recording.Image {
+			steps := 3
+			if i == 0 {
+				steps = 1
+			}
+			for turn := 0; turn < steps; turn++ {
+				board, _ = board.step(Conway)
+			}
+			if frame.Bounds().Dx() != 16 || frame.Bounds().Dy() != 16 {
+				t.Fatalf("%v: frame %v is %v", engine, i, frame.Bounds())
+			}
+			for y := 0; y < 16; y++ {
+				for x := 0; x < 16; x++ {
+					_, alive := board[util.Cell{X: x / 2, Y: y / 2}]
+					if (frame.ColorIndexAt(x, y) == 255) != alive {
+						t.Fatalf("%v: frame %v has pixel (%v, %v) wrong", engine, i, x, y)
+					}
+				}
+			}
+		}
+	}
+}
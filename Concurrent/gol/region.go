@@ -0,0 +1,84 @@
+package gol
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+//Region is a rectangle of the plane, with its top left corner at (X, Y).
+//The zero Region stands for the smallest rectangle holding every alive cell.
+type Region struct {
+	X      int
+	Y      int
+	Width  int
+	Height int
+}
+
+//ParseRegion reads a region written as x,y,width,height, such as -100,-100,200,200
+func ParseRegion(s string) (Region, error) {
+	fields := strings.Split(s, ",")
+	if len(fields) != 4 {
+		return Region{}, errors.New(fmt.Sprintf("region %q must be x,y,width,height", s))
+	}
+	var values [4]int
+	for i, field := range fields {
+		value, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			return Region{}, errors.New(fmt.Sprintf("region %q must be x,y,width,height", s))
+		}
+		values[i] = value
+	}
+	if values[2] < 0 || values[3] < 0 {
+		return Region{}, errors.New(fmt.Sprintf("region %q cannot have a negative size", s))
+	}
+	return Region{values[0], values[1], values[2], values[3]}, nil
+}
+
+func (r Region) String() string {
+	if r == (Region{}) {
+		return "bounding box"
+	}
+	return fmt.Sprintf("%v,%v,%v,%v", r.X, r.Y, r.Width, r.Height)
+}
+
+//Set parses a region given on the command line, so that a Region can be used with flag.Var
+func (r *Region) Set(s string) error {
+	region, err := ParseRegion(s)
+	if err != nil {
+		return err
+	}
+	*r = region
+	return nil
+}
+
+//Returns whether a cell lies inside the region
+func (r Region) contains(cell util.Cell) bool {
+	return cell.X >= r.X && cell.X < r.X+r.Width && cell.Y >= r.Y && cell.Y < r.Y+r.Height
+}
+
+//Returns the smallest region holding every cell, or the zero Region if there are none
+func boundingBox(cells []util.Cell) Region {
+	if len(cells) == 0 {
+		return Region{}
+	}
+	minX, minY, maxX, maxY := cells[0].X, cells[0].Y, cells[0].X, cells[0].Y
+	for _, cell := range cells[1:] {
+		if cell.X < minX {
+			minX = cell.X
+		}
+		if cell.X > maxX {
+			maxX = cell.X
+		}
+		if cell.Y < minY {
+			minY = cell.Y
+		}
+		if cell.Y > maxY {
+			maxY = cell.Y
+		}
+	}
+	return Region{minX, minY, maxX - minX + 1, maxY - minY + 1}
+}
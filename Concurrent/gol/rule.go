@@ -0,0 +1,168 @@
+package gol
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+//Rule decides which cells are born and which survive, from their number of alive
+//neighbours. Bit n of Birth is set if a dead cell with n alive neighbours comes
+//alive, and bit n of Survival if an alive cell with n alive neighbours stays alive.
+//The zero Rule is Conway's Game of Life, B3/S23.
+type Rule struct {
+	Birth    uint16
+	Survival uint16
+	//Number of states of a Generations rule, counting dead and alive. Cells that do
+	//not survive fade through the states in between before they die. 0 for a life-like rule.
+	States int
+}
+
+//Conway is the rule of Conway's Game of Life
+var Conway = Rule{Birth: 1 << 3, Survival: 1<<2 | 1<<3}
+
+//ParseRule reads a rule in B/S notation, such as B3/S23 for Conway's Game of Life,
+//B36/S23 for HighLife or B2/S for Seeds. The S/B order, S23/B3, is accepted too.
+//Generations rules give their number of states last, e.g. B2/S/C3 or B2/S/3 for Brian's Brain.
+func ParseRule(s string) (Rule, error) {
+	rule := Rule{}
+	parts := strings.Split(strings.ToUpper(strings.TrimSpace(s)), "/")
+	if len(parts) == 3 {
+		states, err := strconv.Atoi(strings.TrimPrefix(parts[2], "C"))
+		if err != nil || states < 2 || states > 256 {
+			return rule, errors.New(fmt.Sprintf("rule %q does not have between 2 and 256 states", s))
+		}
+		if states > 2 {
+			rule.States = states
+		}
+		parts = parts[:2]
+	}
+	if len(parts) != 2 {
+		return rule, errors.New(fmt.Sprintf("rule %q is not of the form B3/S23", s))
+	}
+	seen := map[byte]bool{}
+	for _, part := range parts {
+		if len(part) == 0 || (part[0] != 'B' && part[0] != 'S') || seen[part[0]] {
+			return rule, errors.New(fmt.Sprintf("rule %q is not of the form B3/S23", s))
+		}
+		seen[part[0]] = true
+		counts := uint16(0)
+		for _, digit := range part[1:] {
+			if digit < '0' || digit > '8' {
+				return rule, errors.New(fmt.Sprintf("rule %q has a neighbour count that is not between 0 and 8", s))
+			}
+			counts |= 1 << uint(digit-'0')
+		}
+		if part[0] == 'B' {
+			rule.Birth = counts
+		} else {
+			rule.Survival = counts
+		}
+	}
+	if rule.Birth == 0 && rule.Survival == 0 {
+		return rule, errors.New(fmt.Sprintf("rule %q kills every cell", s))
+	}
+	return rule, nil
+}
+
+//NextState decides whether a cell is alive on the next turn
+func (r Rule) NextState(alive bool, neighbours int) bool {
+	if r == (Rule{}) {
+		r = Conway
+	}
+	if alive {
+		return r.Survival&(1<<uint(neighbours)) != 0
+	}
+	return r.Birth&(1<<uint(neighbours)) != 0
+}
+
+//Next returns the gray level of a cell on the next turn, from its gray level
+//and its number of alive neighbours. Only alive cells count as neighbours.
+func (r Rule) Next(level byte, neighbours int) byte {
+	switch level {
+	case 255:
+		if r.NextState(true, neighbours) {
+			return 255
+		}
+		if r.States > 2 {
+			return r.Level(2)
+		}
+		return 0
+	case 0:
+		if r.NextState(false, neighbours) {
+			return 255
+		}
+		return 0
+	}
+	state := r.State(level) + 1
+	if state < 2 || state >= r.States {
+		return 0
+	}
+	return r.Level(state)
+}
+
+//Level returns the gray level that a state is stored and drawn as. State 0 is dead
+//and state 1 is alive. The dying states after those fade from white to black.
+func (r Rule) Level(state int) byte {
+	switch state {
+	case 0:
+		return 0
+	case 1:
+		return 255
+	}
+	return byte(255 * (r.States - state) / (r.States - 1))
+}
+
+//State returns the state stored as a gray level. Levels that the rule does
+//not use are read as the nearest state, so any grayscale image can be loaded.
+func (r Rule) State(level byte) int {
+	if level == 255 {
+		return 1
+	}
+	nearest := 0
+	distance := int(level)
+	for state := 2; state < r.States; state++ {
+		d := int(r.Level(state)) - int(level)
+		if d < 0 {
+			d = -d
+		}
+		if d < distance {
+			nearest = state
+			distance = d
+		}
+	}
+	return nearest
+}
+
+func (r Rule) String() string {
+	if r == (Rule{}) {
+		r = Conway
+	}
+	s := "B"
+	for n := 0; n <= 8; n++ {
+		if r.Birth&(1<<uint(n)) != 0 {
+			s += fmt.Sprint(n)
+		}
+	}
+	s += "/S"
+	for n := 0; n <= 8; n++ {
+		if r.Survival&(1<<uint(n)) != 0 {
+			s += fmt.Sprint(n)
+		}
+	}
+	if r.States > 2 {
+		s += fmt.Sprintf("/C%v", r.States)
+	}
+	return s
+}
+
+//Set parses a rule given on the command line, so that a Rule can be used with flag.Var
+func (r *Rule) Set(s string) error {
+	rule, err := ParseRule(s)
+	if err != nil {
+		return err
+	}
+	*r = rule
+	return nil
+}
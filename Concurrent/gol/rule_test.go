@@ -0,0 +1,205 @@
+package gol
+
+import (
+	"math/rand"
+	"testing"
+)
+
+//Executes one turn on a whole world, split between p.Threads workers that
+//exchange their edges through the distributor, or a single worker by default
+func step(p Params, world [][]byte) [][]byte {
+	if p.Threads == 0 {
+		p.Threads = 1
+	}
+	events := make(chan Event, p.ImageWidth*p.ImageHeight)
+	boards := make([]bitBoard, p.Threads)
+	edges := make([]filler, p.Threads)
+	for t := range edges {
+		startY, endY := stripBounds(p, t)
+		boards[t] = packBoard(world[startY:endY], p.ImageWidth, p.Rule)
+		edges[t] = stripEdges(boards[t], t)
+	}
+	next := [][]byte{}
+	for t, halo := range buildHalos(p, edges) {
+		startY, endY := stripBounds(p, t)
+		wp := workerParams{StartY: startY, EndY: endY, ImageWidth: p.ImageWidth, ImageHeight: endY - startY, Turns: p.Turns, Rule: p.Rule}
+		strip := newBitBoard(p.ImageWidth, endY-startY, p.Rule)
+		calculateNextState(t, wp, boards[t], strip, workerChannels{events: events}, 0, halo)
+		next = append(next, strip.unpack()...)
+	}
+	return next
+}
+
+//Builds a world from rows of '#' for alive and '.' for dead cells
+func makeWorld(rows ...string) [][]byte {
+	world := make([][]byte, len(rows))
+	for y, row := range rows {
+		world[y] = make([]byte, len(row))
+		for x, c := range row {
+			if c == '#' {
+				world[y][x] = 255
+			}
+		}
+	}
+	return world
+}
+
+func assertEqualWorld(t *testing.T, name string, given, expected [][]byte) {
+	for y := range expected {
+		for x := range expected[y] {
+			if given[y][x] != expected[y][x] {
+				t.Errorf("%v: cell (%v, %v) is %v, expected %v", name, x, y, given[y][x], expected[y][x])
+			}
+		}
+	}
+}
+
+func mustParseRule(t *testing.T, s string) Rule {
+	rule, err := ParseRule(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rule
+}
+
+// TestParseRule checks that rules in B/S notation are read correctly, and that broken ones are rejected.
+func TestParseRule(t *testing.T) {
+	valid := map[string]Rule{
+		"B3/S23":       Conway,
+		"S23/B3":       Conway,
+		"b36/s23":      {Birth: 1<<3 | 1<<6, Survival: 1<<2 | 1<<3},
+		"B2/S":         {Birth: 1 << 2},
+		"B3678/S34678": {Birth: 1<<3 | 1<<6 | 1<<7 | 1<<8, Survival: 1<<3 | 1<<4 | 1<<6 | 1<<7 | 1<<8},
+		"B2/S/C3":      {Birth: 1 << 2, States: 3},
+		"B2/S345/4":    {Birth: 1 << 2, Survival: 1<<3 | 1<<4 | 1<<5, States: 4},
+		"B3/S23/2":     Conway,
+	}
+	for s, expected := range valid {
+		rule := mustParseRule(t, s)
+		if rule != expected {
+			t.Errorf("%q parsed as %v, expected %v", s, rule, expected)
+		}
+	}
+	for _, s := range []string{"", "B3", "B9/S23", "B3/B3", "X3/S23", "B/S", "B2/S/1", "B2/S/C257", "B2/S/X"} {
+		if _, err := ParseRule(s); err == nil {
+			t.Errorf("%q should not parse", s)
+		}
+	}
+	if (Rule{}).String() != "B3/S23" {
+		t.Errorf("the zero rule should be Conway's, got %v", Rule{})
+	}
+}
+
+// TestSeeds checks that under Seeds (B2/S) a block dies, giving birth to the 8 cells beside its edges.
+func TestSeeds(t *testing.T) {
+	p := Params{ImageWidth: 8, ImageHeight: 8, Rule: mustParseRule(t, "B2/S")}
+	world := makeWorld(
+		"........",
+		"........",
+		"........",
+		"...##...",
+		"...##...",
+		"........",
+		"........",
+		"........")
+	expected := makeWorld(
+		"........",
+		"........",
+		"...##...",
+		"..#..#..",
+		"..#..#..",
+		"...##...",
+		"........",
+		"........")
+	assertEqualWorld(t, "Seeds", step(p, world), expected)
+}
+
+// TestHighLife checks that HighLife (B36/S23) only differs from Conway's Game of Life
+// by giving birth to cells with 6 alive neighbours.
+func TestHighLife(t *testing.T) {
+	world := makeWorld(
+		"........",
+		"........",
+		"..###...",
+		"........",
+		"..###...",
+		"........",
+		"........",
+		"........")
+	p := Params{ImageWidth: 8, ImageHeight: 8}
+	expected := step(p, world)
+	expected[3][3] = 255
+	p.Rule = mustParseRule(t, "B36/S23")
+	assertEqualWorld(t, "HighLife", step(p, world), expected)
+}
+
+// TestDayAndNight checks that Day & Night (B3678/S34678) treats dead and alive cells alike,
+// so that inverting a random world and then executing a turn gives the inverse of its next turn.
+func TestDayAndNight(t *testing.T) {
+	p := Params{ImageWidth: 16, ImageHeight: 16, Rule: mustParseRule(t, "B3678/S34678")}
+	random := rand.New(rand.NewSource(1))
+	world := make([][]byte, p.ImageHeight)
+	inverse := make([][]byte, p.ImageHeight)
+	for y := range world {
+		world[y] = make([]byte, p.ImageWidth)
+		inverse[y] = make([]byte, p.ImageWidth)
+		for x := range world[y] {
+			if random.Intn(2) == 0 {
+				world[y][x] = 255
+			}
+			inverse[y][x] = 255 - world[y][x]
+		}
+	}
+	next := step(p, world)
+	for y := range next {
+		for x := range next[y] {
+			next[y][x] = 255 - next[y][x]
+		}
+	}
+	assertEqualWorld(t, "Day & Night", step(p, inverse), next)
+}
+
+// TestLevels checks that every state of a Generations rule has its own gray level, which reads back as the same state.
+func TestLevels(t *testing.T) {
+	for states := 3; states <= 256; states++ {
+		rule := Rule{Birth: 1 << 2, States: states}
+		for state := 0; state < states; state++ {
+			if rule.State(rule.Level(state)) != state {
+				t.Fatalf("state %v of %v is stored as %v, which reads back as state %v",
+					state, states, rule.Level(state), rule.State(rule.Level(state)))
+			}
+		}
+	}
+}
+
+// TestBriansBrain checks that under Brian's Brain (B2/S/C3) a domino gives birth to the cells
+// beside it and starts dying, then dies on the turn after.
+func TestBriansBrain(t *testing.T) {
+	p := Params{ImageWidth: 8, ImageHeight: 8, Rule: mustParseRule(t, "B2/S/C3")}
+	world := makeWorld(
+		"........",
+		"........",
+		"........",
+		"...##...",
+		"........",
+		"........",
+		"........",
+		"........")
+	expected := makeWorld(
+		"........",
+		"........",
+		"...##...",
+		"........",
+		"...##...",
+		"........",
+		"........",
+		"........")
+	dying := p.Rule.Level(2)
+	expected[3][3] = dying
+	expected[3][4] = dying
+	world = step(p, world)
+	assertEqualWorld(t, "Brian's Brain turn 1", world, expected)
+	if world = step(p, world); world[3][3] != 0 || world[3][4] != 0 {
+		t.Errorf("Brian's Brain turn 2: dying cells should be dead, got %v and %v", world[3][3], world[3][4])
+	}
+}
@@ -0,0 +1,297 @@
+package gol
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+//sparseBoard holds the alive cells of an unbounded plane. The cells may have any
+//coordinates, including negative ones, as nothing wraps around.
+type sparseBoard map[util.Cell]struct{}
+
+//Returns the board after one turn, along with the cells that changed
+func (b sparseBoard) step(rule Rule) (sparseBoard, []util.Cell) {
+	neighbours := make(map[util.Cell]int, 4*len(b))
+	for cell := range b {
+		for dy := -1; dy <= 1; dy++ {
+			for dx := -1; dx <= 1; dx++ {
+				if dx != 0 || dy != 0 {
+					neighbours[util.Cell{X: cell.X + dx, Y: cell.Y + dy}]++
+				}
+			}
+		}
+	}
+
+	next := make(sparseBoard, len(b))
+	var flipped []util.Cell
+	for cell, count := range neighbours {
+		_, alive := b[cell]
+		if rule.NextState(alive, count) {
+			next[cell] = struct{}{}
+		}
+		if _, nextAlive := next[cell]; nextAlive != alive {
+			flipped = append(flipped, cell)
+		}
+	}
+	//Alive cells without any alive neighbours are missing from the counts
+	for cell := range b {
+		if _, counted := neighbours[cell]; counted {
+			continue
+		}
+		if rule.NextState(true, 0) {
+			next[cell] = struct{}{}
+		} else {
+			flipped = append(flipped, cell)
+		}
+	}
+	return next, flipped
+}
+
+//Sets cells of the board to new gray levels, drawing the cells that change
+func (b sparseBoard) setLevels(c distributorChannels, levels map[util.Cell]uint8, turn int) {
+	changes := make([]cellChange, 0, len(levels))
+	for cell, level := range levels {
+		change := cellChange{cell: cell, after: level}
+		if _, alive := b[cell]; alive {
+			change.before = 255
+		}
+		changes = append(changes, change)
+		if level == 255 {
+			b[cell] = struct{}{}
+		} else {
+			delete(b, cell)
+		}
+		c.events <- CellFlipped{CompletedTurns: turn, Cell: cell, Value: level}
+	}
+	sendStats(c, turn, changes, true)
+}
+
+//Returns the hash of the board, as the cycle detector keeps it
+func (b sparseBoard) hash() uint64 {
+	hash := uint64(0)
+	for cell := range b {
+		hash ^= cellKey(cell, 255)
+	}
+	return hash
+}
+
+func (b sparseBoard) aliveCells() []util.Cell {
+	cells := make([]util.Cell, 0, len(b))
+	for cell := range b {
+		cells = append(cells, cell)
+	}
+	return cells
+}
+
+//Returns an error if the game cannot be run with the sparse engine. The unbounded plane
+//has no edges to join, so the topology must be left as the default torus.
+func checkSparse(p Params) error {
+	switch {
+	case p.Topology != Torus:
+		return errors.New(fmt.Sprintf("the sparse engine runs on an unbounded plane without edges, so cannot run on a %v", p.Topology))
+	case p.Rule.States > 2:
+		return errors.New(fmt.Sprintf("the sparse engine cannot run the Generations rule %v", p.Rule))
+	case p.Rule.Birth&1 != 0:
+		return errors.New(fmt.Sprintf("the sparse engine cannot run %v, as B0 would bring the whole plane alive", p.Rule))
+	}
+	return nil
+}
+
+//Writes the alive cells inside a region of the plane to a pgm image the size of the region.
+//The zero Region writes the bounding box of the alive cells.
+func outputRegion(p Params, c distributorChannels, aliveCells []util.Cell, turns int) {
+	region := p.Region
+	if region == (Region{}) {
+		region = boundingBox(aliveCells)
+	}
+	c.ioCommand <- ioOutputRegion
+	c.region <- region
+	template := p.OutputName
+	if template == "" {
+		template = DefaultOutputName + "_{x}_{y}"
+	}
+	s := outputName(template, p, region.Width, region.Height, region.X, region.Y, turns)
+	c.filename <- s
+
+	world := make([][]byte, region.Height)
+	for i := range world {
+		world[i] = make([]byte, region.Width)
+	}
+	for _, cell := range aliveCells {
+		if region.contains(cell) {
+			world[cell.Y-region.Y][cell.X-region.X] = 255
+		}
+	}
+	for i := range world {
+		for j := range world[i] {
+			c.output <- world[i][j]
+		}
+	}
+}
+
+//Runs the game with the sparse engine, in place of the distributor and its workers.
+//The image is placed with its top left corner at (0, 0) of an unbounded plane.
+func sparseDistributor(p Params, c distributorChannels) {
+	world, err := readWorld(p, c)
+	if err != nil {
+		c.events <- ErrorOccurred{0, err}
+		finish(c, 0)
+		return
+	}
+	board := make(sparseBoard)
+	for y := range world {
+		for x := range world[y] {
+			if world[y][x] == 255 {
+				board[util.Cell{X: x, Y: y}] = struct{}{}
+				c.events <- CellFlipped{CompletedTurns: 0, Cell: util.Cell{X: x, Y: y}, Value: 255}
+			}
+		}
+	}
+	sendStats(c, 0, boardChanges(world), true)
+
+	turn := 0
+	isPaused := false
+	ticker := time.NewTicker(reportInterval(p))
+	telemetry := newTelemetryMeter(0, 0)
+	//Closed, so that a turn is always ready unless the game is paused
+	running := make(chan bool)
+	close(running)
+	past := newHistory(p.History)
+	//Turn that the game pauses on once it is reached, 0 if it is not to pause
+	stopAt := 0
+	cycles := newCycleDetector(board.hash(), 0)
+	//Moves the paused game towards a turn, through the history and then by running to it
+	seek := func(target int) {
+		if target > p.Turns {
+			target = p.Turns
+		}
+		if target < 0 {
+			target = 0
+		}
+		levels, reached := past.seek(turn, target)
+		board.setLevels(c, levels, reached)
+		turn = reached
+		cycles = newCycleDetector(board.hash(), turn)
+		if reached < target {
+			isPaused = false
+			stopAt = target
+			c.events <- StateChange{turn, Executing}
+			return
+		}
+		if reached > target {
+			fmt.Println("Error: turn", target, "is no longer in the history, stopped at turn", reached)
+		}
+		c.events <- StateChange{turn, Paused}
+	}
+
+	for turn < p.Turns || isPaused {
+		next := running
+		if isPaused {
+			next = nil
+		}
+		select {
+		case <-ticker.C:
+			c.events <- AliveCellsCount{CompletedTurns: turn, CellsCount: len(board)}
+			c.events <- telemetry.report(turn)
+		case k := <-c.keyPresses:
+			switch k {
+			case 'p':
+				isPaused = !isPaused
+				stopAt = 0
+				if isPaused {
+					c.events <- StateChange{turn, Paused}
+				} else {
+					c.events <- StateChange{turn, Executing}
+				}
+			case 'n':
+				if isPaused {
+					seek(turn + 1)
+				}
+			case 'b':
+				if !isPaused {
+					break
+				}
+				if entry, ok := past.stepBack(); ok {
+					levels := map[util.Cell]uint8{}
+					entry.undo(levels)
+					board.setLevels(c, levels, entry.from)
+					turn = entry.from
+					cycles = newCycleDetector(board.hash(), turn)
+				}
+				c.events <- StateChange{turn, Paused}
+			case 's':
+				outputRegion(p, c, board.aliveCells(), turn)
+			case 'q':
+				outputRegion(p, c, board.aliveCells(), turn)
+				ticker.Stop()
+				finish(c, turn)
+				return
+			}
+		case target := <-c.seeks:
+			if isPaused {
+				seek(target)
+			}
+		case edit := <-c.edits:
+			if isPaused {
+				//The plane has no edges, so the cells are edited where they are
+				changes := []cellChange{}
+				for _, cell := range edit.Cells {
+					_, alive := board[cell]
+					value := uint8(0)
+					if edit.apply(alive) {
+						board[cell] = struct{}{}
+						value = 255
+					} else {
+						delete(board, cell)
+					}
+					if _, nowAlive := board[cell]; nowAlive != alive {
+						changes = append(changes, cellChange{cell: cell, before: 255 - value, after: value})
+					}
+					c.events <- CellFlipped{CompletedTurns: turn, Cell: cell, Value: value}
+				}
+				past.add(historyEntry{from: turn, to: turn, changes: changes})
+				sendStats(c, turn, changes, true)
+				cycles = newCycleDetector(board.hash(), turn)
+			}
+		case <-next:
+			var flipped []util.Cell
+			board, flipped = board.step(p.Rule)
+			changes := make([]cellChange, 0, len(flipped))
+			for _, cell := range flipped {
+				value := uint8(0)
+				if _, alive := board[cell]; alive {
+					value = 255
+				}
+				changes = append(changes, cellChange{cell: cell, before: 255 - value, after: value})
+				cycles.flip(cell, 255-value, value)
+				c.events <- CellFlipped{CompletedTurns: turn, Cell: cell, Value: value}
+			}
+			past.add(historyEntry{from: turn, to: turn + 1, changes: changes})
+			sendStats(c, turn+1, changes, false)
+			//Numbered from 0 like the turns of the strip engine
+			c.events <- TurnComplete{CompletedTurns: turn}
+			turn++
+			if period, ok := cycles.turnComplete(turn); ok {
+				c.events <- CycleDetected{CompletedTurns: turn, Period: period}
+				if skip := cycleSkip(p, turn, period); p.SkipCycles && stopAt == 0 && skip > 0 {
+					past.add(historyEntry{from: turn, to: turn + skip})
+					turn += skip
+				}
+			}
+			if turn == stopAt {
+				isPaused = true
+				stopAt = 0
+				c.events <- StateChange{turn, Paused}
+			}
+		}
+	}
+	ticker.Stop()
+
+	alive := board.aliveCells()
+	c.events <- FinalTurnComplete{CompletedTurns: turn, Alive: alive}
+	outputRegion(p, c, alive, turn)
+	finish(c, turn)
+}
@@ -0,0 +1,165 @@
+package gol
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+//Returns a sparse board holding the alive cells of a world, moved by (x, y)
+func sparseFromWorld(world [][]byte, x int, y int) sparseBoard {
+	board := make(sparseBoard)
+	for cy := range world {
+		for cx := range world[cy] {
+			if world[cy][cx] == 255 {
+				board[util.Cell{X: cx + x, Y: cy + y}] = struct{}{}
+			}
+		}
+	}
+	return board
+}
+
+// TestSparse runs a random soup in the middle of a plane, far enough from the edges that they
+// are never reached, and checks each turn against the reference and the cells reported as flipped.
+func TestSparse(t *testing.T) {
+	for _, rule := range []string{"B3/S23", "B36/S23", "B2/S"} {
+		p := Params{ImageWidth: 64, ImageHeight: 64, Rule: mustParseRule(t, rule), Topology: Plane}
+		world := make([][]byte, p.ImageHeight)
+		soup := randomWorld(Params{ImageWidth: 20, ImageHeight: 20}, 3)
+		for y := range world {
+			world[y] = make([]byte, p.ImageWidth)
+			if y >= 22 && y < 42 {
+				copy(world[y][22:], soup[y-22])
+			}
+		}
+		board := sparseFromWorld(world, 0, 0)
+		for turn := 1; turn <= 10; turn++ {
+			previous := board
+			var flipped []util.Cell
+			board, flipped = board.step(p.Rule)
+			world = referenceStep(p, world)
+			name := fmt.Sprintf("%v on turn %v", p.Rule, turn)
+			if len(board) != countAlive(world) {
+				t.Errorf("%v: %v alive cells, expected %v", name, len(board), countAlive(world))
+			}
+			for cell := range board {
+				if world[cell.Y][cell.X] != 255 {
+					t.Errorf("%v: cell %v should be dead", name, cell)
+				}
+			}
+			for _, cell := range flipped {
+				_, before := previous[cell]
+				_, after := board[cell]
+				if before == after {
+					t.Errorf("%v: cell %v reported flipped but stayed the same", name, cell)
+				}
+			}
+		}
+	}
+}
+
+// TestSparseGliders sends gliders towards both corners of a 16x16 image. On the plane they
+// must keep going past the edges, into negative coordinates, instead of wrapping around.
+func TestSparseGliders(t *testing.T) {
+	p := Params{ImageWidth: 16, ImageHeight: 16}
+	for _, test := range []struct {
+		mirrored bool
+		dx, dy   int
+	}{{false, 1, 1}, {true, -1, -1}} {
+		start := glider(p, 6, 6)
+		if test.mirrored {
+			start = mirror(start, true, true)
+		}
+		board := sparseFromWorld(start, 0, 0)
+		for turn := 0; turn < 400; turn++ {
+			board, _ = board.step(p.Rule)
+		}
+		expected := sparseFromWorld(start, 100*test.dx, 100*test.dy)
+		if len(board) != len(expected) {
+			t.Fatalf("glider moving (%v, %v) has %v cells, expected %v", test.dx, test.dy, len(board), len(expected))
+		}
+		for cell := range expected {
+			if _, ok := board[cell]; !ok {
+				t.Errorf("glider moving (%v, %v) is missing cell %v", test.dx, test.dy, cell)
+			}
+		}
+	}
+}
+
+// TestSparseTopology asks the sparse engine for a bounded plane, which it cannot run, and
+// checks that Run ends the game with the error instead of running any turns.
+func TestSparseTopology(t *testing.T) {
+	p := Params{Turns: 10, Threads: 1, ImageWidth: 16, ImageHeight: 16, Topology: Plane, Engine: SparseEngine}
+	events := make(chan Event)
+	go Run(p, events, nil)
+	var err error
+	for event := range events {
+		switch e := event.(type) {
+		case ErrorOccurred:
+			err = e.Err
+		case TurnComplete, FinalTurnComplete:
+			t.Errorf("got %T after asking for a plane", e)
+		}
+	}
+	if err == nil || err.Error() != checkSparse(p).Error() {
+		t.Errorf("got the error %v, expected %v", err, checkSparse(p))
+	}
+}
+
+func TestParseRegion(t *testing.T) {
+	region, err := ParseRegion("-10, 5,20,30")
+	if err != nil || region != (Region{-10, 5, 20, 30}) {
+		t.Errorf("parsed %v, %v", region, err)
+	}
+	for _, s := range []string{"1,2,3", "a,b,c,d", "0,0,-1,5"} {
+		if _, err := ParseRegion(s); err == nil {
+			t.Errorf("%q should not parse", s)
+		}
+	}
+	cells := []util.Cell{{X: 3, Y: -2}, {X: -4, Y: 7}, {X: 0, Y: 0}}
+	if box := boundingBox(cells); box != (Region{-4, -2, 8, 10}) {
+		t.Errorf("bounding box of %v is %v", cells, box)
+	}
+}
+
+// TestOutputRegion writes a region to an image through the io goroutine, and checks its header and cells.
+func TestOutputRegion(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+	_ = os.Chdir(dir)
+
+	ioCommand := make(chan ioCommand)
+	ioIdle := make(chan bool)
+	output := make(chan uint8)
+	filename := make(chan string)
+	region := make(chan Region)
+	go startIo(Params{}, ioChannels{command: ioCommand, idle: ioIdle, filename: filename, region: region, output: output})
+	c := distributorChannels{ioCommand: ioCommand, ioIdle: ioIdle, output: output, filename: filename, region: region}
+
+	cells := []util.Cell{{X: -3, Y: -1}, {X: 0, Y: 0}, {X: 2, Y: 1}}
+	outputRegion(Params{}, c, cells, 7)
+	outputRegion(Params{Region: Region{-1, 0, 2, 1}}, c, cells, 7)
+	ioCommand <- ioCheckIdle
+	<-ioIdle
+
+	for name, expected := range map[string]string{
+		"6x3x7_-3_-1": "P5\n6 3\n255\n" + string([]byte{255, 0, 0, 0, 0, 0, 0, 0, 0, 255, 0, 0, 0, 0, 0, 0, 0, 255}),
+		"2x1x7_-1_0":  "P5\n2 1\n255\n" + string([]byte{0, 255}),
+	} {
+		data, err := ioutil.ReadFile(filepath.Join("out", name+".pgm"))
+		if err != nil {
+			t.Errorf("%v: %v", name, err)
+		} else if string(data) != expected {
+			t.Errorf("%v: got %q, expected %q", name, data, expected)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package gol
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+//Number of turns that can wait to be written to the statistics file, so that the workers
+//are not held up while it is being written
+const statsBuffer = 1000
+
+//Cells changed by a turn, sent by the distributor to the statistics writer
+type statsChanges struct {
+	turn    int
+	changes []cellChange
+	//Whether the cells were changed other than by running a turn, as they were loaded at the
+	//start, edited or moved through the history. No row is written for them.
+	edit bool
+}
+
+//Statistics of the board after a turn, written as a row of the statistics file
+type turnStats struct {
+	CompletedTurns int `json:"completed_turns"`
+	AliveCells     int `json:"alive_cells"`
+	Births         int `json:"births"`
+	Deaths         int `json:"deaths"`
+	//Bounding box of the alive cells, which is the zero Region when none are alive
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+//Columns of a statistics csv file. The first two are those of the csv files in check/alive.
+var statsColumns = []string{"completed_turns", "alive_cells", "births", "deaths", "x", "y", "width", "height"}
+
+func (s turnStats) csvRow() []string {
+	row := []string{}
+	for _, n := range []int{s.CompletedTurns, s.AliveCells, s.Births, s.Deaths, s.X, s.Y, s.Width, s.Height} {
+		row = append(row, strconv.Itoa(n))
+	}
+	return row
+}
+
+//statsBoard keeps count of the alive cells of a board along each of its rows and columns,
+//so that the statistics of a turn come from the cells it changed
+type statsBoard struct {
+	alive   map[util.Cell]bool
+	rows    map[int]int
+	columns map[int]int
+}
+
+//Changes a cell, returning whether it was born or has died
+func (b statsBoard) set(cell util.Cell, level uint8) (bool, bool) {
+	wasAlive, alive := b.alive[cell], level == 255
+	switch {
+	case alive && !wasAlive:
+		b.alive[cell] = true
+		b.rows[cell.Y]++
+		b.columns[cell.X]++
+		return true, false
+	case wasAlive && !alive:
+		delete(b.alive, cell)
+		if b.rows[cell.Y]--; b.rows[cell.Y] == 0 {
+			delete(b.rows, cell.Y)
+		}
+		if b.columns[cell.X]--; b.columns[cell.X] == 0 {
+			delete(b.columns, cell.X)
+		}
+		return false, true
+	}
+	return false, false
+}
+
+//Returns the bounding box of the alive cells
+func (b statsBoard) bounds() Region {
+	if len(b.alive) == 0 {
+		return Region{}
+	}
+	minX, maxX := span(b.columns)
+	minY, maxY := span(b.rows)
+	return Region{minX, minY, maxX - minX + 1, maxY - minY + 1}
+}
+
+//Returns the smallest and largest of the keys of a map
+func span(counts map[int]int) (int, int) {
+	first := true
+	min, max := 0, 0
+	for i := range counts {
+		if first || i < min {
+			min = i
+		}
+		if first || i > max {
+			max = i
+		}
+		first = false
+	}
+	return min, max
+}
+
+//Writes a row of statistics for every turn to the Stats file, as JSON Lines if it ends in
+//.jsonl and csv otherwise. Runs until the changes channel is closed, then sends on done
+//once the file has been written.
+func writeStats(p Params, in <-chan statsChanges, done chan<- bool) {
+	defer func() { done <- true }()
+	_ = os.MkdirAll(filepath.Dir(p.Stats), os.ModePerm)
+	file, err := os.Create(p.Stats)
+	if err != nil {
+		fmt.Println("Error: could not write statistics.", err)
+		//The distributor must not be held up waiting for the turns to be taken
+		for range in {
+		}
+		return
+	}
+	defer file.Close()
+	buffered := bufio.NewWriter(file)
+
+	var write func(turnStats)
+	flush := func() {}
+	if strings.HasSuffix(p.Stats, ".jsonl") {
+		encoder := json.NewEncoder(buffered)
+		write = func(s turnStats) { util.Check(encoder.Encode(s)) }
+	} else {
+		writer := csv.NewWriter(buffered)
+		util.Check(writer.Write(statsColumns))
+		write = func(s turnStats) { util.Check(writer.Write(s.csvRow())) }
+		flush = writer.Flush
+	}
+
+	board := statsBoard{alive: map[util.Cell]bool{}, rows: map[int]int{}, columns: map[int]int{}}
+	for turn := range in {
+		stats := turnStats{CompletedTurns: turn.turn}
+		for _, change := range turn.changes {
+			born, died := board.set(change.cell, change.after)
+			if born {
+				stats.Births++
+			}
+			if died {
+				stats.Deaths++
+			}
+		}
+		if turn.edit {
+			continue
+		}
+		stats.AliveCells = len(board.alive)
+		bounds := board.bounds()
+		stats.X, stats.Y, stats.Width, stats.Height = bounds.X, bounds.Y, bounds.Width, bounds.Height
+		write(stats)
+	}
+	flush()
+	util.Check(buffered.Flush())
+}
+
+//Sends the cells changed by a turn to the statistics writer, if there is one
+func sendStats(c distributorChannels, turn int, changes []cellChange, edit bool) {
+	if c.stats != nil {
+		c.stats <- statsChanges{turn: turn, changes: changes, edit: edit}
+	}
+}
+
+//Returns the cells of a board as changes from an empty one
+func boardChanges(board [][]byte) []cellChange {
+	changes := []cellChange{}
+	for y, row := range board {
+		for x, level := range row {
+			if level != 0 {
+				changes = append(changes, cellChange{cell: util.Cell{X: x, Y: y}, after: level})
+			}
+		}
+	}
+	return changes
+}
@@ -0,0 +1,66 @@
+package gol
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestStatsJSON runs a glider with the engines that can write statistics, checking the JSON
+// Lines they write. The glider keeps 5 cells, each of its turns has 2 births and 2 deaths,
+// and its bounding box moves one cell diagonally every 4 turns.
+func TestStatsJSON(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	input := filepath.Join(dir, "glider.cells")
+	if err := ioutil.WriteFile(input, []byte(".O.\n..O\nOOO\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, engine := range []Engine{StripEngine, SparseEngine} {
+		p := Params{
+			Turns:       20,
+			Threads:     4,
+			ImageWidth:  32,
+			ImageHeight: 32,
+			Rule:        Conway,
+			Engine:      engine,
+			Input:       input,
+			OutputDir:   dir,
+			Stats:       filepath.Join(dir, "stats.jsonl"),
+		}
+		events := make(chan Event)
+		go Run(p, events, nil)
+		for range events {
+		}
+
+		f, err := os.Open(p.Stats)
+		if err != nil {
+			t.Fatal(err)
+		}
+		scanner := bufio.NewScanner(f)
+		turns := 0
+		for scanner.Scan() {
+			var stats turnStats
+			if err := json.Unmarshal(scanner.Bytes(), &stats); err != nil {
+				t.Fatalf("%v: %v", engine, err)
+			}
+			turns++
+			bounds := boundingBox(cellList(gliderCells(p.ImageWidth, turns)))
+			expected := turnStats{turns, 5, 2, 2, bounds.X, bounds.Y, bounds.Width, bounds.Height}
+			if stats != expected {
+				t.Errorf("%v: line %v is %+v, expected %+v", engine, turns, stats, expected)
+			}
+		}
+		f.Close()
+		if turns != p.Turns {
+			t.Errorf("%v: %v lines of statistics, expected %v", engine, turns, p.Turns)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package gol
+
+import "time"
+
+//DefaultReportInterval is how often the alive cells and telemetry are reported
+const DefaultReportInterval = 2 * time.Second
+
+//Returns how often the alive cells and telemetry are reported, DefaultReportInterval if
+//the params leave it at 0
+func reportInterval(p Params) time.Duration {
+	if p.ReportInterval <= 0 {
+		return DefaultReportInterval
+	}
+	return p.ReportInterval
+}
+
+//telemetryMeter times the turns of the workers between reports. Each turn, every worker
+//sends its edges, waits for the distributor to send back its halo once all edges have
+//arrived, then runs its strip. A strip with more work than the others keeps the rest
+//waiting on the halo exchange.
+type telemetryMeter struct {
+	since     time.Time
+	sinceTurn int
+	//When each worker's edges arrived on the current turn
+	edgesSent []time.Time
+	//When the halos of the current turn were sent
+	exchanged time.Time
+	//Total times of each worker since the last report
+	turnTimes []time.Duration
+	haloWaits []time.Duration
+	exchanges int
+}
+
+//Returns a meter for a number of workers, starting on a turn
+func newTelemetryMeter(workers int, turn int) *telemetryMeter {
+	m := &telemetryMeter{edgesSent: make([]time.Time, workers)}
+	m.reset(turn)
+	return m
+}
+
+func (m *telemetryMeter) reset(turn int) {
+	m.since = time.Now()
+	m.sinceTurn = turn
+	m.turnTimes = make([]time.Duration, len(m.edgesSent))
+	m.haloWaits = make([]time.Duration, len(m.edgesSent))
+	m.exchanges = 0
+}
+
+//Notes that a worker has sent its edges
+func (m *telemetryMeter) edgesReceived(worker int) {
+	m.edgesSent[worker] = time.Now()
+}
+
+//Notes that every worker has been sent its halo, ending the wait of each
+func (m *telemetryMeter) halosSent() {
+	m.exchanged = time.Now()
+	for w, sent := range m.edgesSent {
+		m.haloWaits[w] += m.exchanged.Sub(sent)
+	}
+	m.exchanges++
+}
+
+//Notes that a worker has finished running its strip
+func (m *telemetryMeter) turnComplete(worker int) {
+	m.turnTimes[worker] += time.Since(m.exchanged)
+}
+
+//Returns the telemetry since the last report, then starts measuring again from a turn
+func (m *telemetryMeter) report(turn int) Telemetry {
+	t := Telemetry{CompletedTurns: turn}
+	if elapsed := time.Since(m.since).Seconds(); elapsed > 0 {
+		t.TurnsPerSecond = float64(turn-m.sinceTurn) / elapsed
+	}
+	if len(m.edgesSent) > 0 {
+		t.TurnTimes = make([]time.Duration, len(m.edgesSent))
+		t.HaloWaits = make([]time.Duration, len(m.edgesSent))
+		for w := range m.edgesSent {
+			if m.exchanges > 0 {
+				t.TurnTimes[w] = (m.turnTimes[w] / time.Duration(m.exchanges)).Round(time.Microsecond)
+				t.HaloWaits[w] = (m.haloWaits[w] / time.Duration(m.exchanges)).Round(time.Microsecond)
+			}
+		}
+	}
+	m.reset(turn)
+	return t
+}
@@ -0,0 +1,76 @@
+package gol
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestTelemetry runs a glider with the engines that run every turn, reporting every 20
+// milliseconds, and checks the first few reports. The strip engine must time every
+// worker's strip, while the sparse engine has no workers to time.
+func TestTelemetry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	input := filepath.Join(dir, "glider.cells")
+	if err := ioutil.WriteFile(input, []byte(".O.\n..O\nOOO\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, engine := range []Engine{StripEngine, SparseEngine} {
+		p := Params{
+			Turns:          1<<40 - 1,
+			Threads:        4,
+			ImageWidth:     64,
+			ImageHeight:    64,
+			Rule:           Conway,
+			Engine:         engine,
+			Input:          input,
+			OutputDir:      dir,
+			ReportInterval: 20 * time.Millisecond,
+		}
+		events := make(chan Event)
+		keyPresses := make(chan rune, 10)
+		start := time.Now()
+		go Run(p, events, keyPresses)
+
+		reports := []Telemetry{}
+		for event := range events {
+			if e, ok := event.(Telemetry); ok {
+				reports = append(reports, e)
+				if len(reports) == 3 {
+					keyPresses <- 'q'
+				}
+			}
+		}
+
+		if len(reports) < 3 {
+			t.Fatalf("%v: %v telemetry reports, expected at least 3", engine, len(reports))
+		}
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("%v: 3 reports took %v, expected one every %v", engine, elapsed, p.ReportInterval)
+		}
+		for _, report := range reports[:3] {
+			if report.TurnsPerSecond <= 0 {
+				t.Errorf("%v: reported %v turns per second", engine, report.TurnsPerSecond)
+			}
+			workers := 0
+			if engine == StripEngine {
+				workers = p.Threads
+			}
+			if len(report.TurnTimes) != workers || len(report.HaloWaits) != workers {
+				t.Errorf("%v: reported %v strip times and %v halo waits, expected %v of each", engine, len(report.TurnTimes), len(report.HaloWaits), workers)
+			}
+			for w := range report.TurnTimes {
+				if report.TurnTimes[w] <= 0 {
+					t.Errorf("%v: strip %v took %v", engine, w, report.TurnTimes[w])
+				}
+			}
+		}
+	}
+}
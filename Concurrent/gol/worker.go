@@ -2,6 +2,7 @@ package gol
 
 import (
 	"fmt"
+	"math/bits"
 
 	"uk.ac.bris.cs/gameoflife/util"
 )
@@ -12,6 +13,7 @@ type workerParams struct {
 	ImageWidth  int
 	ImageHeight int
 	Turns       int
+	Rule        Rule
 }
 
 type workerChannels struct {
@@ -19,16 +21,29 @@ type workerChannels struct {
 	distributorEvents <-chan Event
 	globalFiller      chan<- filler
 	workerFiller      <-chan filler
-	finishedChannel   <-chan bool
+	finishedChannel   <-chan int
 	keyPresses        <-chan rune
+	edits             <-chan stripEdit
 }
 
-//Used to send the top and bottom arrays of each worker's world to the distributor,
-//as well as receive them from it
+//Sent to paused workers to set cells of the board to new gray levels, each worker setting
+//those in its own strip, and to move them to another turn
+type stripEdit struct {
+	turn   int
+	levels map[util.Cell]uint8
+}
+
+//Used to send the edges of each worker's world to the distributor, as well as receive
+//the cells just outside the world from it, packed one bit to a cell. A worker sends its
+//top row as lowerLine and its bottom row as upperLine, along with its first and last
+//columns. It receives the lines above and below its world, and the columns beside it,
+//which are two cells longer than the world to include the corners.
 type filler struct {
-	lowerLine []byte
-	upperLine []byte
-	workerID  int
+	lowerLine   []uint64
+	upperLine   []uint64
+	leftColumn  []uint64
+	rightColumn []uint64
+	workerID    int
 }
 
 func worker(world [][]byte, p workerParams, c workerChannels, workerID int) {
@@ -38,17 +53,17 @@ func worker(world [][]byte, p workerParams, c workerChannels, workerID int) {
 	//For all initially alive cells send a CellFlipped Event.
 	for y, elem := range world {
 		for x, cell := range elem {
-			if cell == 255 {
+			if cell != 0 {
 				d := util.Cell{X: x, Y: y + p.StartY}
-				cellFlip := CellFlipped{CompletedTurns: 0, Cell: d}
+				cellFlip := CellFlipped{CompletedTurns: 0, Cell: d, Value: cell}
 				c.events <- cellFlip
 			}
 		}
 	}
 
 	turn := 0
-	aliveCells := []util.Cell{}
-	aliveCells = calculateAliveCells(p, world, workerID)
+	board := packBoard(world, p.ImageWidth, p.Rule)
+	next := newBitBoard(p.ImageWidth, p.ImageHeight, p.Rule)
 
 	//Executes all turns of the Game of Life.
 	for {
@@ -58,39 +73,19 @@ func worker(world [][]byte, p workerParams, c workerChannels, workerID int) {
 			if turn == p.Turns {
 				break
 			}
-			aliveCells = []util.Cell{}
-			c.globalFiller <- filler{lowerLine: world[0], upperLine: world[p.ImageHeight-1], workerID: workerID}
+			c.globalFiller <- stripEdges(board, workerID)
 			//fmt.Println(workerID, "sent fillers")
 
 			//Receive lines outside world's boundaries for use in this worker
-			receivedFiller := <-c.workerFiller
-			receivedFiller2 := <-c.workerFiller
+			halo := <-c.workerFiller
 			//fmt.Println(workerID, "got fillers")
-			//Decide which filler delivered which line
-			upperLine := []byte{}
-			lowerLine := []byte{}
-			if receivedFiller.upperLine != nil {
-				upperLine = receivedFiller.upperLine
-				lowerLine = receivedFiller2.lowerLine
-			} else {
-				upperLine = receivedFiller2.upperLine
-				lowerLine = receivedFiller.lowerLine
-			}
 			//Execute turn of game
-			world, aliveCells = calculateNextState(workerID, p, world, c, turn, upperLine, lowerLine)
+			aliveCount := calculateNextState(workerID, p, board, next, c, turn, halo)
+			board, next = next, board
 			//Send completion event to distributor
-			c.events <- WorkerTurnComplete{CompletedTurns: turn, CellsCount: len(aliveCells)}
-			canContinue := false
-			for {
-				select {
-				case b := <-c.finishedChannel:
-					canContinue = b
-				}
-				if canContinue {
-					break
-				}
-			}
-			turn++
+			c.events <- WorkerTurnComplete{CompletedTurns: turn, CellsCount: aliveCount, WorkerID: workerID}
+			//The distributor sends the turn to go on from, which is past any cycles it skips
+			turn = <-c.finishedChannel
 		}
 
 		select {
@@ -100,19 +95,27 @@ func worker(world [][]byte, p workerParams, c workerChannels, workerID int) {
 				fmt.Println("Received pause command from worker")
 				isPaused = !isPaused
 			case 's':
-				//fmt.Println("Saving, alive cells:", aliveCells)
-				c.events <- WorkerSaveImage{CompletedTurns: turn, Alive: aliveCells}
+				c.events <- WorkerSaveImage{CompletedTurns: turn, Alive: calculateAliveCells(p, board, workerID), Dying: calculateDyingCells(p, board)}
 				//TODO: send event
 			case 'q':
-				c.events <- WorkerSaveImage{CompletedTurns: turn, Alive: aliveCells}
+				c.events <- WorkerSaveImage{CompletedTurns: turn, Alive: calculateAliveCells(p, board, workerID), Dying: calculateDyingCells(p, board)}
 				//TODO: send event
 				return
+			case 'e':
+				//The edit is sent before the key, so it is already waiting
+				edit := <-c.edits
+				for cell, level := range edit.levels {
+					if cell.Y >= p.StartY && cell.Y < p.EndY {
+						board.setLevel(cell.X, cell.Y-p.StartY, level)
+					}
+				}
+				turn = edit.turn
 			}
 		default:
 		}
 	}
 	//aliveCells := calculateAliveCells(p, world, workerID)
-	c.events <- WorkerFinalTurnComplete{CompletedTurns: turn, Alive: aliveCells}
+	c.events <- WorkerFinalTurnComplete{CompletedTurns: turn, Alive: calculateAliveCells(p, board, workerID), Dying: calculateDyingCells(p, board)}
 }
 
 func createNewWorld(world [][]byte, p workerParams) [][]byte {
@@ -134,112 +137,64 @@ func duplicateArray(array []byte, p workerParams) []byte {
 	return newArray
 }
 
-func calculateNextState(id int, p workerParams, world [][]byte, c workerChannels,
-	completedTurns int, upperLine []byte, lowerLine []byte) ([][]byte, []util.Cell) {
-	aliveCells := []util.Cell{}
-	h := p.ImageHeight
-	w := p.ImageWidth
-	nworld := make([][]byte, h, w)
-	for y, a := range world {
-		nA := make([]byte, w)
-		for x, b := range a {
-			nB := byte(0)
-			ln := calculateAliveNeighbours(id, h, w, world, x, y, upperLine, lowerLine)
-			if b == 0 {
-				if ln == 3 {
-					nB = 255
-					aliveCells = append(aliveCells, util.Cell{X: x, Y: y + p.StartY})
-					sendFlippedEvent(x, y+p.StartY, completedTurns, c)
-				} else {
-					nB = 0
-				}
-			} else {
-				if ln < 2 || ln > 3 {
-					nB = 0
-					sendFlippedEvent(x, y+p.StartY, completedTurns, c)
-				} else {
-					aliveCells = append(aliveCells, util.Cell{X: x, Y: y + p.StartY})
-					nB = 255
-				}
-			}
-			nA[x] = nB
-		}
-		nworld[y] = nA
+//Returns the rows and columns on the edges of a worker's world
+func stripEdges(world bitBoard, workerID int) filler {
+	h := world.height
+	leftColumn := make([]uint64, wordsFor(h))
+	rightColumn := make([]uint64, wordsFor(h))
+	for y, row := range world.rows {
+		setBit(leftColumn, y, getBit(row, 0))
+		setBit(rightColumn, y, getBit(row, world.width-1))
+	}
+	return filler{
+		lowerLine:   append([]uint64{}, world.rows[0]...),
+		upperLine:   append([]uint64{}, world.rows[h-1]...),
+		leftColumn:  leftColumn,
+		rightColumn: rightColumn,
+		workerID:    workerID,
 	}
-	return nworld, aliveCells
 }
 
-func sendFlippedEvent(x int, y int, completedTurns int, c workerChannels) {
-	cell := util.Cell{X: x, Y: y}
-	c.events <- CellFlipped{CompletedTurns: completedTurns, Cell: cell}
-}
-
-func calculateAliveCells(p workerParams, world [][]byte, workerID int) []util.Cell {
-	alive := []util.Cell{}
-	for y := 0; y < p.ImageHeight; y++ {
-		for x := 0; x < p.ImageWidth; x++ {
-			if world[y][x] == 255 {
-				coord := util.Cell{X: x, Y: y + p.StartY}
-				alive = append(alive, coord)
+//Executes one turn of a strip into next, sending a CellFlipped event for every cell that changes.
+//Returns the number of cells alive after the turn.
+func calculateNextState(id int, p workerParams, world bitBoard, next bitBoard, c workerChannels,
+	completedTurns int, halo filler) int {
+	world.step(next, p.Rule, halo)
+	aliveCount := 0
+	for y, row := range next.rows {
+		for i, word := range row {
+			aliveCount += bits.OnesCount64(word)
+			//Dying cells change on every turn
+			changed := word ^ world.rows[y][i]
+			if next.dying != nil {
+				changed |= next.dying[y][i] | world.dying[y][i]
+			}
+			for ; changed != 0; changed &= changed - 1 {
+				x := i*64 + bits.TrailingZeros64(changed)
+				sendFlippedEvent(x, y+p.StartY, next.level(x, y), completedTurns, c)
 			}
 		}
 	}
-	return alive
+	return aliveCount
 }
 
-func calculateAliveNeighbours(id int, h int, w int, world [][]byte, x int, y int, upperLine []byte, lowerLine []byte) int {
-	ans := 0
-	up := y + 1
-	down := y - 1
-
-	right := (x + 1) % w
-	left := (x - 1) % w
-	if left < 0 {
-		left = left + w
-	}
-
-	neighbours := make([][]byte, 3)
-	neighbours[1] = []byte{world[y][left], world[y][x], world[y][right]}
-	if y == 0 {
-		neighbours[2] = []byte{upperLine[left], upperLine[x], upperLine[right]}
-	} else {
-		neighbours[2] = []byte{world[down][left], world[down][x], world[down][right]}
-	}
-	if y == h-1 {
-		neighbours[0] = []byte{lowerLine[left], lowerLine[x], lowerLine[right]}
-	} else {
-		neighbours[0] = []byte{world[up][left], world[up][x], world[up][right]}
-	}
+func sendFlippedEvent(x int, y int, value byte, completedTurns int, c workerChannels) {
+	cell := util.Cell{X: x, Y: y}
+	c.events <- CellFlipped{CompletedTurns: completedTurns, Cell: cell, Value: value}
+}
 
-	up = 0
-	down = 2
-	left = 0
-	right = 2
-	middle := 1
+func calculateAliveCells(p workerParams, world bitBoard, workerID int) []util.Cell {
+	return setCells(world.rows, p.StartY)
+}
 
-	if neighbours[up][middle] == 255 {
-		ans++
-	}
-	if neighbours[down][middle] == 255 {
-		ans++
-	}
-	if neighbours[middle][left] == 255 {
-		ans++
-	}
-	if neighbours[middle][right] == 255 {
-		ans++
-	}
-	if neighbours[down][right] == 255 {
-		ans++
-	}
-	if neighbours[up][right] == 255 {
-		ans++
-	}
-	if neighbours[up][left] == 255 {
-		ans++
+//Returns the gray levels of the cells that are dying under a Generations rule
+func calculateDyingCells(p workerParams, world bitBoard) map[util.Cell]uint8 {
+	dying := map[util.Cell]uint8{}
+	if world.dying == nil {
+		return dying
 	}
-	if neighbours[down][left] == 255 {
-		ans++
+	for _, cell := range setCells(world.dying, 0) {
+		dying[util.Cell{X: cell.X, Y: cell.Y + p.StartY}] = world.levels[cell.Y][cell.X]
 	}
-	return ans
+	return dying
 }
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestHashlifeGol tests the hashlife engine on the same images and turns as TestGol.
+func TestHashlifeGol(t *testing.T) {
+	for _, size := range []int{16, 64, 512} {
+		for _, turns := range []int{0, 1, 100} {
+			p := gol.Params{ImageWidth: size, ImageHeight: size, Turns: turns, Threads: 1, Engine: gol.HashlifeEngine}
+			expectedAlive := util.ReadAliveCells(
+				"check/images/"+fmt.Sprintf("%vx%vx%v.pgm", p.ImageWidth, p.ImageHeight, turns),
+				p.ImageWidth,
+				p.ImageHeight,
+			)
+			t.Run(fmt.Sprintf("%dx%dx%d", p.ImageWidth, p.ImageHeight, p.Turns), func(t *testing.T) {
+				events := make(chan gol.Event)
+				gol.Run(p, events, nil)
+				var cells []util.Cell
+				for event := range events {
+					switch e := event.(type) {
+					case gol.FinalTurnComplete:
+						cells = e.Alive
+					}
+				}
+				assertEqualBoard(t, cells, expectedAlive, p)
+			})
+		}
+	}
+}
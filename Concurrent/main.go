@@ -3,9 +3,13 @@ package main
 import (
 	"flag"
 	"fmt"
+	"os"
+	"path/filepath"
 	"runtime"
 	"uk.ac.bris.cs/gameoflife/gol"
 	"uk.ac.bris.cs/gameoflife/sdl"
+	"uk.ac.bris.cs/gameoflife/tui"
+	"uk.ac.bris.cs/gameoflife/util"
 )
 
 // main is the function called when starting Game of Life with 'go run .'
@@ -22,14 +26,14 @@ func main() {
 	flag.IntVar(
 		&params.ImageWidth,
 		"w",
-		512,
-		"Specify the width of the image. Defaults to 512.")
+		0,
+		"Specify the width of the image. Defaults to the width of the input pattern, or 512.")
 
 	flag.IntVar(
 		&params.ImageHeight,
 		"h",
-		512,
-		"Specify the height of the image. Defaults to 512.")
+		0,
+		"Specify the height of the image. Defaults to the height of the input pattern, or 512.")
 
 	flag.IntVar(
 		&params.Turns,
@@ -37,15 +41,190 @@ func main() {
 		10000000000,
 		"Specify the number of turns to process. Defaults to 10000000000.")
 
+	flag.Var(
+		&params.Rule,
+		"rule",
+		"Specify the rule in B/S notation, e.g. B36/S23 for HighLife. Defaults to B3/S23, Conway's Game of Life.")
+
+	flag.Var(
+		&params.Topology,
+		"topology",
+		"Specify how the edges of the board join: torus, plane, cylinder, klein or projective. Defaults to torus.")
+
+	flag.Var(
+		&params.Engine,
+		"engine",
+		"Specify how turns are executed: strips, hashlife to jump ahead on square tori with sides a power of 2, or sparse for an unbounded plane, which has no edges so cannot be given a -topology. Defaults to strips.")
+
+	flag.Var(
+		&params.Region,
+		"region",
+		"Specify the part of the plane the sparse engine writes to images, as x,y,width,height. Defaults to the bounding box of the alive cells.")
+
+	flag.StringVar(
+		&params.Input,
+		"input",
+		"",
+		"Specify a pattern file to load, as a .pgm, .png, .rle or .cells file. Defaults to images/<width>x<height>.pgm.")
+
+	flag.IntVar(
+		&params.OffsetX,
+		"offsetx",
+		0,
+		"Specify the column the left edge of the loaded pattern is placed on. Defaults to 0.")
+
+	flag.IntVar(
+		&params.OffsetY,
+		"offsety",
+		0,
+		"Specify the row the top edge of the loaded pattern is placed on. Defaults to 0.")
+
+	threshold := flag.Int(
+		"threshold",
+		gol.DefaultThreshold,
+		"Specify the gray level from 1 to 255 from which pixels of a .pgm, .pbm or .png input are alive. Defaults to 128.")
+
+	flag.Var(
+		&params.Format,
+		"format",
+		"Specify the format that images are saved in: pgm, png, rle or cells. Defaults to pgm.")
+
+	flag.StringVar(
+		&params.OutputDir,
+		"out",
+		"out",
+		"Specify the directory that images are saved in. Defaults to out.")
+
+	flag.StringVar(
+		&params.OutputName,
+		"name",
+		gol.DefaultOutputName,
+		"Specify the names images are saved under, filling in {width}, {height}, {turns}, {x}, {y}, {rule} and {input}. Defaults to "+gol.DefaultOutputName+".")
+
+	flag.StringVar(
+		&params.Record,
+		"record",
+		"",
+		"Specify an animated .gif file to record the turns to. Defaults to not recording.")
+
+	flag.IntVar(
+		&params.RecordEvery,
+		"every",
+		1,
+		"Specify the number of turns between the frames of the recording. Defaults to 1.")
+
+	flag.IntVar(
+		&params.RecordScale,
+		"scale",
+		1,
+		"Specify the width and height in pixels of each cell of the recording. Defaults to 1.")
+
+	flag.IntVar(
+		&params.History,
+		"history",
+		gol.DefaultHistory,
+		"Specify the number of turns and edits kept to step back through with b while paused, or -1 to keep none. Defaults to "+fmt.Sprint(gol.DefaultHistory)+".")
+
+	flag.BoolVar(
+		&params.SkipCycles,
+		"skip-cycles",
+		false,
+		"Specify whether to skip to the last turn once the board is a still life or repeats a cycle of turns. Defaults to false.")
+
+	flag.StringVar(
+		&params.Stats,
+		"stats",
+		"",
+		"Specify a file to write the alive cells, births, deaths and bounding box of every turn to, as JSON Lines if it ends in .jsonl and csv otherwise. Defaults to none.")
+
+	flag.DurationVar(
+		&params.ReportInterval,
+		"report",
+		gol.DefaultReportInterval,
+		"Specify how often the alive cells and the turns per second are reported. Defaults to "+gol.DefaultReportInterval.String()+".")
+
+	paste := flag.String(
+		"paste",
+		"",
+		"Specify a pattern file to paste at the mouse by pressing v while paused. Defaults to none.")
+
+	headless := flag.Bool(
+		"headless",
+		false,
+		"Specify whether to draw the board in the terminal instead of an SDL window, reading keys from stdin. Defaults to false.")
+	flag.BoolVar(headless, "tui", false, "Same as -headless.")
+
 	flag.Parse()
 
+	if *threshold < 1 || *threshold > 255 {
+		fmt.Println("Error: the threshold must be from 1 to 255, not", *threshold)
+		os.Exit(1)
+	}
+	params.Threshold = uint8(*threshold)
+
+	//An RLE pattern brings its own rule, unless another is asked for
+	ruleGiven := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "rule" {
+			ruleGiven = true
+		}
+	})
+	if rule, ok := gol.PatternRule(params.Input); ok && !ruleGiven {
+		params.Rule = rule
+	}
+
+	//A board size that is not given is taken from the input pattern
+	if params.ImageWidth == 0 || params.ImageHeight == 0 {
+		width, height, err := gol.BoardSize(params.Input, params.OffsetX, params.OffsetY)
+		if err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		if params.ImageWidth == 0 {
+			params.ImageWidth = width
+		}
+		if params.ImageHeight == 0 {
+			params.ImageHeight = height
+		}
+	}
+
 	fmt.Println("Threads:", params.Threads)
 	fmt.Println("Width:", params.ImageWidth)
 	fmt.Println("Height:", params.ImageHeight)
+	fmt.Println("Rule:", params.Rule)
+	fmt.Println("Topology:", params.Topology)
+	if params.Input != "" {
+		fmt.Println("Input:", params.Input, "at", params.OffsetX, params.OffsetY)
+	}
+	fmt.Println("Format:", params.Format)
+	fmt.Println("Output:", filepath.Join(params.OutputDir, params.OutputName+"."+params.Format.String()))
+	if params.Record != "" {
+		fmt.Println("Record:", params.Record, "every", params.RecordEvery, "turns")
+	}
+	fmt.Println("Engine:", params.Engine)
+	if params.Engine == gol.SparseEngine {
+		fmt.Println("Region:", params.Region)
+	}
+
+	var pasteCells []util.Cell
+	if *paste != "" {
+		cells, err := gol.PatternCells(*paste, params.Rule, params.Threshold)
+		if err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		pasteCells = cells
+	}
 
 	keyPresses := make(chan rune, 10)
+	edits := make(chan gol.Edit, 10)
+	seeks := make(chan int, 10)
 	events := make(chan gol.Event, 1000)
 
-	gol.Run(params, events, keyPresses)
-	sdl.Start(params, events, keyPresses)
+	gol.RunInteractive(params, events, keyPresses, edits, seeks)
+	if *headless {
+		tui.Start(params, events, keyPresses, seeks)
+	} else {
+		sdl.Start(params, events, keyPresses, edits, seeks, pasteCells)
+	}
 }
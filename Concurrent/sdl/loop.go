@@ -4,15 +4,24 @@ import (
 	"fmt"
 	"github.com/veandco/go-sdl2/sdl"
 	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
 )
 
-func Start(p gol.Params, events <-chan gol.Event, keyPresses chan<- rune) {
+//Start shows the board in a window until the events channel is closed. While the game is
+//paused, clicking a cell toggles it and v pastes the paste pattern at the mouse, through edits.
+//n steps forward a turn and b steps back, and typing a turn then enter seeks to it.
+func Start(p gol.Params, events <-chan gol.Event, keyPresses chan<- rune, edits chan<- gol.Edit, seeks chan<- int, paste []util.Cell) {
 	w := NewWindow(int32(p.ImageWidth), int32(p.ImageHeight))
+	paused := false
+	//Cells edited while paused are drawn once no more events are waiting
+	edited := false
+	//Turn typed so far to seek to, -1 when none is being typed
+	typed := -1
 
 sdlLoop:
 	for {
 		event := w.PollEvent()
-		if event != nil {
+		if event != nil && !w.HandleEvent(event) {
 			switch e := event.(type) {
 			case *sdl.KeyboardEvent:
 				switch e.Keysym.Sym {
@@ -24,6 +33,40 @@ sdlLoop:
 					keyPresses <- 'q'
 				case sdl.K_k:
 					keyPresses <- 'k'
+				case sdl.K_n:
+					keyPresses <- 'n'
+				case sdl.K_b:
+					keyPresses <- 'b'
+				case sdl.K_0, sdl.K_1, sdl.K_2, sdl.K_3, sdl.K_4, sdl.K_5, sdl.K_6, sdl.K_7, sdl.K_8, sdl.K_9:
+					if typed < 0 {
+						typed = 0
+					}
+					typed = typed*10 + int(e.Keysym.Sym-sdl.K_0)
+					fmt.Println("Seek to turn", typed)
+				case sdl.K_RETURN, sdl.K_KP_ENTER:
+					if typed >= 0 && paused {
+						seeks <- typed
+					}
+					typed = -1
+				case sdl.K_ESCAPE:
+					typed = -1
+				case sdl.K_v:
+					//The top left corner of the pattern goes under the mouse
+					x, y, _ := sdl.GetMouseState()
+					if cellX, cellY, ok := w.CellAt(x, y); paused && ok && len(paste) > 0 {
+						cells := make([]util.Cell, len(paste))
+						for i, cell := range paste {
+							cells[i] = util.Cell{X: cell.X + cellX, Y: cell.Y + cellY}
+						}
+						edits <- gol.Edit{Cells: cells, Alive: true}
+					}
+				}
+			case *sdl.MouseButtonEvent:
+				if e.Button != sdl.BUTTON_LEFT || e.Type != sdl.MOUSEBUTTONUP {
+					break
+				}
+				if cellX, cellY, ok := w.CellAt(e.X, e.Y); paused && ok {
+					edits <- gol.Edit{Cells: []util.Cell{{X: cellX, Y: cellY}}}
 				}
 			}
 		}
@@ -35,16 +78,25 @@ sdlLoop:
 			}
 			switch e := event.(type) {
 			case gol.CellFlipped:
-				w.FlipPixel(e.Cell.X, e.Cell.Y)
+				w.ShadePixel(e.Cell.X, e.Cell.Y, e.Value)
+				if paused {
+					edited = true
+				}
 			case gol.TurnComplete:
 				w.RenderFrame()
+			case gol.StateChange:
+				paused = e.NewState == gol.Paused
+				fmt.Printf("Completed Turns %-8v%v\n", event.GetCompletedTurns(), event)
 			default:
 				if len(event.String()) > 0 {
 					fmt.Printf("Completed Turns %-8v%v\n", event.GetCompletedTurns(), event)
 				}
 			}
 		default:
-			break
+			if edited {
+				w.RenderFrame()
+				edited = false
+			}
 		}
 	}
 
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/csv"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestStats writes the statistics of the 64x64 board to a csv file, checking the alive cells
+// of every turn against check/alive, and that the births and deaths of each turn account
+// for the change in alive cells from the turn before.
+func TestStats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gol")
+	util.Check(err)
+	defer os.RemoveAll(dir)
+	p := gol.Params{
+		Turns:       100,
+		Threads:     8,
+		ImageWidth:  64,
+		ImageHeight: 64,
+		OutputDir:   dir,
+		Stats:       filepath.Join(dir, "stats.csv"),
+	}
+	alive := readAliveCounts(p.ImageWidth, p.ImageHeight)
+	events := make(chan gol.Event)
+	gol.Run(p, events, nil)
+	for range events {
+	}
+
+	f, err := os.Open(p.Stats)
+	util.Check(err)
+	defer f.Close()
+	table, err := csv.NewReader(f).ReadAll()
+	util.Check(err)
+	if len(table) != p.Turns+1 {
+		t.Fatalf("%v rows of statistics, expected a header and %v turns", len(table), p.Turns)
+	}
+	previous := -1
+	for _, row := range table[1:] {
+		values := make([]int, len(row))
+		for i := range row {
+			values[i], err = strconv.Atoi(row[i])
+			util.Check(err)
+		}
+		turn, cells, births, deaths, width, height := values[0], values[1], values[2], values[3], values[6], values[7]
+		if cells != alive[turn] {
+			t.Errorf("turn %v: %v alive cells, expected %v", turn, cells, alive[turn])
+		}
+		if previous >= 0 && cells != previous+births-deaths {
+			t.Errorf("turn %v: %v alive cells after %v births and %v deaths, but %v on the turn before", turn, cells, births, deaths, previous)
+		}
+		if cells > 0 && (width < 1 || width > p.ImageWidth || height < 1 || height > p.ImageHeight) {
+			t.Errorf("turn %v: bounding box is %vx%v", turn, width, height)
+		}
+		previous = cells
+	}
+}
@@ -0,0 +1,169 @@
+package tui
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+//Number of the latest events shown below the board
+const logLines = 5
+
+//Shortest time between frames, so that fast turns do not flood the terminal
+const frameInterval = 50 * time.Millisecond
+
+//Start draws the game in the terminal in place of an SDL window, until the events channel is closed.
+//Keys are read from stdin as soon as they are pressed: p pauses, s saves, q quits and k kills.
+//While paused, n steps forward a turn, b steps back, and a turn typed then enter seeks to it.
+func Start(p gol.Params, events <-chan gol.Event, keyPresses chan<- rune, seeks chan<- int) {
+	columns, rows := terminalSize()
+	//The turn, the latest events and the line the cursor is left on go below the board
+	s := NewScreen(p.ImageWidth, p.ImageHeight, columns, rows-logLines-2)
+	restore := rawMode()
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	go readKeys(keyPresses, seeks)
+
+	//Clear the terminal and hide the cursor
+	fmt.Print("\x1b[2J\x1b[?25l")
+	turn := 0
+	log := []string{}
+	var lastFrame time.Time
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				draw(s, columns, turn, log)
+				fmt.Print("\x1b[?25h")
+				restore()
+				return
+			}
+			switch e := event.(type) {
+			case gol.CellFlipped:
+				s.SetCell(e.Cell.X, e.Cell.Y, e.Value)
+			case gol.TurnComplete:
+				turn = e.CompletedTurns
+				if time.Since(lastFrame) >= frameInterval {
+					draw(s, columns, turn, log)
+					lastFrame = time.Now()
+				}
+			default:
+				//Steps and seeks move the turn of a paused game
+				if change, ok := event.(gol.StateChange); ok {
+					turn = change.CompletedTurns
+				}
+				if len(event.String()) > 0 {
+					log = append(log, fmt.Sprintf("Completed Turns %-8v%v", event.GetCompletedTurns(), event))
+					if len(log) > logLines {
+						log = log[1:]
+					}
+					draw(s, columns, turn, log)
+				}
+			}
+		case <-interrupts:
+			fmt.Print("\x1b[?25h\n")
+			restore()
+			os.Exit(130)
+		}
+	}
+}
+
+//Draws the board from the top of the terminal, followed by the turn and the latest events
+func draw(s *Screen, columns int, turn int, log []string) {
+	out := bufio.NewWriter(os.Stdout)
+	out.WriteString("\x1b[H")
+	for _, line := range s.Render() {
+		out.WriteString(line + "\x1b[K\n")
+	}
+	fmt.Fprintf(out, "Turn %v, each dot is %vx%v cells\x1b[K\n", turn, s.Scale, s.Scale)
+	for _, line := range log {
+		if len(line) > columns {
+			line = line[:columns]
+		}
+		out.WriteString(line + "\x1b[K\n")
+	}
+	out.WriteString("\x1b[J")
+	out.Flush()
+}
+
+//Sends the keys that control the game as they are read from stdin, and the turns typed
+//as digits followed by enter, which escape clears
+func readKeys(keyPresses chan<- rune, seeks chan<- int) {
+	reader := bufio.NewReader(os.Stdin)
+	typed := -1
+	for {
+		r, _, err := reader.ReadRune()
+		if err != nil {
+			return
+		}
+		switch {
+		case strings.ContainsRune("psqknb", r):
+			keyPresses <- r
+		case r >= '0' && r <= '9':
+			if typed < 0 {
+				typed = 0
+			}
+			typed = typed*10 + int(r-'0')
+		case r == '\n' || r == '\r':
+			if typed >= 0 {
+				seeks <- typed
+			}
+			typed = -1
+		case r == 0x1b:
+			typed = -1
+		}
+	}
+}
+
+//Runs stty on the terminal that stdin is connected to
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+//Stops the terminal from waiting for enter and echoing keys, returning a function that
+//puts it back. Nothing is changed when stdin is not a terminal, so keys then need enter.
+func rawMode() func() {
+	saved, err := stty("-g")
+	if err != nil {
+		return func() {}
+	}
+	if _, err := stty("-icanon", "-echo", "min", "1"); err != nil {
+		return func() {}
+	}
+	return func() {
+		_, _ = stty(saved)
+	}
+}
+
+//Returns the number of columns and rows of the terminal, or 80x24 when it cannot be found
+func terminalSize() (int, int) {
+	if size, err := stty("size"); err == nil {
+		fields := strings.Fields(size)
+		if len(fields) == 2 {
+			rows, rowsErr := strconv.Atoi(fields[0])
+			columns, columnsErr := strconv.Atoi(fields[1])
+			if rowsErr == nil && columnsErr == nil && rows > 0 && columns > 0 {
+				return columns, rows
+			}
+		}
+	}
+	columns, err := strconv.Atoi(os.Getenv("COLUMNS"))
+	if err != nil || columns < 1 {
+		columns = 80
+	}
+	rows, err := strconv.Atoi(os.Getenv("LINES"))
+	if err != nil || rows < 1 {
+		rows = 24
+	}
+	return columns, rows
+}
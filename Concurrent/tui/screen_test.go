@@ -0,0 +1,57 @@
+package tui
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+func TestScreen(t *testing.T) {
+	s := NewScreen(4, 8, 80, 24)
+	if s.Scale != 1 {
+		t.Fatalf("a 4x8 board should not be scaled, but has a scale of %v", s.Scale)
+	}
+	s.SetCell(0, 0, 255)
+	s.SetCell(3, 7, 255)
+	s.SetCell(1, 4, 255)
+	s.SetCell(1, 4, 0)
+	//A dying cell is lit until it dies
+	s.SetCell(2, 0, 170)
+	s.SetCell(2, 1, 170)
+	s.SetCell(2, 1, 85)
+	s.SetCell(2, 1, 0)
+	//Cells off the board are ignored
+	s.SetCell(-1, 2, 255)
+	s.SetCell(4, 0, 255)
+	expected := []string{"⠁⠁", "⠀⢀"}
+	lines := s.Render()
+	if len(lines) != len(expected) {
+		t.Fatalf("rendered %q, expected %q", lines, expected)
+	}
+	for i := range lines {
+		if lines[i] != expected[i] {
+			t.Errorf("rendered %q, expected %q", lines, expected)
+		}
+	}
+
+	//A large board is scaled down to fit, with each dot lit by any of its cells
+	s = NewScreen(512, 512, 80, 17)
+	if s.Scale != 8 {
+		t.Errorf("a 512x512 board in 80x17 characters should have a scale of 8, not %v", s.Scale)
+	}
+	s.SetCell(511, 511, 255)
+	s.SetCell(510, 504, 255)
+	s.SetCell(510, 504, 0)
+	lines = s.Render()
+	if len(lines) > 17 {
+		t.Errorf("rendered %v lines for 17 rows", len(lines))
+	}
+	for _, line := range lines {
+		if utf8.RuneCountInString(line) > 80 {
+			t.Errorf("rendered a line of %v characters for 80 columns", utf8.RuneCountInString(line))
+		}
+	}
+	last := []rune(lines[len(lines)-1])
+	if last[len(last)-1] != 0x2880 {
+		t.Errorf("the last character is %q, expected %q", last[len(last)-1], rune(0x2880))
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/rpc"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestCheckpointResume crashes an engine that is saving checkpoints, restarts it from
+// the last checkpoint, and checks that a controller continues from the saved turn.
+func TestCheckpointResume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gol")
+	util.Check(err)
+	defer os.RemoveAll(dir)
+	checkpointFile := filepath.Join(dir, "checkpoint")
+	alive := readAliveCounts(512, 512)
+
+	engine := startServer(t, dir, "engine", "-port", "8044", "-checkpoint", checkpointFile, "-checkpoint-every", "50")
+	defer stopServer(engine)
+	client, err := rpc.Dial("tcp", "127.0.0.1:8044")
+	util.Check(err)
+	params := gol.Params{Turns: 100000000, Threads: 4, ImageWidth: 512, ImageHeight: 512}
+	initParams := gol.InitParams{Alive: util.ReadAliveCells("images/512x512.pgm", 512, 512), Params: params}
+	util.Check(client.Call(gol.Initialise, gol.InitRequest{Params: &initParams, ShouldContinue: 0}, new(gol.StatusReport)))
+
+	//Crash the engine once it has saved a few checkpoints
+	var checkpoint gol.Checkpoint
+	for checkpoint.Turn < 200 {
+		time.Sleep(100 * time.Millisecond)
+		checkpoint, _ = gol.ReadCheckpoint(checkpointFile)
+	}
+	client.Close()
+	stopServer(engine)
+	checkpoint, err = gol.ReadCheckpoint(checkpointFile)
+	util.Check(err)
+	if checkpoint.Turn%50 != 0 || checkpoint.Params != params {
+		t.Fatalf("unexpected checkpoint at turn %v with %v", checkpoint.Turn, checkpoint.Params)
+	}
+	if len(checkpoint.Alive) != alive[checkpoint.Turn] {
+		t.Fatalf("checkpoint at turn %v has %v alive cells, expected %v", checkpoint.Turn, len(checkpoint.Alive), alive[checkpoint.Turn])
+	}
+
+	resumed := startServer(t, dir, "engine", "-port", "8045", "-resume", checkpointFile)
+	defer stopServer(resumed)
+	p := gol.ClientParams{
+		Turns:          100000000,
+		Threads:        4,
+		ImageWidth:     512,
+		ImageHeight:    512,
+		BrokerAddr:     "127.0.0.1:8045",
+		ShouldContinue: 1,
+		OutputDir:      dir,
+	}
+	events := make(chan gol.Event)
+	keyPresses := make(chan rune, 10)
+	gol.Run(p, events, keyPresses)
+	for event := range events {
+		switch e := event.(type) {
+		case gol.AliveCellsCount:
+			if e.CompletedTurns < checkpoint.Turn {
+				t.Errorf("resumed engine reported turn %v, before the checkpoint at turn %v", e.CompletedTurns, checkpoint.Turn)
+			}
+			if e.CompletedTurns <= 10000 && e.CellsCount != alive[e.CompletedTurns] {
+				t.Errorf("At turn %v expected %v alive cells, got %v instead", e.CompletedTurns, alive[e.CompletedTurns], e.CellsCount)
+			}
+			if len(keyPresses) == 0 {
+				keyPresses <- 'k'
+			}
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestCycle runs a block and a blinker on a worker node for far more turns than could be
+// computed. The engine must report the blinker's cycle of period 2 on turn 4, once the board
+// whose hash repeated on turn 2 has come back, and skip the cycles that fit before the last
+// turn, which is odd so the blinker ends up vertical.
+func TestCycle(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gol")
+	util.Check(err)
+	defer os.RemoveAll(dir)
+	input := filepath.Join(dir, "blinker.cells")
+	util.Check(ioutil.WriteFile(input, []byte("OO\nOO\n\n\n\n\n.OOO\n"), 0644))
+
+	workers := []*exec.Cmd{startServer(t, dir, "worker", "-port", "8093")}
+	engine := startServer(t, dir, "engine", "-port", "8055", "-workers", "127.0.0.1:8093")
+	defer stopServer(engine)
+	for _, w := range workers {
+		defer stopServer(w)
+	}
+
+	p := gol.ClientParams{
+		Turns:       1<<40 + 1,
+		Threads:     4,
+		ImageWidth:  16,
+		ImageHeight: 16,
+		BrokerAddr:  "127.0.0.1:8055",
+		Input:       input,
+		OutputDir:   dir,
+		SkipCycles:  true,
+	}
+	events := make(chan gol.Event)
+	gol.Run(p, events, nil)
+
+	cycles := []gol.CycleDetected{}
+	var final gol.FinalTurnComplete
+	for event := range events {
+		switch e := event.(type) {
+		case gol.CycleDetected:
+			cycles = append(cycles, e)
+		case gol.FinalTurnComplete:
+			final = e
+		}
+	}
+
+	if len(cycles) != 1 || cycles[0] != (gol.CycleDetected{CompletedTurns: 4, Period: 2}) {
+		t.Errorf("detected %v, expected a cycle of period 2 on turn 4", cycles)
+	}
+	if final.CompletedTurns != p.Turns {
+		t.Errorf("final turn is %v, expected %v", final.CompletedTurns, p.Turns)
+	}
+	expected := []util.Cell{{X: 0, Y: 0}, {X: 1, Y: 0}, {X: 0, Y: 1}, {X: 1, Y: 1}, {X: 2, Y: 5}, {X: 2, Y: 6}, {X: 2, Y: 7}}
+	if !sameCells(final.Alive, expected) {
+		t.Errorf("final board is %v, expected the block and a vertical blinker", final.Alive)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestEdit pauses an empty 16x16 game on a worker node, draws a block and pastes a blinker
+// onto it, then resumes and checks that both are still on the final board. The strips on the
+// worker node must be replaced by the edited ones before the game is resumed.
+func TestEdit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gol")
+	util.Check(err)
+	defer os.RemoveAll(dir)
+	input := filepath.Join(dir, "empty.cells")
+	util.Check(ioutil.WriteFile(input, []byte(".\n"), 0644))
+
+	workers := []*exec.Cmd{startServer(t, dir, "worker", "-port", "8091")}
+	engine := startServer(t, dir, "engine", "-port", "8049", "-workers", "127.0.0.1:8091")
+	defer stopServer(engine)
+	for _, w := range workers {
+		defer stopServer(w)
+	}
+
+	p := gol.ClientParams{
+		Turns:       2001,
+		Threads:     4,
+		ImageWidth:  16,
+		ImageHeight: 16,
+		BrokerAddr:  "127.0.0.1:8049",
+		Input:       input,
+		OutputDir:   dir,
+	}
+	block := []util.Cell{{X: 3, Y: 3}, {X: 4, Y: 3}, {X: 3, Y: 4}, {X: 4, Y: 4}}
+	horizontal := []util.Cell{{X: 10, Y: 11}, {X: 11, Y: 11}, {X: 12, Y: 11}}
+	vertical := []util.Cell{{X: 11, Y: 10}, {X: 11, Y: 11}, {X: 11, Y: 12}}
+	edits := []gol.Edit{
+		//Cells beyond the edge of the torus wrap around onto the board
+		{Cells: []util.Cell{{X: 3, Y: 3}, {X: 20, Y: 3}, {X: 3, Y: -12}, {X: 4, Y: 4}}},
+		//Pasting over a cell that is already alive leaves it alive
+		{Cells: append([]util.Cell{{X: 3, Y: 3}}, horizontal...), Alive: true},
+	}
+
+	events := make(chan gol.Event)
+	keyPresses := make(chan rune, 10)
+	editChannel := make(chan gol.Edit, 10)
+	keyPresses <- 'p'
+	gol.RunInteractive(p, events, keyPresses, editChannel, nil)
+
+	flipped := map[util.Cell]bool{}
+	resumed := false
+	var final gol.FinalTurnComplete
+	for event := range events {
+		switch e := event.(type) {
+		case gol.StateChange:
+			if e.NewState == gol.Paused {
+				for _, edit := range edits {
+					editChannel <- edit
+				}
+			}
+		case gol.CellFlipped:
+			flipped[e.Cell] = !flipped[e.Cell]
+			//Resumes once every edited cell has been drawn, as a resume may be taken before an edit.
+			//Without the live view, the board is no longer drawn after resuming.
+			if len(flipped) == len(block)+len(horizontal) && !resumed {
+				for _, cell := range append(block, horizontal...) {
+					if !flipped[cell] {
+						t.Errorf("%v was not drawn alive after the edits", cell)
+					}
+				}
+				keyPresses <- 'p'
+				resumed = true
+			}
+		case gol.FinalTurnComplete:
+			final = e
+		}
+	}
+
+	if !resumed {
+		t.Fatalf("only %v cells were flipped by the edits", len(flipped))
+	}
+	//The blinker may be in either phase, as the turn the game paused on is not known
+	if !sameCells(final.Alive, append(append([]util.Cell{}, block...), horizontal...)) &&
+		!sameCells(final.Alive, append(append([]util.Cell{}, block...), vertical...)) {
+		t.Errorf("final board is %v, expected the block and the blinker", final.Alive)
+	}
+}
+
+//Returns whether two lists hold the same cells, in any order
+func sameCells(a []util.Cell, b []util.Cell) bool {
+	set := map[util.Cell]bool{}
+	for _, cell := range a {
+		set[cell] = true
+	}
+	if len(set) != len(a) || len(a) != len(b) {
+		return false
+	}
+	for _, cell := range b {
+		if !set[cell] {
+			return false
+		}
+	}
+	return true
+}
@@ -1,123 +1,390 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"net"
 	"net/rpc"
+	"strings"
+	"sync"
 	"time"
 
 	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
 )
 
 type Engine struct {
-	events             chan gol.Event
-	keyPresses         chan rune
-	keyPressEvents     chan gol.Event
-	tickerChan         chan bool
-	ticker             *time.Ticker
-	killChannel        chan bool
-	killConfirmChannel chan bool
-	gameRunning        bool
+	sessions       map[int]*session
+	lastSession    int
+	nextSession    int
+	subscribers    map[int]*subscriber
+	nextSubscriber int
+	lock           sync.Mutex
+	options        gol.DistributorOptions
+	shutdown       chan bool
+	rpcs           *rpcMetrics
+}
+
+func newEngine(options gol.DistributorOptions) *Engine {
+	return &Engine{make(map[int]*session), 0, 1, make(map[int]*subscriber), 1, sync.Mutex{}, options, make(chan bool), newRPCMetrics()}
+}
+
+//Creates a session for a new game. A new ID is chosen if id is 0.
+//The game does not begin until run is called, so that its controller can subscribe first.
+func (e *Engine) newSession(id int) *session {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if id == 0 {
+		id = e.nextSession
+	}
+	if id >= e.nextSession {
+		e.nextSession = id + 1
+	}
+	s := newSession(id)
+	e.sessions[id] = s
+	e.lastSession = id
+	return s
+}
+
+//Begins a session's game
+func (e *Engine) run(s *session, p gol.Params, alive []util.Cell, options gol.DistributorOptions) {
+	//Every session but the first saves its checkpoints to a file of its own
+	if s.id != 1 && options.CheckpointFile != "" {
+		options.CheckpointFile = fmt.Sprintf("%v.%v", options.CheckpointFile, s.id)
+	}
+	go func() {
+		gol.Distributor(p, alive, s.events, s.keyPressEvents, s.keyPresses, s.edits, s.edited, s.seeks, s.tickerChan, s.killChannel, s.killConfirmChannel, s.frames, s.resync, options)
+		close(s.done)
+	}()
+	go e.publish(s, p.ReportEvery())
+	fmt.Println("Started session", s.id)
+}
+
+//Finds a session by ID. ID 0 stands for the session started last.
+func (e *Engine) getSession(id int) (*session, bool) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if id == 0 {
+		id = e.lastSession
+	}
+	s, ok := e.sessions[id]
+	return s, ok
+}
+
+//Forgets a session once its game has ended, unless it has already been replaced.
+//Returns the number of sessions left.
+func (e *Engine) endSession(s *session) int {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if e.sessions[s.id] == s {
+		delete(e.sessions, s.id)
+	}
+	return len(e.sessions)
+}
+
+func (e *Engine) countSessions() int {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return len(e.sessions)
+}
+
+//Attaches a new subscriber to a session. Returns its ID and whether it controls the game.
+func (e *Engine) subscribe(s *session, observe bool) (int, bool) {
+	sub := &subscriber{session: s, reports: make(chan gol.TickReport, reportBuffer), frames: make(chan gol.Frame, 64)}
+	e.lock.Lock()
+	id := e.nextSubscriber
+	e.nextSubscriber++
+	e.subscribers[id] = sub
+	e.lock.Unlock()
+	return id, s.subscribe(id, sub, observe)
+}
+
+func (e *Engine) getSubscriber(id int) (*subscriber, bool) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	sub, ok := e.subscribers[id]
+	return sub, ok
+}
+
+func (e *Engine) removeSubscriber(id int) {
+	e.lock.Lock()
+	sub, ok := e.subscribers[id]
+	delete(e.subscribers, id)
+	e.lock.Unlock()
+	if ok {
+		sub.session.unsubscribe(id)
+	}
 }
 
 //Begin GoL execution
 func (e *Engine) Initialise(req gol.InitRequest, res *gol.StatusReport) (err error) {
+	defer e.rpcs.observe("Initialise", time.Now(), &err)
 	params := req.Params
 	if req.ShouldContinue == 0 {
-		if e.gameRunning {
-			e.killChannel <- true
-			<-e.killConfirmChannel
-			//time.Sleep(1 * time.Second)
-			e.emptyChannels()
+		//Restarting a session stops the game that was running in it
+		if s, ok := e.getSession(req.SessionID); ok && req.SessionID != 0 {
+			s.kill()
 		}
-		go gol.Distributor(params.Params, req.Params.Alive, e.events, e.keyPressEvents, e.keyPresses, e.tickerChan, e.killChannel, e.killConfirmChannel)
-		e.gameRunning = true
+		s := e.newSession(req.SessionID)
+		(*res).SessionID = s.id
+		(*res).SubscriberID, (*res).Controlling = e.subscribe(s, false)
+		e.run(s, params.Params, req.Params.Alive, e.options)
 	} else if req.ShouldContinue == 1 {
-		if !e.gameRunning {
+		s, ok := e.getSession(req.SessionID)
+		if !ok {
 			fmt.Println("Error: no game running. Creating new game.")
-			go gol.Distributor(params.Params, req.Params.Alive, e.events, e.keyPressEvents, e.keyPresses, e.tickerChan, e.killChannel, e.killConfirmChannel)
-			e.gameRunning = true
+			s = e.newSession(req.SessionID)
+			(*res).SubscriberID, (*res).Controlling = e.subscribe(s, false)
+			e.run(s, params.Params, req.Params.Alive, e.options)
 		} else {
-			e.keyPresses <- 'r'
+			(*res).SubscriberID, (*res).Controlling = e.subscribe(s, false)
+			if (*res).Controlling {
+				s.keyPresses <- 'r'
+			}
 		}
+		(*res).SessionID = s.id
 	} else {
 		fmt.Println("Incorrect flag value for continue. Must be either 0 or 1.")
 	}
 	return err
 }
 
-func (e *Engine) emptyChannels() {
-	for {
-		if len(e.events) > 0 {
-			<-e.events
-		}
-		if len(e.keyPresses) > 0 {
-			<-e.keyPresses
-		}
-		if len(e.keyPressEvents) > 0 {
-			<-e.keyPressEvents
-		}
-		if len(e.tickerChan) > 0 {
-			<-e.tickerChan
-		}
-		if len(e.killChannel) > 0 {
-			<-e.killChannel
-		}
-		if len(e.killConfirmChannel) > 0 {
-			<-e.killConfirmChannel
-		}
-		if len(e.events) == 0 && len(e.keyPresses) == 0 && len(e.keyPressEvents) == 0 && len(e.tickerChan) == 0 && len(e.killChannel) == 0 && len(e.killConfirmChannel) == 0 {
-			break
-		}
+//Attach to a running game as an observer, without starting or continuing it
+func (e *Engine) Subscribe(req gol.SubscribeRequest, res *gol.SubscribeReport) (err error) {
+	defer e.rpcs.observe("Subscribe", time.Now(), &err)
+	s, ok := e.getSession(req.SessionID)
+	if !ok {
+		return errors.New(fmt.Sprintf("no session %v on this engine", req.SessionID))
 	}
+	(*res).SessionID = s.id
+	(*res).SubscriberID, (*res).Controlling = e.subscribe(s, req.Observe)
+	return err
+}
+
+//Continue a game from a checkpoint. Controllers can then attach to it with -c 1.
+func (e *Engine) resume(checkpoint gol.Checkpoint) {
+	options := e.options
+	options.StartTurn = checkpoint.Turn
+	e.run(e.newSession(0), checkpoint.Params, checkpoint.Alive, options)
 }
 
+//Returns the next report for a subscriber, waiting for it if there is none queued
 func (e *Engine) Report(req gol.ReportRequest, res *gol.TickReport) (err error) {
-	e.tickerChan <- true
-	for {
+	defer e.rpcs.observe("Report", time.Now(), &err)
+	sub, ok := e.getSubscriber(req.SubscriberID)
+	if !ok {
+		return errors.New(fmt.Sprintf("no subscriber %v on this engine", req.SubscriberID))
+	}
+	//The distributor may be busy recovering from a failed worker
+	timeout := time.After(10 * time.Second)
+	select {
+	case report := <-sub.reports:
+		*res = report
+	default:
 		select {
-		case event := <-e.events:
-			switch t := event.(type) {
-			case gol.AliveCellsCount:
-				(*res).CellsCount = t.CellsCount
-				(*res).Turns = t.CompletedTurns
-				(*res).ReportType = gol.Ticking
-				return err
-			case gol.FinalTurnComplete:
-				(*res).Alive = t.Alive
-				(*res).Turns = t.CompletedTurns
-				(*res).ReportType = gol.Finished
-				e.gameRunning = false
-				e.emptyChannels()
-				return err
+		case report := <-sub.reports:
+			*res = report
+		case <-e.shutdown:
+			return errors.New("engine is shutting down")
+		case <-timeout:
+			if !sub.session.isSubscribed(req.SubscriberID) {
+				e.removeSubscriber(req.SubscriberID)
+				return errors.New("subscriber was dropped for not collecting its reports")
 			}
+			return errors.New("engine did not report in time")
+		}
+	}
+	if res.ReportType == gol.Finished {
+		e.removeSubscriber(req.SubscriberID)
+	}
+	return err
+}
+
+//Returns the frames of the turns completed since the last call, for live viewing.
+//Waits up to a second for the first frame, so that viewers do not poll in a busy loop.
+func (e *Engine) Frames(req gol.FramesRequest, res *gol.FramesReport) (err error) {
+	defer e.rpcs.observe("Frames", time.Now(), &err)
+	sub, ok := e.getSubscriber(req.SubscriberID)
+	if !ok {
+		return errors.New(fmt.Sprintf("no subscriber %v on this engine", req.SubscriberID))
+	}
+	if req.Resync {
+		sub.session.resyncSubscriber(sub)
+	}
+	select {
+	case <-e.shutdown:
+		return errors.New("engine is shutting down")
+	case <-time.After(time.Second):
+		return err
+	case frame := <-sub.frames:
+		(*res).Frames = append((*res).Frames, frame)
+	}
+	for len((*res).Frames) < cap(sub.frames) {
+		select {
+		case frame := <-sub.frames:
+			(*res).Frames = append((*res).Frames, frame)
+		default:
+			return err
 		}
 	}
 	return err
 }
 
+//Passes a key press on to a game. Only the controlling subscriber may press keys.
+//Other subscribers are told about the resulting state change.
 func (e *Engine) KeyPress(req gol.KeyPressRequest, res *gol.KeyPressReport) (err error) {
-	e.keyPresses <- req.Key
+	defer e.rpcs.observe("KeyPress", time.Now(), &err)
+	sub, ok := e.getSubscriber(req.SubscriberID)
+	if !ok {
+		if req.Key != 'k' {
+			return errors.New(fmt.Sprintf("no subscriber %v on this engine", req.SubscriberID))
+		}
+		(*res).State = gol.Quitting
+		if e.countSessions() == 0 {
+			e.stop()
+		}
+		return err
+	}
+	s := sub.session
+	if !s.isController(req.SubscriberID) {
+		return errors.New("only the controlling client can press keys")
+	}
+	s.keyPresses <- req.Key
 	select {
-	case k := <-e.keyPressEvents:
+	case k := <-s.keyPressEvents:
 		switch t := k.(type) {
 		case gol.StateChange:
 			(*res).Alive = t.Alive
 			(*res).Turns = t.CompletedTurns
 			(*res).State = t.NewState
 		}
+	case <-s.done:
+		return errors.New(fmt.Sprintf("session %v has already finished", s.id))
+	}
+	report := gol.TickReport{Turns: res.Turns, Alive: res.Alive, State: res.State, ReportType: gol.StateChanged}
+	s.broadcast(report, req.SubscriberID)
+	switch req.Key {
+	case 'q':
+		//The game is left paused for another controller to take over
+		e.removeSubscriber(req.SubscriberID)
+	case 'k':
+		//Observers are given the final board, as if the game had finished
+		report.ReportType = gol.Finished
+		s.broadcast(report, req.SubscriberID)
+		e.removeSubscriber(req.SubscriberID)
+		//Once the last game has been killed, stop accepting connections
+		//as soon as the controller has its final board
+		if e.endSession(s) == 0 {
+			e.stop()
+		}
+	}
+	return err
+}
+
+//Changes cells of a paused game. Only the controlling subscriber may edit the board.
+//Other subscribers that are viewing the game are sent the flipped cells as a frame.
+func (e *Engine) Edit(req gol.EditRequest, res *gol.EditReport) (err error) {
+	defer e.rpcs.observe("Edit", time.Now(), &err)
+	sub, ok := e.getSubscriber(req.SubscriberID)
+	if !ok {
+		return errors.New(fmt.Sprintf("no subscriber %v on this engine", req.SubscriberID))
+	}
+	s := sub.session
+	if !s.isController(req.SubscriberID) {
+		return errors.New("only the controlling client can edit the board")
+	}
+	select {
+	case s.edits <- req.Edit:
+	case <-s.done:
+		return errors.New(fmt.Sprintf("session %v has already finished", s.id))
+	}
+	select {
+	case *res = <-s.edited:
+	case <-s.done:
+		return errors.New(fmt.Sprintf("session %v has already finished", s.id))
+	}
+	return err
+}
+
+//Moves a paused game to another turn, replying once it has paused there. Only the
+//controlling subscriber may seek. Other subscribers are told about the state change.
+func (e *Engine) Seek(req gol.SeekRequest, res *gol.KeyPressReport) (err error) {
+	defer e.rpcs.observe("Seek", time.Now(), &err)
+	sub, ok := e.getSubscriber(req.SubscriberID)
+	if !ok {
+		return errors.New(fmt.Sprintf("no subscriber %v on this engine", req.SubscriberID))
+	}
+	s := sub.session
+	if !s.isController(req.SubscriberID) {
+		return errors.New("only the controlling client can seek")
+	}
+	select {
+	case s.seeks <- req.Turn:
+	case <-s.done:
+		return errors.New(fmt.Sprintf("session %v has already finished", s.id))
+	}
+	select {
+	case k := <-s.keyPressEvents:
+		if t, ok := k.(gol.StateChange); ok {
+			(*res).Alive = t.Alive
+			(*res).Turns = t.CompletedTurns
+			(*res).State = t.NewState
+		}
+	case <-s.done:
+		return errors.New(fmt.Sprintf("session %v has already finished", s.id))
 	}
+	s.broadcast(gol.TickReport{Turns: res.Turns, Alive: res.Alive, State: res.State, ReportType: gol.StateChanged}, req.SubscriberID)
 	return err
 }
 
+//Shuts down the worker nodes, then signals main to close the listener and exit
+func (e *Engine) stop() {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	select {
+	case <-e.shutdown:
+	default:
+		gol.ShutdownWorkerNodes(e.options.WorkerAddrs)
+		close(e.shutdown)
+	}
+}
+
 // main is the function called when starting Game of Life with 'go run .'
 func main() {
 	pAddr := flag.String("port", "8030", "Port to listen on")
+	workerAddrs := flag.String("workers", "", "Comma separated addresses of worker nodes. Uses local workers if empty")
+	workerTimeout := flag.Duration("timeout", 5*time.Second, "How long to wait for a worker node to finish a turn before recovering without it")
+	stallTimeout := flag.Duration("stall-timeout", 0, "Restart local workers if a turn takes longer than this, waiting twice as long after every restart. 0 never restarts them")
+	checkpointFile := flag.String("checkpoint", "", "File to save checkpoints of the running game to. Sessions after the first add their ID to the name. No checkpoints are saved if empty")
+	checkpointTurns := flag.Int("checkpoint-every", 0, "Save a checkpoint every this many turns. 0 to only use -checkpoint-interval")
+	checkpointInterval := flag.Duration("checkpoint-interval", time.Minute, "Save a checkpoint at most this often. 0 to only use -checkpoint-every")
+	resumeFile := flag.String("resume", "", "Checkpoint file to resume a game from")
+	metricsAddr := flag.String("metrics", "", "Address to serve Prometheus metrics on at /metrics, such as :9100. No metrics are served if empty")
 	flag.Parse()
-	rpc.Register(&Engine{make(chan gol.Event, 1000), make(chan rune, 10), make(chan gol.Event, 1000), make(chan bool, 10),
-		time.NewTicker(2 * time.Second), make(chan bool, 1), make(chan bool, 1), false})
-	listener, _ := net.Listen("tcp", ":"+*pAddr)
-	defer listener.Close()
-	rpc.Accept(listener)
+	options := gol.DistributorOptions{
+		WorkerTimeout:      *workerTimeout,
+		StallTimeout:       *stallTimeout,
+		CheckpointFile:     *checkpointFile,
+		CheckpointTurns:    *checkpointTurns,
+		CheckpointInterval: *checkpointInterval,
+	}
+	if *workerAddrs != "" {
+		options.WorkerAddrs = strings.Split(*workerAddrs, ",")
+	}
+	e := newEngine(options)
+	rpc.Register(e)
+	if *metricsAddr != "" {
+		go e.serveMetricsOn(*metricsAddr)
+	}
+	if *resumeFile != "" {
+		checkpoint, err := gol.ReadCheckpoint(*resumeFile)
+		util.Check(err)
+		fmt.Println("Resuming from turn", checkpoint.Turn)
+		e.resume(checkpoint)
+	}
+	listener, err := net.Listen("tcp", ":"+*pAddr)
+	util.Check(err)
+	gol.ServeRPC(listener, e.shutdown)
+	fmt.Println("Engine shut down")
 }
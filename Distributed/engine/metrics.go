@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+//How long to wait for a worker node to accept a connection before reporting it as down
+const workerProbeTimeout = 500 * time.Millisecond
+
+//rpcMetrics counts the calls to each of the engine's RPC methods, the ones that
+//failed and the time spent in them. Report and Frames wait for the game, so their
+//time includes waiting for the next report or frame.
+type rpcMetrics struct {
+	lock     sync.Mutex
+	calls    map[string]int
+	errors   map[string]int
+	duration map[string]time.Duration
+}
+
+func newRPCMetrics() *rpcMetrics {
+	return &rpcMetrics{calls: map[string]int{}, errors: map[string]int{}, duration: map[string]time.Duration{}}
+}
+
+//Records a call to a method that began at start. Deferred by every RPC method,
+//with a pointer to its error so that the error it returns is seen.
+func (m *rpcMetrics) observe(method string, start time.Time, err *error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.calls[method]++
+	m.duration[method] += time.Since(start)
+	if *err != nil {
+		m.errors[method]++
+	}
+}
+
+//sessionMetrics is a snapshot of one session, taken while writing the metrics
+type sessionMetrics struct {
+	id             int
+	turn           int
+	cells          int
+	turnsPerSecond float64
+	subscribers    int
+	controlled     bool
+}
+
+//Takes a snapshot of every session, in order of ID
+func (e *Engine) sessionMetrics() []sessionMetrics {
+	e.lock.Lock()
+	sessions := []*session{}
+	for _, s := range e.sessions {
+		sessions = append(sessions, s)
+	}
+	e.lock.Unlock()
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].id < sessions[j].id })
+
+	metrics := []sessionMetrics{}
+	for _, s := range sessions {
+		s.lock.Lock()
+		metrics = append(metrics, sessionMetrics{
+			id:             s.id,
+			turn:           s.turn,
+			cells:          s.cells,
+			turnsPerSecond: s.turnsPerSecond,
+			subscribers:    len(s.subscribers),
+			controlled:     s.controller != 0,
+		})
+		s.lock.Unlock()
+	}
+	return metrics
+}
+
+//Returns whether a worker node accepts connections
+func probeWorker(addr string) bool {
+	conn, err := net.DialTimeout("tcp", addr, workerProbeTimeout)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+//Prints metrics in the Prometheus text format
+type metricsWriter struct {
+	out io.Writer
+}
+
+//Writes the help and type lines of a metric, before its samples
+func (w metricsWriter) metric(name string, kind string, help string) {
+	fmt.Fprintf(w.out, "# HELP %v %v\n# TYPE %v %v\n", name, help, name, kind)
+}
+
+//Writes one sample of a metric. Labels are given as name and value pairs.
+func (w metricsWriter) sample(name string, value interface{}, labels ...string) {
+	fmt.Fprint(w.out, name)
+	for i := 0; i+1 < len(labels); i += 2 {
+		separator := ","
+		if i == 0 {
+			separator = "{"
+		}
+		fmt.Fprintf(w.out, "%v%v=%q", separator, labels[i], labels[i+1])
+	}
+	if len(labels) > 1 {
+		fmt.Fprint(w.out, "}")
+	}
+	fmt.Fprintf(w.out, " %v\n", value)
+}
+
+//Serves /metrics in the Prometheus text format: the progress and subscribers of every
+//session, the calls to each RPC method and whether each worker node can be reached
+func (e *Engine) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	out := metricsWriter{w}
+
+	sessions := e.sessionMetrics()
+	out.metric("gol_engine_sessions", "gauge", "Games running on the engine.")
+	out.sample("gol_engine_sessions", len(sessions))
+	out.metric("gol_engine_turn", "gauge", "Turns completed by each game.")
+	for _, s := range sessions {
+		out.sample("gol_engine_turn", s.turn, "session", fmt.Sprint(s.id))
+	}
+	out.metric("gol_engine_alive_cells", "gauge", "Alive cells of each game at its last report.")
+	for _, s := range sessions {
+		out.sample("gol_engine_alive_cells", s.cells, "session", fmt.Sprint(s.id))
+	}
+	out.metric("gol_engine_turns_per_second", "gauge", "Turns per second of each game between its last two reports.")
+	for _, s := range sessions {
+		out.sample("gol_engine_turns_per_second", s.turnsPerSecond, "session", fmt.Sprint(s.id))
+	}
+	out.metric("gol_engine_subscribers", "gauge", "Controllers and observers attached to each game.")
+	for _, s := range sessions {
+		out.sample("gol_engine_subscribers", s.subscribers, "session", fmt.Sprint(s.id))
+	}
+	out.metric("gol_engine_controlled", "gauge", "1 if a controller may press keys in the game.")
+	for _, s := range sessions {
+		controlled := 0
+		if s.controlled {
+			controlled = 1
+		}
+		out.sample("gol_engine_controlled", controlled, "session", fmt.Sprint(s.id))
+	}
+
+	e.rpcs.lock.Lock()
+	methods := []string{}
+	for method := range e.rpcs.calls {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	out.metric("gol_engine_rpc_duration_seconds", "summary", "Calls to each RPC method and the time spent in them.")
+	for _, method := range methods {
+		out.sample("gol_engine_rpc_duration_seconds_sum", e.rpcs.duration[method].Seconds(), "method", method)
+		out.sample("gol_engine_rpc_duration_seconds_count", e.rpcs.calls[method], "method", method)
+	}
+	out.metric("gol_engine_rpc_errors_total", "counter", "Calls to each RPC method that returned an error.")
+	for _, method := range methods {
+		out.sample("gol_engine_rpc_errors_total", e.rpcs.errors[method], "method", method)
+	}
+	e.rpcs.lock.Unlock()
+
+	out.metric("gol_engine_worker_up", "gauge", "1 if the worker node accepts connections.")
+	for _, addr := range e.options.WorkerAddrs {
+		up := 0
+		if probeWorker(addr) {
+			up = 1
+		}
+		out.sample("gol_engine_worker_up", up, "worker", addr)
+	}
+}
+
+//Serves the metrics on an address until the engine shuts down
+func (e *Engine) serveMetricsOn(addr string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", e.serveMetrics)
+	server := &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		<-e.shutdown
+		server.Close()
+	}()
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		fmt.Println("Error: could not serve metrics.", err)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// TestMetrics fills in an engine with one session, a controller, a failed RPC call and
+// two worker nodes, one of them down, then checks the samples served at /metrics.
+func TestMetrics(t *testing.T) {
+	up, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer up.Close()
+	down, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	down.Close()
+
+	e := newEngine(gol.DistributorOptions{WorkerAddrs: []string{up.Addr().String(), down.Addr().String()}})
+	s := e.newSession(0)
+	e.subscribe(s, false)
+	e.subscribe(s, true)
+	e.publishEvent(s, gol.AliveCellsCount{CompletedTurns: 42, CellsCount: 7})
+	e.publishEvent(s, gol.Telemetry{CompletedTurns: 42, TurnsPerSecond: 21})
+	if err := e.Subscribe(gol.SubscribeRequest{SessionID: 5}, new(gol.SubscribeReport)); err == nil {
+		t.Fatal("subscribed to a session that does not exist")
+	}
+	if err := e.Subscribe(gol.SubscribeRequest{SessionID: s.id, Observe: true}, new(gol.SubscribeReport)); err != nil {
+		t.Fatal(err)
+	}
+
+	response := httptest.NewRecorder()
+	e.serveMetrics(response, httptest.NewRequest("GET", "/metrics", nil))
+	body := response.Body.String()
+
+	for _, expected := range []string{
+		"# TYPE gol_engine_turn gauge",
+		"gol_engine_sessions 1",
+		`gol_engine_turn{session="1"} 42`,
+		`gol_engine_alive_cells{session="1"} 7`,
+		`gol_engine_turns_per_second{session="1"} 21`,
+		`gol_engine_subscribers{session="1"} 3`,
+		`gol_engine_controlled{session="1"} 1`,
+		`gol_engine_rpc_duration_seconds_count{method="Subscribe"} 2`,
+		`gol_engine_rpc_errors_total{method="Subscribe"} 1`,
+		`gol_engine_worker_up{worker="` + up.Addr().String() + `"} 1`,
+		`gol_engine_worker_up{worker="` + down.Addr().String() + `"} 0`,
+	} {
+		if !strings.Contains(body, expected+"\n") {
+			t.Errorf("metrics are missing %q:\n%v", expected, body)
+		}
+	}
+	if !strings.HasPrefix(response.Header().Get("Content-Type"), "text/plain") {
+		t.Errorf("metrics served as %v", response.Header().Get("Content-Type"))
+	}
+}
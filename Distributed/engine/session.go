@@ -0,0 +1,261 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+//The channels of one game running on the engine. Each session has its own
+//distributor, so several controllers can run games on one engine at once.
+type session struct {
+	id                 int
+	events             chan gol.Event
+	keyPresses         chan rune
+	keyPressEvents     chan gol.Event
+	edits              chan gol.Edit
+	edited             chan gol.EditReport
+	seeks              chan int
+	tickerChan         chan bool
+	killChannel        chan bool
+	killConfirmChannel chan bool
+	frames             chan gol.Frame
+	resync             chan bool
+	done               chan bool
+
+	lock        sync.Mutex
+	subscribers map[int]*subscriber
+	//Subscriber that may press keys, 0 if nobody controls the game
+	controller int
+	//Statistics of the turns completed since they were last published
+	stats []gol.TurnStats
+	//Progress of the game at its last report, for the metrics
+	turn           int
+	cells          int
+	turnsPerSecond float64
+}
+
+//Most reports queued for a subscriber. Each report interval queues several reports, so
+//this holds several minutes of them, and a subscriber is only dropped once it has stopped
+//collecting them for that long rather than when it is briefly slow.
+const reportBuffer = 1000
+
+//A client attached to a session. Reports and frames are queued for each
+//subscriber separately, so that every attached client sees the whole game.
+type subscriber struct {
+	session *session
+	reports chan gol.TickReport
+	frames  chan gol.Frame
+	//Set once the subscriber has asked for frames
+	viewing bool
+	//Set once frames have been dropped, until the next full frame
+	missedFrames bool
+}
+
+func newSession(id int) *session {
+	return &session{
+		id:                 id,
+		events:             make(chan gol.Event, 1000),
+		keyPresses:         make(chan rune, 10),
+		keyPressEvents:     make(chan gol.Event, 1000),
+		edits:              make(chan gol.Edit, 10),
+		edited:             make(chan gol.EditReport, 10),
+		seeks:              make(chan int, 10),
+		tickerChan:         make(chan bool, 10),
+		killChannel:        make(chan bool, 1),
+		killConfirmChannel: make(chan bool, 1),
+		frames:             make(chan gol.Frame, 64),
+		resync:             make(chan bool, 1),
+		done:               make(chan bool),
+		subscribers:        make(map[int]*subscriber),
+	}
+}
+
+//Stops the session's distributor, unless it has already returned
+func (s *session) kill() {
+	select {
+	case s.killChannel <- true:
+	case <-s.done:
+		return
+	}
+	select {
+	case <-s.killConfirmChannel:
+	case <-s.done:
+	}
+}
+
+//Adds a subscriber to the session. It takes control of the game if it is not
+//an observer and no other client holds control. Returns whether it did.
+func (s *session) subscribe(id int, sub *subscriber, observe bool) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.subscribers[id] = sub
+	if !observe && s.controller == 0 {
+		s.controller = id
+		return true
+	}
+	return false
+}
+
+//Removes a subscriber, giving up control of the game if it held it
+func (s *session) unsubscribe(id int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.subscribers, id)
+	if s.controller == id {
+		s.controller = 0
+	}
+}
+
+func (s *session) isSubscribed(id int) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	_, ok := s.subscribers[id]
+	return ok
+}
+
+func (s *session) isController(id int) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.controller == id
+}
+
+//Queues a report for every subscriber except the one given. Subscribers that have
+//not collected their reports for a long time, filling their queue, are assumed to have
+//gone, and are dropped.
+func (s *session) broadcast(report gol.TickReport, except int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for id, sub := range s.subscribers {
+		if id == except {
+			continue
+		}
+		select {
+		case sub.reports <- report:
+		default:
+			fmt.Println("Dropped subscriber", id, "of session", s.id)
+			delete(s.subscribers, id)
+			if s.controller == id {
+				s.controller = 0
+			}
+		}
+	}
+}
+
+//Passes a frame on to every subscriber that is viewing the game. A subscriber that
+//misses a frame skips the frames after it until the next full frame, which is asked for.
+func (s *session) broadcastFrame(frame gol.Frame) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, sub := range s.subscribers {
+		if !sub.viewing || (sub.missedFrames && !frame.Full) {
+			continue
+		}
+		select {
+		case sub.frames <- frame:
+			sub.missedFrames = false
+		default:
+			sub.missedFrames = true
+			s.requestResync()
+		}
+	}
+}
+
+//Throws away a subscriber's queued frames and asks the distributor for a full frame
+func (s *session) resyncSubscriber(sub *subscriber) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	sub.viewing = true
+	sub.missedFrames = true
+	for len(sub.frames) > 0 {
+		<-sub.frames
+	}
+	s.requestResync()
+}
+
+func (s *session) requestResync() {
+	select {
+	case s.resync <- true:
+	default:
+	}
+}
+
+//Most statistics of turns that are sent in one report
+const statsBatch = 10000
+
+//Passes the session's reports and frames on to its subscribers until the game ends.
+//Reports are asked for every interval, whether or not anybody is watching. The
+//statistics of the turns are published along with them, or sooner once a batch is full.
+func (e *Engine) publish(s *session, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.publishStats()
+			select {
+			case s.tickerChan <- true:
+			default:
+			}
+		case frame := <-s.frames:
+			s.broadcastFrame(frame)
+		case event := <-s.events:
+			if e.publishEvent(s, event) {
+				return
+			}
+		case <-s.done:
+			//The final turn may still be queued after the distributor has returned
+			for {
+				select {
+				case event := <-s.events:
+					if e.publishEvent(s, event) {
+						return
+					}
+				case frame := <-s.frames:
+					s.broadcastFrame(frame)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+//Sends an event from the distributor to the subscribers. Returns true once the game has finished.
+func (e *Engine) publishEvent(s *session, event gol.Event) bool {
+	switch t := event.(type) {
+	case gol.AliveCellsCount:
+		s.lock.Lock()
+		s.turn, s.cells = t.CompletedTurns, t.CellsCount
+		s.lock.Unlock()
+		s.broadcast(gol.TickReport{Turns: t.CompletedTurns, CellsCount: t.CellsCount, ReportType: gol.Ticking}, 0)
+	case gol.TurnStats:
+		s.stats = append(s.stats, t)
+		if len(s.stats) == statsBatch {
+			s.publishStats()
+		}
+	case gol.Telemetry:
+		s.lock.Lock()
+		s.turnsPerSecond = t.TurnsPerSecond
+		s.lock.Unlock()
+		s.broadcast(gol.TickReport{Turns: t.CompletedTurns, Telemetry: t, ReportType: gol.Measured}, 0)
+	case gol.CycleDetected:
+		s.broadcast(gol.TickReport{Turns: t.CompletedTurns, Period: t.Period, ReportType: gol.CycleFound}, 0)
+	case gol.FinalTurnComplete:
+		s.publishStats()
+		s.broadcast(gol.TickReport{Turns: t.CompletedTurns, Alive: t.Alive, ReportType: gol.Finished}, 0)
+		e.endSession(s)
+		return true
+	}
+	return false
+}
+
+//Sends the statistics of the turns completed since they were last published to every subscriber
+func (s *session) publishStats() {
+	if len(s.stats) > 0 {
+		s.broadcast(gol.TickReport{Turns: s.stats[len(s.stats)-1].CompletedTurns, Stats: s.stats, ReportType: gol.Statistics}, 0)
+		s.stats = nil
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// TestSlowSubscriber leaves the reports of one subscriber uncollected over many report
+// intervals, checking that it is kept while its queue has room and only dropped once
+// the queue is full.
+func TestSlowSubscriber(t *testing.T) {
+	e := newEngine(gol.DistributorOptions{})
+	s := e.newSession(0)
+	id, _ := e.subscribe(s, false)
+	//Every interval queues a count and telemetry
+	for turn := 0; turn < 100; turn++ {
+		e.publishEvent(s, gol.AliveCellsCount{CompletedTurns: turn})
+		e.publishEvent(s, gol.Telemetry{CompletedTurns: turn})
+	}
+	if !s.isSubscribed(id) {
+		t.Fatal("a subscriber that was slow for 100 intervals was dropped")
+	}
+	for turn := 0; turn < reportBuffer; turn++ {
+		e.publishEvent(s, gol.AliveCellsCount{CompletedTurns: turn})
+	}
+	if s.isSubscribed(id) {
+		t.Error("a subscriber that never collects its reports was kept")
+	}
+}
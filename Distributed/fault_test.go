@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestWorkerFailure kills one of three worker nodes while a 512x512 run is paused, then
+// resumes it and checks that the broker recovers and still produces the correct final board.
+func TestWorkerFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gol")
+	util.Check(err)
+	defer os.RemoveAll(dir)
+
+	//Each node runs two strips, which fail on the first turn after the node is killed
+	workers := []string{"127.0.0.1:8071", "127.0.0.1:8072", "127.0.0.1:8073"}
+	failing := startServer(t, dir, "worker", "-port", "8071")
+	defer stopServer(failing)
+	for _, port := range []string{"8072", "8073"} {
+		defer stopServer(startServer(t, dir, "worker", "-port", port))
+	}
+	engine := startServer(t, dir, "engine", "-port", "8043", "-timeout", "2s",
+		"-workers", fmt.Sprintf("%v,%v,%v", workers[0], workers[1], workers[2]))
+	defer stopServer(engine)
+
+	p := gol.ClientParams{
+		Turns:       100,
+		Threads:     6,
+		ImageWidth:  512,
+		ImageHeight: 512,
+		BrokerAddr:  "127.0.0.1:8043",
+		OutputDir:   dir,
+	}
+	expectedAlive := util.ReadAliveCells("check/images/512x512x100.pgm", p.ImageWidth, p.ImageHeight)
+	events := make(chan gol.Event)
+	keyPresses := make(chan rune, 10)
+	gol.Run(p, events, keyPresses)
+	keyPresses <- 'p'
+	killed := false
+	var final gol.FinalTurnComplete
+	for event := range events {
+		switch e := event.(type) {
+		case gol.StateChange:
+			if e.NewState == gol.Paused && !killed {
+				stopServer(failing)
+				killed = true
+				keyPresses <- 'p'
+			}
+		case gol.FinalTurnComplete:
+			final = e
+		}
+	}
+	if !killed {
+		t.Fatal("the game finished before it could be paused to kill a worker node")
+	}
+	if final.CompletedTurns != p.Turns {
+		t.Errorf("expected %v completed turns, got %v", p.Turns, final.CompletedTurns)
+	}
+	assertEqualBoard(t, final.Alive, expectedAlive, gol.ClientToEngineParams(p))
+}
@@ -0,0 +1,234 @@
+package gol
+
+import (
+	"math/bits"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+//BitBoard holds a strip of the world packed 64 cells to a word, with a bit set for
+//each alive cell. Cell x of a row is bit x%64 of word x/64, and the bits past the end
+//of a row are always 0.
+type BitBoard struct {
+	width  int
+	height int
+	rows   [][]uint64
+}
+
+//Returns the number of words needed to pack n cells
+func wordsFor(n int) int {
+	return (n + 63) / 64
+}
+
+func getBit(words []uint64, i int) bool {
+	return words[i/64]&(1<<uint(i%64)) != 0
+}
+
+func setBit(words []uint64, i int, value bool) {
+	if value {
+		words[i/64] |= 1 << uint(i%64)
+	} else {
+		words[i/64] &^= 1 << uint(i%64)
+	}
+}
+
+//NewBitBoard returns a board of dead cells
+func NewBitBoard(width int, height int) BitBoard {
+	b := BitBoard{width: width, height: height, rows: make([][]uint64, height)}
+	for y := range b.rows {
+		b.rows[y] = make([]uint64, wordsFor(width))
+	}
+	return b
+}
+
+//PackBoard packs a strip of the world, with alive cells stored as 255
+func PackBoard(world [][]byte, width int) BitBoard {
+	b := NewBitBoard(width, len(world))
+	for y, row := range world {
+		for x, cell := range row {
+			setBit(b.rows[y], x, cell == 255)
+		}
+	}
+	return b
+}
+
+//Unpacks the board into cells stored as 0 or 255
+func (b BitBoard) unpack() [][]byte {
+	world := make([][]byte, b.height)
+	for y := range world {
+		world[y] = make([]byte, b.width)
+		for x := range world[y] {
+			if getBit(b.rows[y], x) {
+				world[y][x] = 255
+			}
+		}
+	}
+	return world
+}
+
+//Returns the cells whose bits are set, with startY added to their rows
+func setCells(rows [][]uint64, startY int) []util.Cell {
+	count := 0
+	for _, row := range rows {
+		for _, word := range row {
+			count += bits.OnesCount64(word)
+		}
+	}
+	cells := make([]util.Cell, 0, count)
+	for y, row := range rows {
+		for i, word := range row {
+			for word != 0 {
+				cells = append(cells, util.Cell{X: i*64 + bits.TrailingZeros64(word), Y: y + startY})
+				word &= word - 1
+			}
+		}
+	}
+	return cells
+}
+
+//Returns the cells that are alive on this board and not on the previous one or
+//the other way round, with startY added to their rows
+func (b BitBoard) changedCells(previous BitBoard, startY int) []util.Cell {
+	count := 0
+	for y, row := range b.rows {
+		for i, word := range row {
+			count += bits.OnesCount64(word ^ previous.rows[y][i])
+		}
+	}
+	cells := make([]util.Cell, 0, count)
+	for y, row := range b.rows {
+		for i, word := range row {
+			for changed := word ^ previous.rows[y][i]; changed != 0; changed &= changed - 1 {
+				cells = append(cells, util.Cell{X: i*64 + bits.TrailingZeros64(changed), Y: y + startY})
+			}
+		}
+	}
+	return cells
+}
+
+//Returns the number of alive cells on the board
+func (b BitBoard) count() int {
+	count := 0
+	for _, row := range b.rows {
+		for _, word := range row {
+			count += bits.OnesCount64(word)
+		}
+	}
+	return count
+}
+
+//Returns the XOR of the cycle detector's keys of the cells that changed from the previous
+//board, with startY added to their rows. The hash of the whole board changes by this much.
+func (b BitBoard) changedHash(previous BitBoard, startY int) uint64 {
+	hash := uint64(0)
+	for y, row := range b.rows {
+		for i, word := range row {
+			for changed := word ^ previous.rows[y][i]; changed != 0; changed &= changed - 1 {
+				hash ^= cellKey(util.Cell{X: i*64 + bits.TrailingZeros64(changed), Y: y + startY})
+			}
+		}
+	}
+	return hash
+}
+
+//Adds a word of bits to 64 four bit counters at once. Bit i of s0 is the lowest bit
+//of counter i, and bit i of s3 its highest.
+func addBits(s0 *uint64, s1 *uint64, s2 *uint64, s3 *uint64, x uint64) {
+	carry := *s0 & x
+	*s0 ^= x
+	x = carry
+	carry = *s1 & x
+	*s1 ^= x
+	x = carry
+	carry = *s2 & x
+	*s2 ^= x
+	*s3 |= carry
+}
+
+//Returns a word with a bit set for every counter that is one of the counts set in mask
+func countsIn(mask uint16, s0 uint64, s1 uint64, s2 uint64, s3 uint64) uint64 {
+	matches := uint64(0)
+	for n := uint(0); n <= 8; n++ {
+		if mask&(1<<n) == 0 {
+			continue
+		}
+		match := ^uint64(0)
+		for i, s := range [4]uint64{s0, s1, s2, s3} {
+			if n&(1<<uint(i)) != 0 {
+				match &= s
+			} else {
+				match &^= s
+			}
+		}
+		matches |= match
+	}
+	return matches
+}
+
+//Returns word i of a row shifted so that each bit holds the cell to its left,
+//taking the cell left of the row from the halo
+func westOf(row []uint64, i int, left bool) uint64 {
+	word := row[i] << 1
+	if i > 0 {
+		word |= row[i-1] >> 63
+	} else if left {
+		word |= 1
+	}
+	return word
+}
+
+//Returns word i of a row shifted so that each bit holds the cell to its right,
+//taking the cell right of the row, which is width cells long, from the halo
+func eastOf(row []uint64, i int, right bool, width int) uint64 {
+	word := row[i] >> 1
+	if i < len(row)-1 {
+		word |= row[i+1] << 63
+	} else if right {
+		word |= 1 << uint((width-1)%64)
+	}
+	return word
+}
+
+//Executes one turn of the board into next, given the cells just outside it. Counts the alive
+//neighbours of 64 cells at once, by adding up the 8 rows shifted onto each cell's position.
+func (b BitBoard) step(next BitBoard, rule Rule, halo filler) {
+	birth, survival := rule.Birth, rule.Survival
+	if birth == 0 && survival == 0 {
+		birth, survival = Conway.Birth, Conway.Survival
+	}
+	last := wordsFor(b.width) - 1
+	lastMask := ^uint64(0) >> uint(64*(last+1)-b.width)
+	for y := 0; y < b.height; y++ {
+		above := halo.upperLine
+		if y > 0 {
+			above = b.rows[y-1]
+		}
+		below := halo.lowerLine
+		if y < b.height-1 {
+			below = b.rows[y+1]
+		}
+		row := b.rows[y]
+		//The halo's columns start on the line above the strip
+		aboveLeft, aboveRight := getBit(halo.leftColumn, y), getBit(halo.rightColumn, y)
+		left, right := getBit(halo.leftColumn, y+1), getBit(halo.rightColumn, y+1)
+		belowLeft, belowRight := getBit(halo.leftColumn, y+2), getBit(halo.rightColumn, y+2)
+		for i := 0; i <= last; i++ {
+			var s0, s1, s2, s3 uint64
+			addBits(&s0, &s1, &s2, &s3, westOf(above, i, aboveLeft))
+			addBits(&s0, &s1, &s2, &s3, above[i])
+			addBits(&s0, &s1, &s2, &s3, eastOf(above, i, aboveRight, b.width))
+			addBits(&s0, &s1, &s2, &s3, westOf(row, i, left))
+			addBits(&s0, &s1, &s2, &s3, eastOf(row, i, right, b.width))
+			addBits(&s0, &s1, &s2, &s3, westOf(below, i, belowLeft))
+			addBits(&s0, &s1, &s2, &s3, below[i])
+			addBits(&s0, &s1, &s2, &s3, eastOf(below, i, belowRight, b.width))
+
+			alive := row[i]
+			word := countsIn(survival, s0, s1, s2, s3)&alive | countsIn(birth, s0, s1, s2, s3)&^alive
+			if i == last {
+				word &= lastMask
+			}
+			next.rows[y][i] = word
+		}
+	}
+}
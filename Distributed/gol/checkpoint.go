@@ -0,0 +1,65 @@
+package gol
+
+import (
+	"encoding/gob"
+	"fmt"
+	"os"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+//Checkpoint holds everything needed to resume a game on a restarted engine
+type Checkpoint struct {
+	Turn   int
+	Params Params
+	Alive  []util.Cell
+}
+
+//WriteCheckpoint saves a checkpoint to a file. The checkpoint is written to a temporary
+//file first, so a crash while writing never leaves a broken checkpoint behind.
+func WriteCheckpoint(path string, checkpoint Checkpoint) error {
+	file, err := os.Create(path + ".tmp")
+	if err != nil {
+		return err
+	}
+	err = gob.NewEncoder(file).Encode(checkpoint)
+	if err == nil {
+		err = file.Sync()
+	}
+	closeErr := file.Close()
+	if err != nil {
+		return err
+	}
+	if closeErr != nil {
+		return closeErr
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+//ReadCheckpoint loads a checkpoint written by WriteCheckpoint
+func ReadCheckpoint(path string) (Checkpoint, error) {
+	checkpoint := Checkpoint{}
+	file, err := os.Open(path)
+	if err != nil {
+		return checkpoint, err
+	}
+	defer file.Close()
+	err = gob.NewDecoder(file).Decode(&checkpoint)
+	return checkpoint, err
+}
+
+//Writes a checkpoint in the background, unless the previous one is still being written
+func saveCheckpoint(path string, checkpoint Checkpoint, writing chan bool) {
+	select {
+	case writing <- true:
+	default:
+		return
+	}
+	go func() {
+		err := WriteCheckpoint(path, checkpoint)
+		if err != nil {
+			fmt.Println("Error: could not write checkpoint.", err)
+		}
+		<-writing
+	}()
+}
@@ -0,0 +1,121 @@
+package gol
+
+import "uk.ac.bris.cs/gameoflife/util"
+
+//Longest cycle that is looked for. The hashes of the boards of this many of the latest
+//turns are kept.
+const maxCyclePeriod = 1024
+
+//Returns a random looking key for an alive cell. The hash of a board is the XOR of the keys
+//of its alive cells, so that it can be kept up to date from the flipped cells without going
+//over the whole board.
+func cellKey(cell util.Cell) uint64 {
+	//The finaliser of splitmix64
+	z := uint64(uint32(cell.X))<<32 | uint64(uint32(cell.Y))
+	z += 0x9e3779b97f4a7c15
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+//Returns the hash of a board from its alive cells
+func boardHash(alive []util.Cell) uint64 {
+	hash := uint64(0)
+	for _, cell := range alive {
+		hash ^= cellKey(cell)
+	}
+	return hash
+}
+
+//cycleDetector finds the first turn on which the board is the same as on one of the turns
+//shortly before it. From then on the game only repeats those turns. Different boards can
+//have the same hash, so a repeated hash is only a candidate cycle, which is found once
+//the board after another period is exactly the board the hash repeated on.
+type cycleDetector struct {
+	hash uint64
+	//Latest turn that each of the recent hashes was seen on
+	seen map[uint64]int
+	//Hashes of the latest turns, indexed by the turn modulo maxCyclePeriod
+	recent []uint64
+	found  bool
+	//Turn that the hash of the candidate cycle repeated on, and its period. 0 if there is none.
+	candidate, period int
+	//Alive cells on the candidate turn
+	snapshot []util.Cell
+}
+
+//Returns a detector starting from a board with the given hash on a turn
+func newCycleDetector(hash uint64, turn int) *cycleDetector {
+	d := &cycleDetector{hash: hash, seen: map[uint64]int{}, recent: make([]uint64, maxCyclePeriod)}
+	d.record(turn)
+	return d
+}
+
+//Updates the hash of the board for cells that have been flipped
+func (d *cycleDetector) flip(flipped []util.Cell) {
+	for _, cell := range flipped {
+		d.hash ^= cellKey(cell)
+	}
+}
+
+//Updates the hash of the board from the XOR of the keys of the cells that have been flipped,
+//which the workers send instead of the cells themselves
+func (d *cycleDetector) flipKeys(keys uint64) {
+	d.hash ^= keys
+}
+
+//Checks the board after a turn against the boards of the recent turns. Returns the period
+//of the cycle the first time that one is found. The alive cells are only asked for when a
+//hash repeats, and when the board is checked against the one it repeated on.
+func (d *cycleDetector) turnComplete(turn int, alive func() []util.Cell) (int, bool) {
+	if d.found {
+		return 0, false
+	}
+	if d.period > 0 && turn == d.candidate+d.period {
+		period := d.period
+		d.period = 0
+		if sameCells(alive(), d.snapshot) {
+			d.found = true
+			return period, true
+		}
+		//The hash collided with the hash of another board
+		d.snapshot = nil
+	}
+	if last, ok := d.seen[d.hash]; ok && d.period == 0 {
+		d.candidate, d.period, d.snapshot = turn, turn-last, alive()
+	}
+	d.record(turn)
+	return 0, false
+}
+
+func (d *cycleDetector) record(turn int) {
+	slot := turn % maxCyclePeriod
+	if last, ok := d.seen[d.recent[slot]]; ok && last == turn-maxCyclePeriod {
+		delete(d.seen, d.recent[slot])
+	}
+	d.seen[d.hash] = turn
+	d.recent[slot] = d.hash
+}
+
+//Returns whether two lists hold the same cells, in any order
+func sameCells(a []util.Cell, b []util.Cell) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	cells := make(map[util.Cell]bool, len(a))
+	for _, cell := range a {
+		cells[cell] = true
+	}
+	for _, cell := range b {
+		if !cells[cell] {
+			return false
+		}
+	}
+	return true
+}
+
+//Returns the turns that can be skipped once a cycle of a period has been found on a turn,
+//which are the whole cycles that fit before the last turn
+func cycleSkip(p Params, turn int, period int) int {
+	return period * ((p.Turns - turn) / period)
+}
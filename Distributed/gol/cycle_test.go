@@ -0,0 +1,43 @@
+package gol
+
+import (
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestCycleCollision gives a board the hash of the empty board of turn 0, as if the two
+// had the same hash, and checks that no cycle is found until the board really repeats.
+func TestCycleCollision(t *testing.T) {
+	a, b := util.Cell{X: 1, Y: 2}, util.Cell{X: 3, Y: 4}
+	d := newCycleDetector(0, 0)
+	var alive []util.Cell
+	board := func() []util.Cell { return alive }
+
+	//Turn 1 has a cell alive but its hash collides with turn 0
+	alive = []util.Cell{a}
+	if period, ok := d.turnComplete(1, board); ok {
+		t.Fatalf("found a cycle of period %v on turn 1 from a collision", period)
+	}
+	d.hash = cellKey(a)
+	//Turn 2 is not the board of turn 1 either
+	d.flip([]util.Cell{a, b})
+	alive = []util.Cell{b}
+	if period, ok := d.turnComplete(2, board); ok {
+		t.Fatalf("found a cycle of period %v on turn 2 from a collision", period)
+	}
+
+	//From turn 3 the board goes back and forth between the two cells, which is a real
+	//cycle of period 2. The board of turn 2 comes back on turn 4, and again on turn 6.
+	for turn := 3; turn <= 6; turn++ {
+		d.flip([]util.Cell{a, b})
+		alive = []util.Cell{a}
+		if turn%2 == 0 {
+			alive = []util.Cell{b}
+		}
+		period, ok := d.turnComplete(turn, board)
+		if ok != (turn == 6) || ok && period != 2 {
+			t.Errorf("turn %v: got a cycle %v of period %v", turn, ok, period)
+		}
+	}
+}
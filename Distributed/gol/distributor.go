@@ -2,6 +2,8 @@ package gol
 
 import (
 	"fmt"
+	"net/rpc"
+	"time"
 
 	"uk.ac.bris.cs/gameoflife/util"
 )
@@ -10,21 +12,91 @@ type distributorChannels struct {
 	events         chan<- Event
 	keyPressEvents chan<- Event
 	keyPresses     <-chan rune
+	edits          <-chan Edit
+	edited         chan<- EditReport
+	seeks          <-chan int
 
 	workerEvents         chan Event
 	workerKeyPresses     []chan rune
 	fillers              []chan filler
 	globalFiller         chan filler
-	turnFinishedChannels []chan int
+	turnFinishedChannels []chan release
+	workerFetches        []chan bool
+	fetched              chan stripAlive
 	ticker               <-chan bool
 	killChan             <-chan bool
 	killConfirmChan      chan<- bool
 	workerKillChan       []chan bool
+	workerNodes          []*rpc.Client
+	options              DistributorOptions
+	frames               chan<- Frame
+	resync               <-chan bool
+}
+
+//Settings of the engine that are not part of a game's Params
+type DistributorOptions struct {
+	//Addresses of worker nodes. Strips are executed by local goroutines if empty.
+	WorkerAddrs []string
+	//How long to wait for a worker node to finish a turn before treating it as dead
+	WorkerTimeout time.Duration
+	//How long local workers may take over a turn before they are restarted, which is
+	//doubled after every restart. 0 never restarts them, as only a panic shows that a
+	//local worker has failed.
+	StallTimeout time.Duration
+	//File to save checkpoints to. No checkpoints are saved if empty.
+	CheckpointFile string
+	//Save a checkpoint every this many turns, if greater than 0
+	CheckpointTurns int
+	//Save a checkpoint at most this often, if greater than 0
+	CheckpointInterval time.Duration
+	//Turn that the given alive cells are from, when resuming from a checkpoint
+	StartTurn int
 }
 
 // distributor divides the work between workers and interacts with other goroutines.
 func Distributor(p Params, alive []util.Cell, events chan Event, keyPressEvents chan Event, keyPresses chan rune,
-	ticker chan bool, killChan <-chan bool, killConfirmChan chan<- bool) ([]util.Cell, int) {
+	edits <-chan Edit, edited chan<- EditReport, seeks <-chan int, ticker chan bool, killChan <-chan bool, killConfirmChan chan<- bool, frames chan<- Frame, resync <-chan bool,
+	options DistributorOptions) ([]util.Cell, int) {
+	c := distributorChannels{
+		events:          events,
+		keyPressEvents:  keyPressEvents,
+		keyPresses:      keyPresses,
+		edits:           edits,
+		edited:          edited,
+		seeks:           seeks,
+		ticker:          ticker,
+		killChan:        killChan,
+		killConfirmChan: killConfirmChan,
+		workerNodes:     []*rpc.Client{},
+		options:         options,
+		frames:          frames,
+		resync:          resync,
+	}
+
+	fmt.Println("Began new GoL")
+	if len(options.WorkerAddrs) > 0 {
+		clients, err := dialWorkers(options.WorkerAddrs)
+		if err != nil {
+			fmt.Println("Error: could not reach worker nodes, using local workers instead.", err)
+		} else {
+			c.workerNodes = clients
+		}
+	}
+	c = startWorkers(p, c, alive, options.StartTurn, false, p.Stats != "")
+
+	aliveCells, turn := handleChannels(p, c, alive, options.StartTurn)
+	// Make sure that the Io has finished any output before exiting.
+	//c.events <- StateChange{turn, Quitting}
+	// Close the channel to stop the SDL goroutine gracefully. Removing may cause deadlock.
+	//close(c.events)
+
+	return aliveCells, turn
+}
+
+//Splits the world into strips and starts a worker for each, beginning at the given turn,
+//paused if the game is and sending the cells flipped if asked to. Every call creates a fresh
+//set of worker channels, so that nothing sent by an earlier set of workers can reach the distributor.
+func startWorkers(p Params, c distributorChannels, alive []util.Cell, turn int, paused bool, flipped bool) distributorChannels {
 	world := make([][]byte, p.ImageHeight)
 	for i := range world {
 		world[i] = make([]byte, p.ImageWidth)
@@ -33,43 +105,30 @@ func Distributor(p Params, alive []util.Cell, events chan Event, keyPressEvents
 		world[c.Y][c.X] = 255
 	}
 
-	workerEvents := make(chan Event, 1000)
-	globalFiller := make(chan filler, 10)
+	c.workerEvents = make(chan Event, 1000)
+	c.globalFiller = make(chan filler, 10)
+	c.workerKeyPresses = make([]chan rune, p.Threads)
+	c.fillers = make([]chan filler, p.Threads)
+	c.turnFinishedChannels = make([]chan release, p.Threads)
+	c.workerKillChan = make([]chan bool, p.Threads)
+	c.workerFetches = make([]chan bool, p.Threads)
+	c.fetched = make(chan stripAlive, p.Threads)
 
-	c := distributorChannels{
-		events:               events,
-		keyPressEvents:       keyPressEvents,
-		keyPresses:           keyPresses,
-		workerEvents:         workerEvents,
-		workerKeyPresses:     make([]chan rune, p.Threads),
-		fillers:              make([]chan filler, p.Threads),
-		globalFiller:         globalFiller,
-		turnFinishedChannels: make([]chan int, p.Threads),
-		ticker:               ticker,
-		killChan:             killChan,
-		killConfirmChan:      killConfirmChan,
-		workerKillChan:       make([]chan bool, p.Threads),
-	}
+	job := time.Now().UnixNano()
 
-	fmt.Println("Began new GoL")
-	//TODO: Initialise semaphores for locking finished workers
-	turn := 0
-	threadHeight := float32(p.ImageHeight) / float32(p.Threads)
-
-	//TODO: Split image, send worker goroutines
 	for t := 0; t < p.Threads; t++ {
-		endY := int(float32(t+1) * threadHeight)
-		if t == p.Threads-1 {
-			endY = p.ImageHeight
-		}
-		startY := int(float32(t) * threadHeight)
+		startY, endY := stripBounds(p, t)
 
 		fillerElement := make(chan filler, 10)
 		c.fillers[t] = fillerElement
 
-		finishedChannel := make(chan int)
+		//Buffered so that a stalled worker cannot block the distributor
+		finishedChannel := make(chan release, 1)
 		c.turnFinishedChannels[t] = finishedChannel
 
+		fetch := make(chan bool, 1)
+		c.workerFetches[t] = fetch
+
 		keyPress := make(chan rune, 10)
 		c.workerKeyPresses[t] = keyPress
 
@@ -82,64 +141,529 @@ func Distributor(p Params, alive []util.Cell, events chan Event, keyPressEvents
 			ImageWidth:  p.ImageWidth,
 			ImageHeight: endY - startY,
 			Turns:       p.Turns,
+			StartTurn:   turn,
+			Paused:      paused,
+			Flipped:     flipped,
+			Timeout:     c.options.WorkerTimeout,
+			Rule:        p.Rule,
 		}
 		workerChannels := workerChannels{
-			events:          workerEvents,
-			globalFiller:    globalFiller,
+			events:          c.workerEvents,
+			globalFiller:    c.globalFiller,
 			workerFiller:    fillerElement,
 			finishedChannel: finishedChannel,
 			keyPresses:      keyPress,
 			killChan:        killChan,
+			fetch:           fetch,
+			fetched:         c.fetched,
 		}
-		go worker(world[startY:endY], workerParams, workerChannels, t)
+		if len(c.workerNodes) > 0 {
+			client := c.workerNodes[t%len(c.workerNodes)]
+			key := StripKey{Job: job, Strip: t}
+			request := StripRequest{Key: key, StartY: startY, World: world[startY:endY], Params: p}
+			err := client.Call(WorkerInitialise, request, new(StripReport))
+			if err == nil {
+				go remoteWorker(client, key, world[startY:endY], workerParams, workerChannels, t)
+				continue
+			}
+			fmt.Println("Error: could not send strip", t, "to its worker node, running it locally.", err)
+		}
+		go localWorker(world[startY:endY], workerParams, workerChannels, t)
 	}
+	return c
+}
 
-	aliveCells, turn := handleChannels(p, c)
-	// Make sure that the Io has finished any output before exiting.
-	//c.events <- StateChange{turn, Quitting}
-	// Close the channel to stop the SDL goroutine gracefully. Removing may cause deadlock.
-	//close(c.events)
+//Stops every worker after a worker has died or stalled, drops the worker nodes
+//that the failed workers were running on, then splits the board of a completed
+//turn between the workers that are left.
+func recoverWorkers(p Params, c distributorChannels, failed map[int]bool, alive []util.Cell, turn int, paused bool, flipped bool) distributorChannels {
+	killWorkers(c)
+	if len(c.workerNodes) > 0 {
+		failedNodes := map[int]bool{}
+		for t := range failed {
+			failedNodes[t%len(c.workerNodes)] = true
+		}
+		nodes := []*rpc.Client{}
+		for i, node := range c.workerNodes {
+			if failedNodes[i] {
+				node.Close()
+			} else {
+				nodes = append(nodes, node)
+			}
+		}
+		c.workerNodes = nodes
+	}
+	fmt.Println("Recovering from turn", turn, "with", len(c.workerNodes), "worker nodes")
+	return startWorkers(p, c, alive, turn, paused, flipped)
+}
 
-	return aliveCells, turn
+//Returns the rows of the world that a worker works on, from startY up to but not including endY
+func stripBounds(p Params, t int) (int, int) {
+	threadHeight := float32(p.ImageHeight) / float32(p.Threads)
+	endY := int(float32(t+1) * threadHeight)
+	if t == p.Threads-1 {
+		endY = p.ImageHeight
+	}
+	return int(float32(t) * threadHeight), endY
+}
+
+//Sends each worker the cells just outside its strip, once every worker has sent its edges.
+//The edges of the world are joined as the topology says.
+func sendLinesToWorkers(p Params, edges []filler, c distributorChannels) {
+	for t, halo := range buildHalos(p, edges) {
+		c.fillers[t] <- halo
+	}
 }
 
-func sendLinesToWorker(p Params, f filler, c distributorChannels) {
-	worker := f.workerID
-	upperWorker := (worker + 1) % p.Threads
-	lowerWorker := (worker - 1)
-	if lowerWorker < 0 {
-		lowerWorker = lowerWorker + p.Threads
+//Returns the cells just outside each worker's strip, from the edges of all strips
+func buildHalos(p Params, edges []filler) []filler {
+	halos := make([]filler, p.Threads)
+	for t := range halos {
+		startY, endY := stripBounds(p, t)
+		halo := filler{
+			lowerLine:   make([]uint64, wordsFor(p.ImageWidth)),
+			upperLine:   make([]uint64, wordsFor(p.ImageWidth)),
+			leftColumn:  make([]uint64, wordsFor(endY-startY+2)),
+			rightColumn: make([]uint64, wordsFor(endY-startY+2)),
+			workerID:    t,
+		}
+		for x := 0; x < p.ImageWidth; x++ {
+			setBit(halo.upperLine, x, edgeCell(p, edges, x, startY-1))
+			setBit(halo.lowerLine, x, edgeCell(p, edges, x, endY))
+		}
+		for y := startY - 1; y <= endY; y++ {
+			setBit(halo.leftColumn, y-startY+1, edgeCell(p, edges, -1, y))
+			setBit(halo.rightColumn, y-startY+1, edgeCell(p, edges, p.ImageWidth, y))
+		}
+		halos[t] = halo
 	}
-	c.fillers[upperWorker] <- filler{lowerLine: nil, upperLine: f.upperLine, workerID: worker}
-	c.fillers[lowerWorker] <- filler{lowerLine: f.lowerLine, upperLine: nil, workerID: worker}
+	return halos
+}
+
+//Returns whether a cell next to a strip is alive. It is either on the edge of another
+//strip or, once it has been wrapped onto the world, on the edge of the world.
+func edgeCell(p Params, edges []filler, x int, y int) bool {
+	x, y, ok := p.Topology.Wrap(x, y, p.ImageWidth, p.ImageHeight)
+	if !ok {
+		return false
+	}
+	for t, edge := range edges {
+		startY, endY := stripBounds(p, t)
+		switch {
+		case y < startY || y >= endY:
+			continue
+		case y == startY:
+			return getBit(edge.lowerLine, x)
+		case y == endY-1:
+			return getBit(edge.upperLine, x)
+		case x == 0:
+			return getBit(edge.leftColumn, y-startY)
+		default:
+			return getBit(edge.rightColumn, y-startY)
+		}
+	}
+	return false
+}
+
+//Asks every worker for the alive cells of its strip. The workers must all be paused or
+//waiting for the next turn, so that the strips are from the same turn. Returns the cells,
+//along with the workers that could not send theirs.
+func fetchAlive(c distributorChannels) ([]util.Cell, map[int]bool) {
+	for _, fetch := range c.workerFetches {
+		fetch <- true
+	}
+	alive := []util.Cell{}
+	failed := map[int]bool{}
+	for range c.workerFetches {
+		s := <-c.fetched
+		if s.err != nil {
+			fmt.Println("Error: could not fetch the cells of worker", s.workerID, s.err)
+			failed[s.workerID] = true
+			continue
+		}
+		alive = append(alive, s.alive...)
+	}
+	return alive, failed
+}
+
+//Stops all workers, wherever they are blocked
+func killWorkers(c distributorChannels) {
+	for _, e := range c.workerKillChan {
+		close(e)
+	}
+}
+
+//Restarts a timer, discarding any expiry that has not been received yet
+func resetTimer(timer *time.Timer, d time.Duration) {
+	if !timer.Stop() {
+		select {
+		case <-timer.C:
+		default:
+		}
+	}
+	timer.Reset(d)
+}
+
+//Offers a frame to live viewers without ever waiting for them, so that a slow viewer
+//cannot hold the game back. Once a frame has been dropped, the next frame sent holds
+//the whole board instead of one turn's flips. Returns whether a full frame is still owed.
+func sendFrame(c distributorChannels, frame Frame, alive []util.Cell, resync bool) bool {
+	if resync {
+		frame = Frame{Turn: frame.Turn, Alive: alive, Full: true}
+	}
+	select {
+	case c.frames <- frame:
+		return false
+	default:
+		return true
+	}
+}
+
+//Decides whether a checkpoint is due after a turn has been completed
+func checkpointDue(options DistributorOptions, turn int, lastCheckpoint time.Time) bool {
+	if options.CheckpointFile == "" {
+		return false
+	}
+	if options.CheckpointTurns > 0 && turn%options.CheckpointTurns == 0 {
+		return true
+	}
+	return options.CheckpointInterval > 0 && time.Since(lastCheckpoint) >= options.CheckpointInterval
+}
+
+//Most boards kept to start the workers again from, besides the board that the game started from
+const maxSnapshots = 16
+
+//How often the board is fetched from the workers to be kept, if it has not been fetched for anything else
+const snapshotInterval = time.Second
+
+//The board of an earlier turn, which the workers can be started again from after one of
+//them has failed, or to go back further than the history reaches
+type snapshot struct {
+	turn  int
+	alive []util.Cell
 }
 
-func handleChannels(p Params, c distributorChannels) ([]util.Cell, int) {
+//Runs the game as the workers complete its turns. The workers only send the number of
+//cells alive after each turn, so the board is fetched from them when it is needed, e.g. to
+//save it, once they have finished a turn or while they are paused. The cells flipped by each
+//turn are only sent while there is a live viewer or the statistics are written.
+func handleChannels(p Params, c distributorChannels, alive []util.Cell, startTurn int) ([]util.Cell, int) {
 	isDone := false
 	aliveCells := []util.Cell{}
 	workersCompletedTurn := 0
+	edges := make([]filler, p.Threads)
+	workersSentEdges := 0
 	workersFinished := 0
-	turn := 0
+	turn := startTurn
 	isPaused := false
 
-	prevTurnAliveCells := []util.Cell{}
-	workingAliveCells := []util.Cell{}
+	lastCheckpoint := time.Now()
+	writingCheckpoint := make(chan bool, 1)
+
+	//Alive cells of the current turn, if they have been fetched since it was completed
+	current := alive
+	fetched := true
+	//Boards that the workers can be started again from, oldest first
+	snapshots := []snapshot{{startTurn, alive}}
+	lastSnapshot := time.Now()
+	//Number of cells alive after the current turn
+	count := len(alive)
+	workingCount := 0
+	workingHash := uint64(0)
+	workingFlipped := []util.Cell{}
+	//Set once a live viewer has asked for frames. Viewers start from an empty board, so the
+	//first frame must be a full one.
+	viewing := false
+	resync := true
+	wantFlips := func() bool {
+		return viewing || p.Stats != ""
+	}
+	//Whether the workers send the cells flipped by the turn they are running
+	flipping := wantFlips()
+	//Keys pressed while the workers were running a turn that need the board, which are
+	//handled once every worker has finished it
+	pending := []rune{}
+	//Set once the workers have been started again while a turn was being handled
+	restarted := false
+	killed := false
+
+	//A turn of the local workers that never ends is not reported as a failure, so if asked
+	//to, restart them once a turn takes too long, waiting twice as long after each restart
+	stallTimeout := c.options.StallTimeout
+	stallTimer := time.NewTimer(stallTimeout)
+	stalled := stallTimer.C
+	if stallTimeout <= 0 || len(c.workerNodes) > 0 {
+		stallTimer.Stop()
+		stalled = nil
+	}
+
+	telemetry := newTelemetryMeter(p.Threads, startTurn)
+	past := newHistory(p.History)
+	//Turn that a step or seek of the paused game runs to, 0 if it is not running. The
+	//controller is told the game has paused once it is reached.
+	stopAt := 0
+	cycles := newCycleDetector(boardHash(alive), startTurn)
+	//The statistics of every turn are sent to the engine if the controller writes them.
+	//Turns run again after a failure are not sent twice.
+	var stats statsBoard
+	statsTurn := startTurn
+	if p.Stats != "" {
+		stats = newStatsBoard(alive)
+	}
+	resetWorkers := func() {
+		workersCompletedTurn = 0
+		workersSentEdges = 0
+		workingCount = 0
+		workingHash = 0
+		workingFlipped = nil
+		workersFinished = 0
+		aliveCells = nil
+	}
+	//Keeps a board to start the workers again from. The boards of later turns are dropped,
+	//as the game may have gone another way since.
+	keep := func(turn int, alive []util.Cell) {
+		i := len(snapshots)
+		for i > 0 && snapshots[i-1].turn >= turn {
+			i--
+		}
+		snapshots = append(snapshots[:i], snapshot{turn, alive})
+		if len(snapshots) > maxSnapshots+1 {
+			snapshots = append(snapshots[:1], snapshots[2:]...)
+		}
+		lastSnapshot = time.Now()
+	}
+	var frame func(flipped []util.Cell)
+	//Starts the workers again from the latest board kept after a worker has failed or
+	//stalled. The turns completed since then are run again.
+	recoverFromSnapshot := func(failed map[int]bool) {
+		s := snapshots[len(snapshots)-1]
+		turn = s.turn
+		flipping = wantFlips()
+		c = recoverWorkers(p, c, failed, s.alive, turn, isPaused, flipping)
+		resetWorkers()
+		current, fetched, count = s.alive, true, len(s.alive)
+		past.clear()
+		cycles = newCycleDetector(boardHash(s.alive), turn)
+		if p.Stats != "" {
+			stats = newStatsBoard(s.alive)
+		}
+		restarted = true
+		resync = true
+		if isPaused {
+			frame(nil)
+		}
+	}
+	//Returns the alive cells of the current turn, fetching them from the workers unless that
+	//has been done already. The workers must be paused or waiting for the next turn. If a
+	//worker cannot send its cells, the workers are started again from the latest board kept,
+	//which is returned instead along with false.
+	board := func() ([]util.Cell, bool) {
+		if fetched {
+			return current, true
+		}
+		alive, failed := fetchAlive(c)
+		if len(failed) > 0 {
+			recoverFromSnapshot(failed)
+			return current, false
+		}
+		current, fetched = alive, true
+		keep(turn, alive)
+		return alive, true
+	}
+	//Offers live viewers the frame of the current turn, with the whole board if one is owed
+	frame = func(flipped []util.Cell) {
+		if !viewing {
+			return
+		}
+		var alive []util.Cell
+		if resync {
+			var ok bool
+			if alive, ok = board(); !ok {
+				return
+			}
+		}
+		resync = sendFrame(c, Frame{Turn: turn, Flipped: flipped}, alive, resync)
+	}
+	//Starts the workers again from another board of a paused game, paused unless they
+	//are to run on to a later turn. Live viewers are sent the cells that were flipped.
+	restart := func(alive []util.Cell, flipped []util.Cell, paused bool) {
+		killWorkers(c)
+		flipping = wantFlips()
+		c = startWorkers(p, c, alive, turn, paused, flipping)
+		resetWorkers()
+		isPaused = paused
+		current, fetched, count = alive, true, len(alive)
+		keep(turn, alive)
+		frame(flipped)
+		cycles = newCycleDetector(boardHash(alive), turn)
+		if p.Stats != "" {
+			stats.flip(flipped)
+			statsTurn = turn
+		}
+	}
+	//Moves the paused game towards a turn, through the history and then by running to it.
+	//Turns further back than the history goes are run again from the latest board kept before them.
+	seek := func(target int) {
+		if target > p.Turns {
+			target = p.Turns
+		}
+		if target < startTurn {
+			target = startTurn
+		}
+		alive, _ := board()
+		flipped, reached := past.seek(turn, target)
+		if reached > target {
+			s := snapshots[0]
+			for _, kept := range snapshots {
+				if kept.turn <= target {
+					s = kept
+				}
+			}
+			turn = s.turn
+			past.clear()
+			resync = true
+			if p.Stats != "" {
+				stats = newStatsBoard(s.alive)
+			}
+			restart(s.alive, nil, s.turn >= target)
+		} else {
+			turn = reached
+			restart(flipCells(alive, flipped), flipped, reached >= target)
+		}
+		if turn < target {
+			stopAt = target
+			return
+		}
+		c.keyPressEvents <- StateChange{turn, Paused, current}
+	}
+	//Handles a key that needs the board, while the workers are paused or waiting for the
+	//next turn. Returns false if the workers had to be started again and are running.
+	boardKey := func(k rune) bool {
+		alive, ok := board()
+		if !ok && !isPaused {
+			return false
+		}
+		switch k {
+		case 'p':
+			isPaused = true
+			c.keyPressEvents <- StateChange{turn, Paused, alive}
+		case 's':
+			c.keyPressEvents <- StateChange{turn, Saving, alive}
+		case 'q':
+			c.keyPressEvents <- StateChange{turn, Quitting, alive}
+			isPaused = true
+		case 'k':
+			c.keyPressEvents <- StateChange{turn, Quitting, alive}
+			killWorkers(c)
+			closeWorkerNodes(c.workerNodes)
+			killed = true
+		}
+		return true
+	}
+	//Handles a turn once every worker has finished it, then lets the workers go on to the
+	//next turn. Returns early if the workers had to be started again in the meantime.
+	endTurn := func() {
+		restarted = false
+		flipped := workingFlipped
+		if flipping {
+			past.add(historyEntry{from: turn, to: turn + 1, flipped: flipped})
+		} else {
+			//The turn cannot be stepped back over, nor shown, without the cells it flipped
+			past.clear()
+			resync = true
+		}
+		count = workingCount
+		cycles.flipKeys(workingHash)
+		workersCompletedTurn = 0
+		workingCount = 0
+		workingHash = 0
+		workingFlipped = nil
+		fetched = false
+		turn++
+		frame(flipped)
+		if restarted {
+			return
+		}
+		if p.Stats != "" {
+			turnStats := stats.turn(turn, flipped)
+			if turn > statsTurn {
+				c.events <- turnStats
+				statsTurn = turn
+			}
+		}
+		period, ok := cycles.turnComplete(turn, func() []util.Cell {
+			alive, _ := board()
+			return alive
+		})
+		if restarted {
+			return
+		}
+		if ok {
+			c.events <- CycleDetected{CompletedTurns: turn, Period: period}
+			//Steps and seeks run to their turn, however many cycles fit before it
+			if skip := cycleSkip(p, turn, period); p.SkipCycles && stopAt == 0 && skip > 0 {
+				past.add(historyEntry{from: turn, to: turn + skip})
+				turn += skip
+			}
+		}
+		if checkpointDue(c.options, turn, lastCheckpoint) {
+			alive, ok := board()
+			if !ok {
+				return
+			}
+			saveCheckpoint(c.options.CheckpointFile, Checkpoint{Turn: turn, Params: p, Alive: alive}, writingCheckpoint)
+			lastCheckpoint = time.Now()
+		}
+		//The workers take the pause before they are told to start the next turn
+		if turn == stopAt {
+			alive, ok := board()
+			if !ok {
+				return
+			}
+			stopAt = 0
+			isPaused = true
+			c.keyPressEvents <- StateChange{turn, Paused, alive}
+		}
+		for len(pending) > 0 {
+			if pending[0] == 'p' && isPaused {
+				//Pressed again after the pause, so the workers go on once they are released
+				isPaused = false
+				c.keyPressEvents <- StateChange{turn, Executing, nil}
+			} else if !boardKey(pending[0]) {
+				return
+			}
+			pending = pending[1:]
+			if killed {
+				return
+			}
+		}
+		if !fetched && time.Since(lastSnapshot) >= snapshotInterval {
+			if _, ok := board(); !ok {
+				return
+			}
+		}
+		//Send all clear to workers to start next turn
+		flipping = wantFlips()
+		for i := 0; i < p.Threads; i++ {
+			c.turnFinishedChannels[i] <- release{turn: turn, pause: isPaused, flipped: flipping}
+		}
+	}
 
 	for {
 		select {
 		case event := <-c.workerEvents:
 			switch e := event.(type) {
 			case WorkerTurnComplete:
+				resetTimer(stallTimer, stallTimeout)
+				telemetry.turnComplete(e.WorkerID)
 				workersCompletedTurn++
-				workingAliveCells = append(workingAliveCells, e.Alive...)
+				workingCount += e.CellsCount
+				workingHash ^= e.Hash
+				workingFlipped = append(workingFlipped, e.Flipped...)
 				if workersCompletedTurn == p.Threads {
-					workersCompletedTurn = 0
-					prevTurnAliveCells = workingAliveCells
-					workingAliveCells = nil
-					turn++
-					//Send all clear to workers to start next turn
-					for i := 0; i < p.Threads; i++ {
-						c.turnFinishedChannels[i] <- turn
+					endTurn()
+					if killed {
+						return current, turn
 					}
 				}
 			case WorkerFinalTurnComplete:
@@ -149,41 +673,109 @@ func handleChannels(p Params, c distributorChannels) ([]util.Cell, int) {
 					c.events <- FinalTurnComplete{CompletedTurns: turn, Alive: aliveCells}
 					isDone = true
 				}
+			case WorkerFailed:
+				fmt.Println("Worker", e.WorkerID, "failed during turn", turn)
+				failed := map[int]bool{}
+				if e.Remote {
+					failed[e.WorkerID] = true
+				}
+				recoverFromSnapshot(failed)
+				resetTimer(stallTimer, stallTimeout)
+			}
+		case <-stalled:
+			if !isPaused && !isDone {
+				fmt.Println("Workers stalled during turn", turn)
+				recoverFromSnapshot(map[int]bool{})
+				stallTimeout *= 2
+			}
+			resetTimer(stallTimer, stallTimeout)
+		case <-c.resync:
+			viewing = true
+			resync = true
+			//A running game sends the full frame once the workers have finished the turn
+			if isPaused {
+				frame(nil)
 			}
 		case f := <-c.globalFiller:
-			sendLinesToWorker(p, f, c)
+			edges[f.workerID] = f
+			telemetry.edgesReceived(f.workerID)
+			workersSentEdges++
+			if workersSentEdges == p.Threads {
+				workersSentEdges = 0
+				sendLinesToWorkers(p, edges, c)
+				telemetry.halosSent()
+			}
 		case k := <-c.keyPresses:
 			switch k {
 			case 'p':
+				if !isPaused {
+					pending = append(pending, k)
+					break
+				}
 				for _, kp := range c.workerKeyPresses {
 					kp <- k
 				}
-				isPaused = !isPaused
-				if isPaused {
-					c.keyPressEvents <- StateChange{turn, Paused, prevTurnAliveCells}
-				} else {
-					c.keyPressEvents <- StateChange{turn, Executing, nil}
+				isPaused = false
+				c.keyPressEvents <- StateChange{turn, Executing, nil}
+			case 's', 'q', 'k':
+				if !isPaused {
+					pending = append(pending, k)
+					break
 				}
-			case 's':
-				c.keyPressEvents <- StateChange{turn, Saving, prevTurnAliveCells}
-			case 'q':
-				c.keyPressEvents <- StateChange{turn, Quitting, prevTurnAliveCells}
-				for _, kp := range c.workerKeyPresses {
-					kp <- k
+				boardKey(k)
+				if killed {
+					return current, turn
 				}
-				isPaused = true
 			case 'r':
 				for _, kp := range c.workerKeyPresses {
 					kp <- k
 				}
 				isPaused = false
+			case 'n':
+				if isPaused {
+					seek(turn + 1)
+				} else {
+					c.keyPressEvents <- StateChange{turn, Executing, nil}
+				}
+			case 'b':
+				if !isPaused {
+					c.keyPressEvents <- StateChange{turn, Executing, nil}
+					break
+				}
+				alive, _ := board()
+				if entry, ok := past.stepBack(); ok {
+					turn = entry.from
+					restart(flipCells(alive, entry.flipped), entry.flipped, true)
+				} else if turn > startTurn {
+					seek(turn - 1)
+					break
+				}
+				c.keyPressEvents <- StateChange{turn, Paused, current}
+			}
+		case target := <-c.seeks:
+			if isPaused {
+				seek(target)
+			} else {
+				c.keyPressEvents <- StateChange{turn, Executing, nil}
 			}
+		case edit := <-c.edits:
+			report := EditReport{}
+			if isPaused {
+				//The workers are started again, still paused, from the edited board
+				alive, _ := board()
+				var edited []util.Cell
+				edited, report.Flipped = applyEdit(p, alive, edit)
+				past.add(historyEntry{from: turn, to: turn, flipped: report.Flipped})
+				restart(edited, report.Flipped, true)
+			}
+			report.Turns = turn
+			c.edited <- report
 		case <-c.ticker:
-			c.events <- AliveCellsCount{CompletedTurns: turn, CellsCount: len(prevTurnAliveCells)}
+			c.events <- AliveCellsCount{CompletedTurns: turn, CellsCount: count}
+			c.events <- telemetry.report(turn)
 		case <-c.killChan:
-			for _, e := range c.workerKillChan {
-				e <- true
-			}
+			killWorkers(c)
+			closeWorkerNodes(c.workerNodes)
 			c.killConfirmChan <- true
 			return aliveCells, turn
 		}
@@ -0,0 +1,70 @@
+package gol
+
+import "uk.ac.bris.cs/gameoflife/util"
+
+//Edit changes cells of a paused game. The cells are toggled, or made alive when Alive
+//is set, as when a pattern is pasted onto the board.
+type Edit struct {
+	Cells []util.Cell
+	Alive bool
+}
+
+//Returns whether a cell is alive after the edit, given whether it was alive before
+func (e Edit) apply(alive bool) bool {
+	return e.Alive || !alive
+}
+
+//Returns the edit with its cells wrapped onto the board as the topology joins its edges,
+//leaving out any cells that fall off it
+func (e Edit) onBoard(p Params) Edit {
+	cells := make([]util.Cell, 0, len(e.Cells))
+	for _, cell := range e.Cells {
+		if x, y, ok := p.Topology.Wrap(cell.X, cell.Y, p.ImageWidth, p.ImageHeight); ok {
+			cells = append(cells, util.Cell{X: x, Y: y})
+		}
+	}
+	return Edit{Cells: cells, Alive: e.Alive}
+}
+
+//Returns the alive cells after an edit, along with the cells that it flipped
+func applyEdit(p Params, alive []util.Cell, edit Edit) ([]util.Cell, []util.Cell) {
+	isAlive := make(map[util.Cell]bool, len(alive))
+	for _, cell := range alive {
+		isAlive[cell] = true
+	}
+	flipped := []util.Cell{}
+	for _, cell := range edit.onBoard(p).Cells {
+		if edit.apply(isAlive[cell]) != isAlive[cell] {
+			isAlive[cell] = !isAlive[cell]
+			flipped = append(flipped, cell)
+		}
+	}
+	next := make([]util.Cell, 0, len(isAlive))
+	for cell, a := range isAlive {
+		if a {
+			next = append(next, cell)
+		}
+	}
+	return next, flipped
+}
+
+//PatternCells reads a pattern file, as the controller reads its input, and returns its
+//alive cells with the top left corner of the pattern at (0, 0)
+func PatternCells(path string, rule Rule, threshold uint8) ([]util.Cell, error) {
+	if threshold == 0 {
+		threshold = DefaultThreshold
+	}
+	world, err := readPattern(path, rule, threshold)
+	if err != nil {
+		return nil, err
+	}
+	var cells []util.Cell
+	for y, row := range world {
+		for x, level := range row {
+			if level == 255 {
+				cells = append(cells, util.Cell{X: x, Y: y})
+			}
+		}
+	}
+	return cells, nil
+}
@@ -2,6 +2,7 @@ package gol
 
 import (
 	"fmt"
+	"time"
 
 	"uk.ac.bris.cs/gameoflife/util"
 )
@@ -29,6 +30,13 @@ type ImageOutputComplete struct { // implements Event
 	Filename       string
 }
 
+// ErrorOccurred is an Event notifying the user about an error that stops the game,
+// such as an input image that cannot be read. A Quitting StateChange follows it.
+type ErrorOccurred struct { // implements Event
+	CompletedTurns int
+	Err            error
+}
+
 // State represents a change in the state of execution.
 type State int
 
@@ -53,6 +61,8 @@ type StateChange struct { // implements Event
 type CellFlipped struct { // implements Event
 	CompletedTurns int
 	Cell           util.Cell
+	//Gray level of the cell after the change: 255 if alive and 0 if dead
+	Value uint8
 }
 
 // TurnComplete is an Event notifying the GUI about turn completion.
@@ -62,9 +72,49 @@ type TurnComplete struct { // implements Event
 	CompletedTurns int
 }
 
+// CycleDetected is an Event notifying the user that the board has come back to how it was
+// on an earlier turn, so repeats itself every Period turns from CompletedTurns on.
+// A Period of 1 is a still life. This Event is sent once, for the first cycle found.
+type CycleDetected struct { // implements Event
+	CompletedTurns int
+	Period         int
+}
+
+// TurnStats is an Event holding the statistics of the board after a turn. The engine
+// sends one for every turn when Params.Stats is set, and the controller writes each as a
+// row of the statistics file.
+type TurnStats struct { // implements Event
+	CompletedTurns int `json:"completed_turns"`
+	AliveCells     int `json:"alive_cells"`
+	Births         int `json:"births"`
+	Deaths         int `json:"deaths"`
+	//Bounding box of the alive cells, which is empty when none are alive
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// Telemetry is an Event reporting how fast the game is running, sent along with each
+// AliveCellsCount. The times are averages over the turns since the last report, with one
+// for each worker's strip, so that a strip holding the others up stands out.
+type Telemetry struct { // implements Event
+	CompletedTurns int
+	TurnsPerSecond float64
+	//Time each worker took to run its strip of a turn, once it had been sent its halo
+	TurnTimes []time.Duration
+	//Time each worker waited for its halo, from sending its edges until every worker had sent theirs
+	HaloWaits []time.Duration
+}
+
 type WorkerTurnComplete struct {
 	CompletedTurns int
-	Alive          []util.Cell
+	CellsCount     int
+	//XOR of the cycle detector's keys of the cells that flipped
+	Hash uint64
+	//Only sent if the distributor asked for the flipped cells
+	Flipped  []util.Cell
+	WorkerID int
 }
 
 // FinalTurnComplete is an Event notifying the testing framework about the new world state after execution finished.
@@ -85,6 +135,13 @@ type WorkerSaveImage struct {
 	Alive          []util.Cell
 }
 
+type WorkerFailed struct {
+	CompletedTurns int
+	WorkerID       int
+	//Set if the worker ran on a worker node, which is then no longer used
+	Remote bool
+}
+
 // String methods allow the different types of Events and States to be printed.
 
 func (state State) String() string {
@@ -124,6 +181,14 @@ func (event ImageOutputComplete) GetCompletedTurns() int {
 	return event.CompletedTurns
 }
 
+func (event ErrorOccurred) String() string {
+	return fmt.Sprintf("Error: %v", event.Err)
+}
+
+func (event ErrorOccurred) GetCompletedTurns() int {
+	return event.CompletedTurns
+}
+
 func (event CellFlipped) String() string {
 	return fmt.Sprintf("CellFlipped")
 }
@@ -148,6 +213,33 @@ func (event FinalTurnComplete) GetCompletedTurns() int {
 	return event.CompletedTurns
 }
 
+func (event CycleDetected) String() string {
+	if event.Period == 1 {
+		return fmt.Sprintf("Still life")
+	}
+	return fmt.Sprintf("Cycle of period %v", event.Period)
+}
+
+func (event CycleDetected) GetCompletedTurns() int {
+	return event.CompletedTurns
+}
+
+func (event TurnStats) String() string {
+	return fmt.Sprintf("Alive Cells %v, Births %v, Deaths %v", event.AliveCells, event.Births, event.Deaths)
+}
+
+func (event TurnStats) GetCompletedTurns() int {
+	return event.CompletedTurns
+}
+
+func (event Telemetry) String() string {
+	return fmt.Sprintf("%.1f turns/s, strip times %v, halo waits %v", event.TurnsPerSecond, event.TurnTimes, event.HaloWaits)
+}
+
+func (event Telemetry) GetCompletedTurns() int {
+	return event.CompletedTurns
+}
+
 func (event WorkerTurnComplete) String() string {
 	return fmt.Sprintf("")
 }
@@ -172,6 +264,14 @@ func (event WorkerSaveImage) GetCompletedTurns() int {
 	return event.CompletedTurns
 }
 
+func (event WorkerFailed) String() string {
+	return fmt.Sprintf("")
+}
+
+func (event WorkerFailed) GetCompletedTurns() int {
+	return event.CompletedTurns
+}
+
 // This might all seem like weird syntax to you...
 // You have however seen something similar to it before in first year.
 
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/rpc"
 	"os"
+	"sync"
 	"time"
 
 	"uk.ac.bris.cs/gameoflife/util"
@@ -14,6 +15,38 @@ type Params struct {
 	Threads     int
 	ImageWidth  int
 	ImageHeight int
+	Rule        Rule
+	Topology    Topology
+	//Pattern file to load, as a pgm, png, rle or cells file. Empty to load images/<W>x<H>.pgm
+	Input string
+	//Where the top left corner of the loaded pattern is placed on the board
+	OffsetX int
+	OffsetY int
+	//Format that images are saved in
+	Format Format
+	//Gray level from which pixels of a netpbm or png input are alive. 0 for DefaultThreshold
+	Threshold uint8
+	//Directory that images are saved in. Empty to save them in out
+	OutputDir string
+	//Template of the names images are saved under, see DefaultOutputName
+	OutputName string
+	//Animated gif file that the turns are recorded to. Empty not to record
+	Record string
+	//Number of turns between the frames of the recording. 0 to record every turn
+	RecordEvery int
+	//Width and height in pixels of each cell of the recording. 0 for 1
+	RecordScale int
+	//Number of turns and edits kept to step back through while paused. 0 for DefaultHistory,
+	//negative to keep none
+	History int
+	//Whether to skip the whole cycles that fit before the last turn once the board repeats
+	//itself
+	SkipCycles bool
+	//File that the controller writes the statistics of every turn to, as JSON Lines if it
+	//ends in .jsonl and csv otherwise. Empty not to write any
+	Stats string
+	//How often the alive cells and telemetry are reported. 0 for DefaultReportInterval
+	ReportInterval time.Duration
 }
 
 type ClientParams struct {
@@ -23,6 +56,25 @@ type ClientParams struct {
 	ImageHeight    int
 	BrokerAddr     string
 	ShouldContinue int
+	LiveView       bool
+	SessionID      int
+	Observe        bool
+	Rule           Rule
+	Topology       Topology
+	Input          string
+	OffsetX        int
+	OffsetY        int
+	Format         Format
+	Threshold      uint8
+	OutputDir      string
+	OutputName     string
+	Record         string
+	RecordEvery    int
+	RecordScale    int
+	History        int
+	SkipCycles     bool
+	Stats          string
+	ReportInterval time.Duration
 }
 
 type controllerChannels struct {
@@ -31,6 +83,11 @@ type controllerChannels struct {
 	input    chan uint8
 	output   chan uint8
 	filename chan string
+	//Receives the error instead of the input when it cannot be read
+	inputError chan error
+	//Sends the frames to record, and is closed when the recording should be saved
+	frames chan [][]byte
+	saved  chan bool
 }
 
 type ReportType uint8
@@ -38,24 +95,58 @@ type ReportType uint8
 const (
 	Ticking ReportType = iota
 	Finished
+	StateChanged
+	CycleFound
+	Statistics
+	Measured
 )
 
 func ClientToEngineParams(p ClientParams) Params {
 	np := Params{
-		Turns:       p.Turns,
-		Threads:     p.Threads,
-		ImageWidth:  p.ImageWidth,
-		ImageHeight: p.ImageHeight,
+		Turns:          p.Turns,
+		Threads:        p.Threads,
+		ImageWidth:     p.ImageWidth,
+		ImageHeight:    p.ImageHeight,
+		Rule:           p.Rule,
+		Topology:       p.Topology,
+		Input:          p.Input,
+		OffsetX:        p.OffsetX,
+		OffsetY:        p.OffsetY,
+		Format:         p.Format,
+		Threshold:      p.Threshold,
+		OutputDir:      p.OutputDir,
+		OutputName:     p.OutputName,
+		Record:         p.Record,
+		RecordEvery:    p.RecordEvery,
+		RecordScale:    p.RecordScale,
+		History:        p.History,
+		SkipCycles:     p.SkipCycles,
+		Stats:          p.Stats,
+		ReportInterval: p.ReportInterval,
 	}
 	return np
 }
 
 func Run(p ClientParams, events chan Event, keyPresses chan rune) {
+	RunInteractive(p, events, keyPresses, nil, nil)
+}
+
+//RunInteractive runs the game like Run, also sending edits to the engine, which
+//changes the board with them while the game is paused, and the turns to move the
+//paused game to that arrive on seeks
+func RunInteractive(p ClientParams, events chan Event, keyPresses chan rune, edits <-chan Edit, seeks <-chan int) {
 	//Read image
 	quit := make(chan bool)
 	engineParams := ClientToEngineParams(p)
 	controllerChannels := makeIO(engineParams)
-	aliveCells := readImage(engineParams, controllerChannels)
+	if p.Record != "" {
+		//The recorder sits between the controller and SDL, copying the cells flipped
+		//by the live viewer, which must run so that every turn is received
+		recorded := make(chan Event)
+		go record(engineParams, recorded, events, controllerChannels.frames, controllerChannels.saved)
+		events = recorded
+		p.LiveView = true
+	}
 
 	//Dial broker address.
 	client, err := rpc.Dial("tcp", (p.BrokerAddr))
@@ -63,19 +154,148 @@ func Run(p ClientParams, events chan Event, keyPresses chan rune) {
 		fmt.Println("Error: Client returned nil.", err)
 		os.Exit(2)
 	}
-	status := new(StatusReport)
-	initParams := InitParams{
-		Alive:  aliveCells,
-		Params: engineParams,
+	subscription := SubscribeReport{}
+	if p.Observe {
+		//Watch a game that is already running, without reading an image
+		request := SubscribeRequest{SessionID: p.SessionID, Observe: true}
+		err = client.Call(Subscribe, request, &subscription)
+		if err != nil {
+			fmt.Println("Error: could not observe game.", err)
+			os.Exit(2)
+		}
+	} else {
+		aliveCells, err := readImage(engineParams, controllerChannels)
+		if err != nil {
+			client.Close()
+			events <- ErrorOccurred{0, err}
+			events <- StateChange{0, Quitting, nil}
+			close(events)
+			return
+		}
+		status := new(StatusReport)
+		initParams := InitParams{
+			Alive:  aliveCells,
+			Params: engineParams,
+		}
+		towork := InitRequest{Params: &initParams, ShouldContinue: p.ShouldContinue, SessionID: p.SessionID}
+		//Call the broker
+		client.Call(Initialise, towork, &status)
+		subscription = SubscribeReport{SessionID: status.SessionID, SubscriberID: status.SubscriberID, Controlling: status.Controlling}
+	}
+	if subscription.Controlling {
+		fmt.Println("Controlling session", subscription.SessionID)
+	} else {
+		fmt.Println("Observing session", subscription.SessionID)
+	}
+	subscriber := subscription.SubscriberID
+	view := &liveView{stop: make(chan bool), done: make(chan bool), running: p.LiveView}
+	if p.LiveView {
+		go viewer(client, subscriber, events, view)
+	} else {
+		close(view.done)
 	}
-	towork := InitRequest{Params: &initParams, ShouldContinue: p.ShouldContinue}
-	//Call the broker
-	client.Call(Initialise, towork, &status)
-	go ticker(client, events, quit)
-	go keyboard(client, keyPresses, events, engineParams, controllerChannels, quit)
+	var stats chan []TurnStats
+	statsDone := make(chan bool)
+	if p.Stats != "" {
+		stats = make(chan []TurnStats, 100)
+		go writeStats(engineParams, stats, statsDone)
+	}
+	go ticker(client, subscriber, engineParams, events, stats, statsDone, quit, view)
+	go keyboard(client, subscriber, subscription.Controlling, keyPresses, edits, seeks, events, engineParams, controllerChannels, quit, view)
+}
+
+//Controls the goroutine that draws every turn received from the engine
+type liveView struct {
+	stop    chan bool
+	done    chan bool
+	once    sync.Once
+	running bool
 }
 
-func readImage(p Params, c controllerChannels) []util.Cell {
+//Stops the viewer and waits for it to return, so that events can be closed
+func (v *liveView) close() {
+	v.once.Do(func() {
+		close(v.stop)
+	})
+	<-v.done
+}
+
+//Keeps a copy of the engine's board up to date with the frames it sends, and passes
+//the flipped cells on to SDL so that every turn is drawn. When the viewer falls
+//behind, the engine skips frames and sends the whole board, which is then drawn
+//by flipping the cells that differ from the copy.
+func viewer(client *rpc.Client, subscriber int, events chan Event, v *liveView) {
+	defer close(v.done)
+	view := map[util.Cell]bool{}
+	synced := false
+	for {
+		select {
+		case <-v.stop:
+			return
+		default:
+		}
+		report := FramesReport{}
+		err := client.Call(Frames, FramesRequest{Resync: !synced, SubscriberID: subscriber}, &report)
+		if err != nil {
+			fmt.Println("Error: could not get frames from engine.", err)
+			return
+		}
+		for _, frame := range report.Frames {
+			flipped := frame.Flipped
+			if frame.Full {
+				flipped = boardDifference(view, frame.Alive)
+				synced = true
+			} else if !synced {
+				continue
+			}
+			for _, cell := range flipped {
+				if view[cell] {
+					delete(view, cell)
+				} else {
+					view[cell] = true
+				}
+				select {
+				case events <- CellFlipped{CompletedTurns: frame.Turn, Cell: cell, Value: cellLevel(view[cell])}:
+				case <-v.stop:
+					return
+				}
+			}
+			select {
+			case events <- TurnComplete{CompletedTurns: frame.Turn}:
+			case <-v.stop:
+				return
+			}
+		}
+	}
+}
+
+//Returns the gray level of an alive or dead cell
+func cellLevel(alive bool) uint8 {
+	if alive {
+		return 255
+	}
+	return 0
+}
+
+//Returns the cells that must be flipped to turn the viewed board into the given one
+func boardDifference(view map[util.Cell]bool, alive []util.Cell) []util.Cell {
+	flipped := []util.Cell{}
+	isAlive := map[util.Cell]bool{}
+	for _, cell := range alive {
+		isAlive[cell] = true
+		if !view[cell] {
+			flipped = append(flipped, cell)
+		}
+	}
+	for cell := range view {
+		if !isAlive[cell] {
+			flipped = append(flipped, cell)
+		}
+	}
+	return flipped
+}
+
+func readImage(p Params, c controllerChannels) ([]util.Cell, error) {
 
 	aliveCells := []util.Cell{}
 
@@ -104,43 +324,80 @@ func readImage(p Params, c controllerChannels) []util.Cell {
 				if b == 255 {
 					aliveCells = append(aliveCells, util.Cell{X: j, Y: i})
 				}
+			case err := <-c.inputError:
+				return nil, err
 			}
 		}
 	}
 
-	return aliveCells
+	return aliveCells, nil
 }
 
-func ticker(client *rpc.Client, events chan Event, quit chan bool) {
-	ticker := time.NewTicker(2 * time.Second)
+//Passes on the reports that the engine publishes every ReportInterval, and the state
+//changes caused by other clients, until the game finishes. The statistics of the turns
+//are passed to the writer on stats, if there is one, which has saved them once the
+//events are closed.
+func ticker(client *rpc.Client, subscriber int, p Params, events chan Event, stats chan []TurnStats, statsDone chan bool,
+	quit chan bool, view *liveView) {
+	saveStats := func() {
+		if stats != nil {
+			close(stats)
+			<-statsDone
+			stats = nil
+		}
+	}
+	defer saveStats()
 	isDone := false
 	for {
 		fmt.Println("Ticking...")
+		aliveReport := TickReport{}
+		call := client.Go(Report, ReportRequest{SubscriberID: subscriber}, &aliveReport, make(chan *rpc.Call, 1))
 		select {
-		case <-ticker.C:
-			aliveReport := TickReport{}
-			client.Call(Report, ReportRequest{}, &aliveReport)
-			switch aliveReport.ReportType {
-			case Ticking:
-				events <- AliveCellsCount{CompletedTurns: aliveReport.Turns, CellsCount: aliveReport.CellsCount}
-			case Finished:
-				events <- FinalTurnComplete{CompletedTurns: aliveReport.Turns, Alive: aliveReport.Alive}
-				events <- StateChange{aliveReport.Turns, Quitting, nil}
-				close(events)
-				isDone = true
-			}
-			if isDone {
-				quit <- true
+		case <-call.Done:
+		case <-quit:
+			return
+		}
+		if call.Error != nil {
+			fmt.Println("Error: could not get report from engine.", call.Error)
+			//The engine may be busy recovering from a failed worker, so try again later
+			select {
+			case <-time.After(p.ReportEvery()):
+				continue
+			case <-quit:
 				return
 			}
-		case <-quit:
-			ticker.Stop()
+		}
+		switch aliveReport.ReportType {
+		case Ticking:
+			events <- AliveCellsCount{CompletedTurns: aliveReport.Turns, CellsCount: aliveReport.CellsCount}
+		case CycleFound:
+			events <- CycleDetected{CompletedTurns: aliveReport.Turns, Period: aliveReport.Period}
+		case Measured:
+			events <- aliveReport.Telemetry
+		case Statistics:
+			if stats != nil {
+				stats <- aliveReport.Stats
+			}
+		case StateChanged:
+			if aliveReport.State != Saving {
+				events <- StateChange{aliveReport.Turns, aliveReport.State, aliveReport.Alive}
+			}
+		case Finished:
+			view.close()
+			events <- FinalTurnComplete{CompletedTurns: aliveReport.Turns, Alive: aliveReport.Alive}
+			events <- StateChange{aliveReport.Turns, Quitting, nil}
+			saveStats()
+			close(events)
+			isDone = true
+		}
+		if isDone {
+			quit <- true
 			return
 		}
 	}
 }
-func keyboard(client *rpc.Client, keyPresses chan rune, events chan Event,
-	p Params, c controllerChannels, quit chan bool) {
+func keyboard(client *rpc.Client, subscriber int, controlling bool, keyPresses chan rune, edits <-chan Edit, seeks <-chan int, events chan Event,
+	p Params, c controllerChannels, quit chan bool, view *liveView) {
 	previousAliveCells := []util.Cell{}
 	isDone := false
 	//isPaused := false
@@ -148,9 +405,25 @@ func keyboard(client *rpc.Client, keyPresses chan rune, events chan Event,
 		select {
 		case k := <-keyPresses:
 			fmt.Println("Received input: ", k)
-			request := KeyPressRequest{Key: k}
+			//Observers can only leave
+			if !controlling {
+				if k == 'q' {
+					quit <- true
+					view.close()
+					close(events)
+					isDone = true
+					break
+				}
+				fmt.Println("Only the controlling client can press keys")
+				continue
+			}
+			request := KeyPressRequest{Key: k, SubscriberID: subscriber}
 			keyPressReport := KeyPressReport{Alive: nil, Turns: 0}
-			client.Call(KeyPress, request, &keyPressReport)
+			err := client.Call(KeyPress, request, &keyPressReport)
+			if err != nil {
+				fmt.Println("Error: key press was not accepted by engine.", err)
+				continue
+			}
 			fmt.Println("State:", keyPressReport.State.String())
 			if keyPressReport.State != Saving {
 				fmt.Println("Sending statechange event")
@@ -160,24 +433,77 @@ func keyboard(client *rpc.Client, keyPresses chan rune, events chan Event,
 					NewState:       keyPressReport.State}
 			}
 			switch k {
-			case 'p':
-				for _, cell := range previousAliveCells {
-					events <- CellFlipped{CompletedTurns: keyPressReport.Turns, Cell: cell}
+			case 'p', 'n', 'b':
+				//The live viewer already draws every turn
+				if view.running {
+					break
 				}
-				for _, cell := range keyPressReport.Alive {
-					events <- CellFlipped{CompletedTurns: keyPressReport.Turns, Cell: cell}
-				}
-				events <- TurnComplete{CompletedTurns: 0}
-				previousAliveCells = keyPressReport.Alive
+				previousAliveCells = drawPaused(events, previousAliveCells, keyPressReport)
 			case 's':
 				outputImage(p, c, keyPressReport.Alive, keyPressReport.Turns)
 			case 'q':
 				outputImage(p, c, keyPressReport.Alive, keyPressReport.Turns)
 				c.command <- ioCheckIdle
 				<-c.ioIdle
+				quit <- true
+				view.close()
 				close(events)
 				isDone = true
+			case 'k':
+				outputImage(p, c, keyPressReport.Alive, keyPressReport.Turns)
+				c.command <- ioCheckIdle
+				<-c.ioIdle
+				//Hang up so that the engine can exit, then stop the ticker
+				client.Close()
 				quit <- true
+				view.close()
+				close(events)
+				isDone = true
+			}
+		case turn := <-seeks:
+			if !controlling {
+				fmt.Println("Only the controlling client can seek")
+				continue
+			}
+			report := KeyPressReport{}
+			err := client.Call(Seek, SeekRequest{Turn: turn, SubscriberID: subscriber}, &report)
+			if err != nil {
+				fmt.Println("Error: seek was not accepted by engine.", err)
+				continue
+			}
+			events <- StateChange{CompletedTurns: report.Turns, Alive: report.Alive, NewState: report.State}
+			if !view.running {
+				previousAliveCells = drawPaused(events, previousAliveCells, report)
+			}
+		case edit := <-edits:
+			if !controlling {
+				fmt.Println("Only the controlling client can edit the board")
+				continue
+			}
+			report := EditReport{}
+			err := client.Call(EditCells, EditRequest{Edit: edit, SubscriberID: subscriber}, &report)
+			if err != nil {
+				fmt.Println("Error: edit was not accepted by engine.", err)
+				continue
+			}
+			//The live viewer already draws the frame of the flipped cells
+			if view.running || len(report.Flipped) == 0 {
+				continue
+			}
+			drawn := map[util.Cell]bool{}
+			for _, cell := range previousAliveCells {
+				drawn[cell] = true
+			}
+			for _, cell := range report.Flipped {
+				drawn[cell] = !drawn[cell]
+				events <- CellFlipped{CompletedTurns: report.Turns, Cell: cell, Value: cellLevel(drawn[cell])}
+			}
+			events <- TurnComplete{CompletedTurns: report.Turns}
+			previousAliveCells = []util.Cell{}
+			for cell, alive := range drawn {
+				if alive {
+					previousAliveCells = append(previousAliveCells, cell)
+				}
 			}
 		case <-quit:
 			isDone = true
@@ -188,9 +514,22 @@ func keyboard(client *rpc.Client, keyPresses chan rune, events chan Event,
 	}
 }
 
+//Draws the board of a paused game in place of the one drawn before, or clears it once
+//the game is running again. Returns the cells that are drawn.
+func drawPaused(events chan Event, drawn []util.Cell, report KeyPressReport) []util.Cell {
+	for _, cell := range drawn {
+		events <- CellFlipped{CompletedTurns: report.Turns, Cell: cell, Value: 0}
+	}
+	for _, cell := range report.Alive {
+		events <- CellFlipped{CompletedTurns: report.Turns, Cell: cell, Value: 255}
+	}
+	events <- TurnComplete{CompletedTurns: 0}
+	return report.Alive
+}
+
 func outputImage(p Params, c controllerChannels, aliveCells []util.Cell, turns int) {
 	c.command <- ioOutput
-	s := fmt.Sprintf("%dx%dx%d", p.ImageWidth, p.ImageHeight, turns)
+	s := outputName(p.OutputName, p, p.ImageWidth, p.ImageHeight, 0, 0, turns)
 	c.filename <- s
 
 	world := make([][]byte, p.ImageHeight)
@@ -218,6 +557,12 @@ func makeIO(engineParams Params) controllerChannels {
 	input := make(chan byte)
 	output := make(chan byte)
 	filename := make(chan string)
+	inputError := make(chan error)
+	var frames chan [][]byte
+	if engineParams.Record != "" {
+		frames = make(chan [][]byte, 100)
+	}
+	saved := make(chan bool)
 
 	ioChannels := ioChannels{
 		command:  ioCommand,
@@ -225,15 +570,22 @@ func makeIO(engineParams Params) controllerChannels {
 		filename: filename,
 		output:   output,
 		input:    input,
+		//Sends the error instead of the input when it cannot be read
+		inputError: inputError,
+		frames:     frames,
+		saved:      saved,
 	}
 	go startIo(engineParams, ioChannels)
 
 	controllerChannels := controllerChannels{
-		command:  ioCommand,
-		ioIdle:   ioIdle,
-		filename: filename,
-		output:   output,
-		input:    input,
+		command:    ioCommand,
+		ioIdle:     ioIdle,
+		filename:   filename,
+		output:     output,
+		input:      input,
+		inputError: inputError,
+		frames:     frames,
+		saved:      saved,
 	}
 	return controllerChannels
 }
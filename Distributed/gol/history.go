@@ -0,0 +1,164 @@
+package gol
+
+import "uk.ac.bris.cs/gameoflife/util"
+
+//DefaultHistory is the number of turns and edits kept for stepping back through a paused game
+const DefaultHistory = 100
+
+//Most flipped cells kept in a history, so that the turns of a large board do not fill memory.
+//The oldest entries are dropped to keep under it, though the latest entry is always kept.
+const maxHistoryFlips = 1 << 20
+
+//Cells flipped going from one turn to a later one. An edit goes from a turn to itself.
+//Flipping the same cells again undoes the entry.
+type historyEntry struct {
+	from    int
+	to      int
+	flipped []util.Cell
+}
+
+//Flips the entry's cells in a set of cells that have been flipped an odd number of times
+func (e historyEntry) toggle(cells map[util.Cell]bool) {
+	for _, cell := range e.flipped {
+		cells[cell] = !cells[cell]
+	}
+}
+
+//history is a ring buffer of the cells flipped by the latest turns and edits of a game, so
+//that a paused game can be stepped back through them, then forward again without running
+//the turns a second time
+type history struct {
+	entries []historyEntry
+	oldest  int
+	//Number of entries before the current turn, which can be stepped back over
+	back int
+	//Number of entries after the current turn, which were stepped back over
+	forward int
+	//Number of flipped cells in the entries before the current turn
+	flips int
+}
+
+//Returns a history of the given number of entries, DefaultHistory if it is 0 and none if it
+//is negative
+func newHistory(size int) *history {
+	if size == 0 {
+		size = DefaultHistory
+	}
+	if size < 0 {
+		size = 0
+	}
+	return &history{entries: make([]historyEntry, size)}
+}
+
+//Adds the cells flipped after the current turn. The entries that were stepped back over
+//are dropped, as the game has gone another way. Once the history is full, each entry
+//added drops the oldest one.
+func (h *history) add(entry historyEntry) {
+	if len(h.entries) == 0 {
+		return
+	}
+	h.forward = 0
+	if h.back == len(h.entries) {
+		h.dropOldest()
+	}
+	for h.back > 0 && h.flips+len(entry.flipped) > maxHistoryFlips {
+		h.dropOldest()
+	}
+	h.entries[(h.oldest+h.back)%len(h.entries)] = entry
+	h.back++
+	h.flips += len(entry.flipped)
+}
+
+//Drops every entry, once the game has gone on in a way that cannot be stepped back over
+func (h *history) clear() {
+	if h.back == 0 && h.forward == 0 {
+		return
+	}
+	for i := range h.entries {
+		h.entries[i] = historyEntry{}
+	}
+	h.oldest, h.back, h.forward, h.flips = 0, 0, 0, 0
+}
+
+func (h *history) dropOldest() {
+	h.flips -= len(h.entries[h.oldest].flipped)
+	h.entries[h.oldest] = historyEntry{}
+	h.oldest = (h.oldest + 1) % len(h.entries)
+	h.back--
+}
+
+//Steps back over the entry before the current turn, if there is one
+func (h *history) stepBack() (historyEntry, bool) {
+	if h.back == 0 {
+		return historyEntry{}, false
+	}
+	h.back--
+	h.forward++
+	entry := h.entries[(h.oldest+h.back)%len(h.entries)]
+	h.flips -= len(entry.flipped)
+	return entry, true
+}
+
+//Steps forward over the entry after the current turn, if there is one and it does not go
+//past the target turn
+func (h *history) stepForward(target int) (historyEntry, bool) {
+	if h.forward == 0 {
+		return historyEntry{}, false
+	}
+	entry := h.entries[(h.oldest+h.back)%len(h.entries)]
+	if entry.to > target {
+		return historyEntry{}, false
+	}
+	h.back++
+	h.forward--
+	h.flips += len(entry.flipped)
+	return entry, true
+}
+
+//Steps through the history from a turn towards a target turn, as far as it reaches.
+//Returns the cells that end up flipped on the way, and the turn reached. Going forward,
+//the edits made on the target turn are made again.
+func (h *history) seek(turn int, target int) ([]util.Cell, int) {
+	cells := map[util.Cell]bool{}
+	for turn > target {
+		entry, ok := h.stepBack()
+		if !ok {
+			break
+		}
+		entry.toggle(cells)
+		turn = entry.from
+	}
+	for turn <= target {
+		entry, ok := h.stepForward(target)
+		if !ok {
+			break
+		}
+		entry.toggle(cells)
+		turn = entry.to
+	}
+	flipped := []util.Cell{}
+	for cell, odd := range cells {
+		if odd {
+			flipped = append(flipped, cell)
+		}
+	}
+	return flipped, turn
+}
+
+//Returns the alive cells after flipping some of them
+func flipCells(alive []util.Cell, flipped []util.Cell) []util.Cell {
+	isAlive := make(map[util.Cell]bool, len(alive))
+	for _, cell := range alive {
+		isAlive[cell] = true
+	}
+	for _, cell := range flipped {
+		isAlive[cell] = !isAlive[cell]
+	}
+	next := make([]util.Cell, 0, len(isAlive))
+	for cell, a := range isAlive {
+		if a {
+			next = append(next, cell)
+		}
+	}
+	return next
+}
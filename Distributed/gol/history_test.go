@@ -0,0 +1,53 @@
+package gol
+
+import (
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+//Returns an entry for a turn that flips a single cell
+func turnEntry(turn int) historyEntry {
+	return historyEntry{from: turn, to: turn + 1, flipped: []util.Cell{{X: turn}}}
+}
+
+// TestHistory checks that the history steps back and forward through its entries, drops the
+// oldest once it is full, and drops the entries stepped back over once another is added.
+func TestHistory(t *testing.T) {
+	h := newHistory(3)
+	for turn := 0; turn < 5; turn++ {
+		h.add(turnEntry(turn))
+	}
+
+	flipped, turn := h.seek(5, 0)
+	if turn != 2 {
+		t.Errorf("seeking turn 0 reached turn %v, expected 2 as turns 0 and 1 were dropped", turn)
+	}
+	if len(flipped) != 3 {
+		t.Errorf("seeking turn 0 flipped %v, expected cells 2 to 4", flipped)
+	}
+
+	flipped, turn = h.seek(2, 3)
+	if turn != 3 || len(flipped) != 1 || flipped[0] != (util.Cell{X: 2}) {
+		t.Errorf("seeking turn 3 reached turn %v and flipped %v, expected turn 3 with cell 2 flipped", turn, flipped)
+	}
+
+	//An edit on turn 3 replaces the turns after it
+	h.add(historyEntry{from: 3, to: 3})
+	if _, ok := h.stepForward(10); ok {
+		t.Error("stepped forward over a turn that an edit replaced")
+	}
+	if _, turn = h.seek(3, 0); turn != 2 {
+		t.Errorf("seeking turn 0 after the edit reached turn %v, expected 2", turn)
+	}
+
+	none := newHistory(-1)
+	none.add(turnEntry(0))
+	if _, ok := none.stepBack(); ok {
+		t.Error("stepped back through a history that keeps nothing")
+	}
+
+	if cells := flipCells([]util.Cell{{X: 1}, {X: 2}}, []util.Cell{{X: 2}, {X: 3}}); len(cells) != 2 {
+		t.Errorf("flipping cells 2 and 3 of cells 1 and 2 left %v, expected cells 1 and 3", cells)
+	}
+}
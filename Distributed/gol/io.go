@@ -2,10 +2,9 @@ package gol
 
 import (
 	"fmt"
-	"io/ioutil"
+	"image/gif"
 	"os"
-	"strconv"
-	"strings"
+	"path/filepath"
 
 	"uk.ac.bris.cs/gameoflife/util"
 )
@@ -17,12 +16,18 @@ type ioChannels struct {
 	filename <-chan string
 	output   <-chan uint8
 	input    chan<- uint8
+	//Receives the error instead of the input when it cannot be read
+	inputError chan<- error
+	//Receives the frames to record, and is closed when the recording should be saved
+	frames <-chan [][]byte
+	saved  chan<- bool
 }
 
 // ioState is the internal ioState of the io goroutine.
 type ioState struct {
-	params   Params
-	channels ioChannels
+	params    Params
+	channels  ioChannels
+	recording gif.GIF
 }
 
 // ioCommand allows requesting behaviour from the io (pgm) goroutine.
@@ -39,31 +44,29 @@ const (
 	ioCheckIdle
 )
 
-// writePgmImage receives an array of bytes and writes it to a pgm file.
-func (io *ioState) writePgmImage() {
-	_ = os.Mkdir("out", os.ModePerm)
+// writeImage receives an array of bytes and writes it to a file, in the format chosen by the params.
+func (io *ioState) writeImage() {
+	width, height := io.params.ImageWidth, io.params.ImageHeight
+	dir := io.params.OutputDir
+	if dir == "" {
+		dir = "out"
+	}
 
 	filename := <-io.channels.filename
-	file, ioError := os.Create("out/" + filename + ".pgm")
+	//The name may hold directories of its own
+	path := filepath.Join(dir, filename+"."+io.params.Format.String())
+	_ = os.MkdirAll(filepath.Dir(path), os.ModePerm)
+	file, ioError := os.Create(path)
 	util.Check(ioError)
 	defer file.Close()
 
-	_, _ = file.WriteString("P5\n")
-	//_, _ = file.WriteString("# PGM file writer by pnmmodules (https://github.com/owainkenwayucl/pnmmodules).\n")
-	_, _ = file.WriteString(strconv.Itoa(io.params.ImageWidth))
-	_, _ = file.WriteString(" ")
-	_, _ = file.WriteString(strconv.Itoa(io.params.ImageHeight))
-	_, _ = file.WriteString("\n")
-	_, _ = file.WriteString(strconv.Itoa(255))
-	_, _ = file.WriteString("\n")
-
-	world := make([][]byte, io.params.ImageHeight)
+	world := make([][]byte, height)
 	for i := range world {
-		world[i] = make([]byte, io.params.ImageWidth)
+		world[i] = make([]byte, width)
 	}
 
-	for y := 0; y < io.params.ImageHeight; y++ {
-		for x := 0; x < io.params.ImageWidth; x++ {
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
 			val := <-io.channels.output
 			//if val != 0 {
 			//	fmt.Println(x, y)
@@ -72,12 +75,17 @@ func (io *ioState) writePgmImage() {
 		}
 	}
 
-	for y := 0; y < io.params.ImageHeight; y++ {
-		for x := 0; x < io.params.ImageWidth; x++ {
-			_, ioError = file.Write([]byte{world[y][x]})
-			util.Check(ioError)
-		}
+	switch io.params.Format {
+	case RLE:
+		ioError = writeRLE(file, world, io.params.Rule)
+	case Cells:
+		ioError = writeCells(file, world, filepath.Base(filename))
+	case PNG:
+		ioError = writePng(file, world)
+	default:
+		ioError = writePgm(file, world)
 	}
+	util.Check(ioError)
 
 	ioError = file.Sync()
 	util.Check(ioError)
@@ -85,37 +93,34 @@ func (io *ioState) writePgmImage() {
 	fmt.Println("File", filename, "output done!")
 }
 
-// readPgmImage opens a pgm file and sends its data as an array of bytes.
-func (io *ioState) readPgmImage() {
+// readImage opens the input pattern, or else the pgm image named by the filename,
+// and sends its data as an array of bytes, placed at the offset on the board.
+// If it cannot be read, an InputError is sent instead.
+func (io *ioState) readImage() {
 	filename := <-io.channels.filename
-	data, ioError := ioutil.ReadFile("images/" + filename + ".pgm")
-	util.Check(ioError)
-
-	fields := strings.Fields(string(data))
-
-	if fields[0] != "P5" {
-		panic("Not a pgm file")
+	path := "images/" + filename + ".pgm"
+	if io.params.Input != "" {
+		path = io.params.Input
 	}
-
-	width, _ := strconv.Atoi(fields[1])
-	if width != io.params.ImageWidth {
-		panic("Incorrect width")
+	threshold := io.params.Threshold
+	if threshold == 0 {
+		threshold = DefaultThreshold
 	}
-
-	height, _ := strconv.Atoi(fields[2])
-	if height != io.params.ImageHeight {
-		panic("Incorrect height")
+	pattern, ioError := readPattern(path, io.params.Rule, threshold)
+	if ioError != nil {
+		io.channels.inputError <- ioError
+		return
 	}
-
-	maxval, _ := strconv.Atoi(fields[3])
-	if maxval != 255 {
-		panic("Incorrect maxval/bit depth")
+	world, ioError := placePattern(pattern, io.params.ImageWidth, io.params.ImageHeight, io.params.OffsetX, io.params.OffsetY)
+	if ioError != nil {
+		io.channels.inputError <- InputError{path, ioError}
+		return
 	}
 
-	image := []byte(fields[4])
-
-	for _, b := range image {
-		io.channels.input <- b
+	for _, row := range world {
+		for _, b := range row {
+			io.channels.input <- b
+		}
 	}
 
 	fmt.Println("File", filename, "input done!")
@@ -133,12 +138,20 @@ func startIo(p Params, c ioChannels) {
 		case command := <-io.channels.command:
 			switch command {
 			case ioInput:
-				io.readPgmImage()
+				io.readImage()
 			case ioOutput:
-				io.writePgmImage()
+				io.writeImage()
 			case ioCheckIdle:
 				io.channels.idle <- true
 			}
+		case frame, ok := <-io.channels.frames:
+			if ok {
+				io.addFrame(frame)
+			} else {
+				io.saveRecording()
+				io.channels.frames = nil
+				io.channels.saved <- true
+			}
 		}
 	}
 }
@@ -0,0 +1,518 @@
+package gol
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+//Format is the kind of file that images are saved as. The zero Format is a PGM image.
+type Format uint8
+
+const (
+	PGM Format = iota
+	//RLE is the run length encoded pattern format of Golly and LifeWiki, with the rule in its header
+	RLE
+	//Cells is the plaintext pattern format of LifeWiki, with a row of . and O for each row of cells
+	Cells
+	//PNG is a gray image like a PGM image, which can be shown by most image viewers
+	PNG
+)
+
+var formatNames = []string{"pgm", "rle", "cells", "png"}
+
+//ParseFormat reads a format from its name, which is also its file extension: pgm, rle, cells or png
+func ParseFormat(s string) (Format, error) {
+	for i, name := range formatNames {
+		if strings.ToLower(strings.TrimPrefix(strings.TrimSpace(s), ".")) == name {
+			return Format(i), nil
+		}
+	}
+	return PGM, errors.New(fmt.Sprintf("unknown format %q, must be one of %v", s, strings.Join(formatNames, ", ")))
+}
+
+func (f Format) String() string {
+	if int(f) < len(formatNames) {
+		return formatNames[f]
+	}
+	return fmt.Sprintf("Format(%d)", f)
+}
+
+//Set parses a format given on the command line, so that a Format can be used with flag.Var
+func (f *Format) Set(s string) error {
+	format, err := ParseFormat(s)
+	if err != nil {
+		return err
+	}
+	*f = format
+	return nil
+}
+
+//InputError is returned when a pattern file cannot be loaded. Err may be one of the
+//netpbm errors of the util package, such as util.HeaderError.
+type InputError struct {
+	Path string
+	Err  error
+}
+
+func (e InputError) Error() string {
+	return fmt.Sprintf("%v: %v", e.Path, e.Err)
+}
+
+//Returns the format of a pattern file from its extension. All netpbm images are read as a PGM.
+func formatOf(path string) (Format, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".pbm", ".pnm":
+		return PGM, nil
+	}
+	return ParseFormat(filepath.Ext(path))
+}
+
+//Reads a pattern file as gray levels, choosing its format from the file extension.
+//Pixels of a netpbm image at least as bright as the threshold are alive and the rest dead.
+func readPattern(path string, rule Rule, threshold byte) ([][]byte, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, InputError{path, err}
+	}
+	format, err := formatOf(path)
+	if err != nil {
+		return nil, InputError{path, err}
+	}
+	var world [][]byte
+	switch format {
+	case RLE:
+		var states [][]int
+		if states, _, err = parseRLE(string(data)); err == nil {
+			world, err = statesToLevels(states, rule)
+		}
+	case Cells:
+		var states [][]int
+		if states, err = parseCells(string(data)); err == nil {
+			world, err = statesToLevels(states, rule)
+		}
+	case PNG:
+		if world, err = parsePng(data); err == nil {
+			applyThreshold(world, threshold)
+		}
+	default:
+		var image util.Netpbm
+		if image, err = util.ParseNetpbm(data); err == nil {
+			world = image.Pixels
+			applyThreshold(world, threshold)
+		}
+	}
+	if err != nil {
+		return nil, InputError{path, err}
+	}
+	return world, nil
+}
+
+//Makes every gray level at least as bright as the threshold alive, and the rest dead
+func applyThreshold(world [][]byte, threshold byte) {
+	for _, row := range world {
+		for x, level := range row {
+			if level >= threshold {
+				row[x] = 255
+			} else {
+				row[x] = 0
+			}
+		}
+	}
+}
+
+//PatternRule returns the rule given in the header of an RLE file, if it has one that this
+//engine can run. A Generations rule is ignored, so the pattern runs under the default rule.
+func PatternRule(path string) (Rule, bool) {
+	if format, err := formatOf(path); err != nil || format != RLE {
+		return Rule{}, false
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Rule{}, false
+	}
+	_, rule, err := parseRLE(string(data))
+	if err != nil || rule == "" {
+		return Rule{}, false
+	}
+	parsed, err := ParseRule(rule)
+	return parsed, err == nil
+}
+
+func statesToLevels(states [][]int, rule Rule) ([][]byte, error) {
+	world := make([][]byte, len(states))
+	for y := range states {
+		world[y] = make([]byte, len(states[y]))
+		for x, state := range states[y] {
+			if state > 1 {
+				return nil, errors.New(fmt.Sprintf("cell (%v, %v) has state %v, which rule %v does not have", x, y, state, rule))
+			}
+			world[y][x] = byte(255 * state)
+		}
+	}
+	return world, nil
+}
+
+//Parses an RLE pattern into the states of its cells, and the rule from its header if it
+//has one. Both two state files, with b for dead and o for alive cells, and multistate
+//files, with . for dead and A for alive cells, are read. The dying states of multistate
+//files, B to X and a prefix from p to y followed by a letter, are parsed but not allowed.
+func parseRLE(data string) ([][]int, string, error) {
+	lines := strings.Split(strings.Replace(data, "\r", "", -1), "\n")
+	header := -1
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			header = i
+			break
+		}
+	}
+	if header < 0 {
+		return nil, "", errors.New("missing rle header")
+	}
+
+	width, height, rule := -1, -1, ""
+	for _, field := range strings.Split(lines[header], ",") {
+		pair := strings.SplitN(field, "=", 2)
+		if len(pair) != 2 {
+			return nil, "", errors.New(fmt.Sprintf("rle header %q is not of the form x = 3, y = 3, rule = B3/S23", lines[header]))
+		}
+		key, value := strings.TrimSpace(pair[0]), strings.TrimSpace(pair[1])
+		switch key {
+		case "x", "y":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return nil, "", errors.New(fmt.Sprintf("rle header has a bad size %v = %q", key, value))
+			}
+			if key == "x" {
+				width = n
+			} else {
+				height = n
+			}
+		case "rule":
+			rule = value
+		}
+	}
+	if width < 0 || height < 0 {
+		return nil, "", errors.New("rle header is missing its x or y size")
+	}
+
+	states := make([][]int, height)
+	for y := range states {
+		states[y] = make([]int, width)
+	}
+	x, y, count, prefix := 0, 0, 0, 0
+	body := strings.Join(lines[header+1:], "")
+	for _, c := range body {
+		if prefix > 0 && (c < 'A' || c > 'X') {
+			return nil, "", errors.New(fmt.Sprintf("rle pattern has a state prefix %q that is not followed by a letter from A to X", rune('p'+prefix-1)))
+		}
+		switch {
+		case unicode.IsSpace(c):
+			continue
+		case c >= '0' && c <= '9':
+			count = count*10 + int(c-'0')
+			continue
+		case c == '!':
+			return states, rule, nil
+		case c >= 'p' && c <= 'y':
+			//Multistate files write the states after X as a prefix and a letter, from pA for 25
+			prefix = int(c-'p') + 1
+			continue
+		}
+		run := count
+		if run == 0 {
+			run = 1
+		}
+		count = 0
+		switch {
+		case c == '$':
+			x, y = 0, y+run
+		case c == 'b' || c == '.':
+			x += run
+		case c == 'o' || c >= 'A' && c <= 'X':
+			state := 1
+			if c != 'o' {
+				state = 24*prefix + int(c-'A') + 1
+				prefix = 0
+			}
+			if y >= height || x+run > width {
+				return nil, "", errors.New(fmt.Sprintf("rle pattern does not fit in its %vx%v header", width, height))
+			}
+			for i := 0; i < run; i++ {
+				states[y][x+i] = state
+			}
+			x += run
+		default:
+			return nil, "", errors.New(fmt.Sprintf("rle pattern has an unknown cell %q", c))
+		}
+	}
+	return nil, "", errors.New("rle pattern is missing its closing !")
+}
+
+//Parses a plaintext pattern into the states of its cells. Lines starting with ! are comments,
+//and every other line is a row, with . for dead cells and O or * for alive ones.
+func parseCells(data string) ([][]int, error) {
+	var states [][]int
+	width := 0
+	for _, line := range strings.Split(strings.Replace(data, "\r", "", -1), "\n") {
+		if strings.HasPrefix(line, "!") {
+			continue
+		}
+		row := make([]int, 0, len(line))
+		for _, c := range line {
+			switch c {
+			case '.':
+				row = append(row, 0)
+			case 'O', '*':
+				row = append(row, 1)
+			default:
+				return nil, errors.New(fmt.Sprintf("cells pattern has an unknown cell %q", c))
+			}
+		}
+		if len(row) > width {
+			width = len(row)
+		}
+		states = append(states, row)
+	}
+	//A file ends with a newline, which does not start another row
+	for len(states) > 0 && len(states[len(states)-1]) == 0 {
+		states = states[:len(states)-1]
+	}
+	for y := range states {
+		states[y] = append(states[y], make([]int, width-len(states[y]))...)
+	}
+	return states, nil
+}
+
+//Writes gray levels as a binary PGM image
+func writePgm(w io.Writer, world [][]byte) error {
+	height := len(world)
+	width := 0
+	if height > 0 {
+		width = len(world[0])
+	}
+	if _, err := fmt.Fprintf(w, "P5\n%v %v\n255\n", width, height); err != nil {
+		return err
+	}
+	for _, row := range world {
+		if _, err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//Reads a png image as gray levels
+func parsePng(data []byte) ([][]byte, error) {
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	bounds := img.Bounds()
+	world := make([][]byte, bounds.Dy())
+	for y := range world {
+		world[y] = make([]byte, bounds.Dx())
+		for x := range world[y] {
+			world[y][x] = color.GrayModel.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.Gray).Y
+		}
+	}
+	return world, nil
+}
+
+//Writes gray levels as a png image
+func writePng(w io.Writer, world [][]byte) error {
+	height := len(world)
+	width := 0
+	if height > 0 {
+		width = len(world[0])
+	}
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for y, row := range world {
+		copy(img.Pix[y*img.Stride:], row)
+	}
+	return png.Encode(w, img)
+}
+
+//Writes gray levels as an RLE pattern with the rule in its header, using b and o for the cells
+func writeRLE(w io.Writer, world [][]byte, rule Rule) error {
+	height := len(world)
+	width := 0
+	if height > 0 {
+		width = len(world[0])
+	}
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, "x = %v, y = %v, rule = %v\n", width, height, rule)
+
+	line := 0
+	emit := func(run int, tag byte) {
+		item := string(tag)
+		if run > 1 {
+			item = strconv.Itoa(run) + item
+		}
+		//Golly keeps lines to at most 70 characters
+		if line+len(item) > 70 {
+			out.WriteString("\n")
+			line = 0
+		}
+		out.WriteString(item)
+		line += len(item)
+	}
+
+	endOfRows := 0
+	for _, row := range world {
+		//Dead cells at the end of a row, and empty rows at the end, are left out
+		last := len(row) - 1
+		for last >= 0 && row[last] != 255 {
+			last--
+		}
+		if last < 0 {
+			endOfRows++
+			continue
+		}
+		if endOfRows > 0 {
+			emit(endOfRows, '$')
+		}
+		for x := 0; x <= last; {
+			alive := row[x] == 255
+			run := 1
+			for x+run <= last && (row[x+run] == 255) == alive {
+				run++
+			}
+			if alive {
+				emit(run, 'o')
+			} else {
+				emit(run, 'b')
+			}
+			x += run
+		}
+		endOfRows = 1
+	}
+	emit(1, '!')
+	out.WriteString("\n")
+	return out.Flush()
+}
+
+//Writes gray levels as a plaintext pattern
+func writeCells(w io.Writer, world [][]byte, name string) error {
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, "!Name: %v\n", name)
+	for _, row := range world {
+		line := make([]byte, len(row))
+		for x, level := range row {
+			line[x] = '.'
+			if level == 255 {
+				line[x] = 'O'
+			}
+		}
+		out.Write(line)
+		out.WriteString("\n")
+	}
+	return out.Flush()
+}
+
+//Places a pattern on a board, with its top left corner at (offsetX, offsetY)
+func placePattern(pattern [][]byte, width int, height int, offsetX int, offsetY int) ([][]byte, error) {
+	patternWidth := 0
+	if len(pattern) > 0 {
+		patternWidth = len(pattern[0])
+	}
+	if offsetX < 0 || offsetY < 0 || offsetX+patternWidth > width || offsetY+len(pattern) > height {
+		return nil, errors.New(fmt.Sprintf("a %vx%v pattern at (%v, %v) does not fit on a %vx%v board",
+			patternWidth, len(pattern), offsetX, offsetY, width, height))
+	}
+	world := make([][]byte, height)
+	for y := range world {
+		world[y] = make([]byte, width)
+	}
+	for y, row := range pattern {
+		copy(world[offsetY+y][offsetX:], row)
+	}
+	return world, nil
+}
+
+//BoardSize returns the size of board that holds a pattern file placed at an offset,
+//read from the header of the file. Without a pattern file the board is 512x512.
+func BoardSize(path string, offsetX int, offsetY int) (int, int, error) {
+	if path == "" {
+		return 512, 512, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, 0, InputError{path, err}
+	}
+	format, err := formatOf(path)
+	if err != nil {
+		return 0, 0, InputError{path, err}
+	}
+	var width, height int
+	switch format {
+	case RLE:
+		var states [][]int
+		states, _, err = parseRLE(string(data))
+		width, height = sizeOf(states)
+	case Cells:
+		var states [][]int
+		states, err = parseCells(string(data))
+		width, height = sizeOf(states)
+	case PNG:
+		var config image.Config
+		config, err = png.DecodeConfig(bytes.NewReader(data))
+		width, height = config.Width, config.Height
+	default:
+		var image util.Netpbm
+		image, err = util.ParseNetpbm(data)
+		width, height = image.Width, image.Height
+	}
+	if err != nil {
+		return 0, 0, InputError{path, err}
+	}
+	return width + offsetX, height + offsetY, nil
+}
+
+func sizeOf(states [][]int) (int, int) {
+	if len(states) == 0 {
+		return 0, 0
+	}
+	return len(states[0]), len(states)
+}
+
+//DefaultThreshold is the gray level from which pixels of a netpbm input are alive, when Params.Threshold is 0
+const DefaultThreshold = 128
+
+//DefaultOutputName is the template images are saved under when Params.OutputName is empty
+const DefaultOutputName = "{width}x{height}x{turns}"
+
+//Returns the name an image is saved under, from a template. The template may use {width},
+//{height} and {turns}, {x} and {y} for the top left corner of the image, {rule}, and
+//{input} for the name of the input file without its extension.
+func outputName(template string, p Params, width int, height int, x int, y int, turns int) string {
+	if template == "" {
+		template = DefaultOutputName
+	}
+	input := fmt.Sprintf("%dx%d", p.ImageWidth, p.ImageHeight)
+	if p.Input != "" {
+		input = strings.TrimSuffix(filepath.Base(p.Input), filepath.Ext(p.Input))
+	}
+	return strings.NewReplacer(
+		"{width}", strconv.Itoa(width),
+		"{height}", strconv.Itoa(height),
+		"{turns}", strconv.Itoa(turns),
+		"{x}", strconv.Itoa(x),
+		"{y}", strconv.Itoa(y),
+		"{rule}", strings.Replace(p.Rule.String(), "/", "_", -1),
+		"{input}", input,
+	).Replace(template)
+}
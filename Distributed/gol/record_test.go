@@ -0,0 +1,56 @@
+package gol
+
+import (
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestRecord passes a blinker through the recorder, which should send every other turn as a frame
+func TestRecord(t *testing.T) {
+	p := Params{ImageWidth: 3, ImageHeight: 3, RecordEvery: 2}
+	in := make(chan Event)
+	out := make(chan Event, 100)
+	frames := make(chan [][]byte, 10)
+	saved := make(chan bool, 1)
+	saved <- true
+	go record(p, in, out, frames, saved)
+
+	vertical := []util.Cell{{X: 1, Y: 0}, {X: 1, Y: 2}, {X: 0, Y: 1}, {X: 2, Y: 1}}
+	for _, cell := range []util.Cell{{X: 0, Y: 1}, {X: 1, Y: 1}, {X: 2, Y: 1}} {
+		in <- CellFlipped{CompletedTurns: 0, Cell: cell}
+	}
+	in <- TurnComplete{CompletedTurns: 0}
+	for turn := 1; turn <= 3; turn++ {
+		for _, cell := range vertical {
+			in <- CellFlipped{CompletedTurns: turn, Cell: cell}
+		}
+		in <- TurnComplete{CompletedTurns: turn}
+	}
+	close(in)
+
+	events := 0
+	for range out {
+		events++
+	}
+	if events != 3+1+3*5 {
+		t.Errorf("passed on %v events, expected %v", events, 3+1+3*5)
+	}
+	var recorded [][][]byte
+	for frame := range frames {
+		recorded = append(recorded, frame)
+	}
+	//The frames are the board after turns 0 and 2, which are both horizontal
+	if len(recorded) != 2 {
+		t.Fatalf("recorded %v frames, expected 2", len(recorded))
+	}
+	for _, frame := range recorded {
+		for y := range frame {
+			for x := range frame[y] {
+				if (frame[y][x] == 255) != (y == 1) {
+					t.Fatalf("frame %v has cell (%v, %v) wrong", frame, x, y)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package gol
+
+import (
+	"errors"
+	"fmt"
+	"net/rpc"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+//Connects to every worker node given to the broker
+func dialWorkers(addrs []string) ([]*rpc.Client, error) {
+	clients := []*rpc.Client{}
+	for _, addr := range addrs {
+		client, err := rpc.Dial("tcp", addr)
+		if err != nil {
+			for _, c := range clients {
+				c.Close()
+			}
+			return nil, err
+		}
+		clients = append(clients, client)
+	}
+	return clients, nil
+}
+
+//Hangs up on the worker nodes used by one game
+func closeWorkerNodes(clients []*rpc.Client) {
+	for _, client := range clients {
+		client.Close()
+	}
+}
+
+//Tells every worker node to exit once the broker has disconnected.
+//Nodes that cannot be reached are skipped.
+func ShutdownWorkerNodes(addrs []string) {
+	for _, addr := range addrs {
+		client, err := rpc.Dial("tcp", addr)
+		if err != nil {
+			continue
+		}
+		client.Call(WorkerShutdown, StripKey{}, new(StripReport))
+		client.Close()
+	}
+}
+
+var errKilled = errors.New("worker killed")
+var errTimeout = errors.New("worker node timed out")
+
+//Waits for an RPC call to a worker node to finish. Gives up if the node takes
+//longer than the timeout, or if the worker is killed in the meantime.
+func waitForCall(call *rpc.Call, timeout time.Duration, killChan <-chan bool) error {
+	var timedOut <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		timedOut = timer.C
+	}
+	select {
+	case <-call.Done:
+		//A node that was shut down while the worker was being killed has not failed
+		select {
+		case <-killChan:
+			return errKilled
+		default:
+			return call.Error
+		}
+	case <-timedOut:
+		return errTimeout
+	case <-killChan:
+		return errKilled
+	}
+}
+
+//remoteWorker stands in for a local worker goroutine. It swaps lines with the
+//distributor in the same way, but executes each turn on a worker node over RPC.
+//Only the edges of the strip are kept here between turns, so the alive cells are
+//fetched from the node when the distributor asks for them.
+func remoteWorker(client *rpc.Client, key StripKey, world [][]byte, p workerParams, c workerChannels, workerID int) {
+	//Releasing the strip is not waited for, as the node may be the reason this worker stopped
+	defer client.Go(WorkerRelease, key, new(StripReport), nil)
+
+	isPaused := p.Paused
+	flipped := p.Flipped
+	edges := stripEdges(PackBoard(world, p.ImageWidth), workerID)
+	turn := p.StartTurn
+	alive := func() ([]util.Cell, error) {
+		report := StripAliveReport{}
+		call := client.Go(WorkerAlive, key, &report, make(chan *rpc.Call, 1))
+		err := waitForCall(call, p.Timeout, c.killChan)
+		return report.Alive, err
+	}
+
+	for {
+		if !isPaused {
+			if turn >= p.Turns {
+				break
+			}
+			halo, ok := exchangeLines(edges, c)
+			if !ok {
+				return
+			}
+			report := HaloReport{}
+			request := HaloRequest{
+				Key:         key,
+				Turn:        turn,
+				LowerLine:   halo.lowerLine,
+				UpperLine:   halo.upperLine,
+				LeftColumn:  halo.leftColumn,
+				RightColumn: halo.rightColumn,
+				Flipped:     flipped,
+			}
+			call := client.Go(WorkerTurn, request, &report, make(chan *rpc.Call, 1))
+			err := waitForCall(call, p.Timeout, c.killChan)
+			if err == errKilled {
+				return
+			} else if err != nil {
+				fmt.Println("Error: worker", workerID, "failed:", err)
+				select {
+				case c.events <- WorkerFailed{CompletedTurns: turn, WorkerID: workerID, Remote: true}:
+				case <-c.killChan:
+				}
+				return
+			}
+			edges = filler{
+				lowerLine:   report.LowerLine,
+				upperLine:   report.UpperLine,
+				leftColumn:  report.LeftColumn,
+				rightColumn: report.RightColumn,
+				workerID:    workerID,
+			}
+			r, ok := completeTurn(WorkerTurnComplete{CompletedTurns: turn, CellsCount: report.Count, Hash: report.Hash, Flipped: report.Flipped, WorkerID: workerID}, c, alive)
+			if !ok {
+				return
+			}
+			turn, isPaused, flipped = r.turn, r.pause, r.flipped
+		}
+		select {
+		case k := <-c.keyPresses:
+			switch k {
+			case 'p':
+				isPaused = !isPaused
+			case 'r':
+				isPaused = false
+			}
+		case <-c.fetch:
+			sendAlive(c, workerID, alive)
+		case <-c.killChan:
+			return
+		default:
+		}
+	}
+	cells, err := alive()
+	if err == errKilled {
+		return
+	} else if err != nil {
+		fmt.Println("Error: worker", workerID, "failed:", err)
+		select {
+		case c.events <- WorkerFailed{CompletedTurns: turn, WorkerID: workerID, Remote: true}:
+		case <-c.killChan:
+		}
+		return
+	}
+	c.events <- WorkerFinalTurnComplete{CompletedTurns: turn, Alive: cells}
+}
@@ -0,0 +1,97 @@
+package gol
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+//Rule decides which cells are born and which survive, from their number of alive
+//neighbours. Bit n of Birth is set if a dead cell with n alive neighbours comes
+//alive, and bit n of Survival if an alive cell with n alive neighbours stays alive.
+//The zero Rule is Conway's Game of Life, B3/S23.
+type Rule struct {
+	Birth    uint16
+	Survival uint16
+}
+
+//Conway is the rule of Conway's Game of Life
+var Conway = Rule{Birth: 1 << 3, Survival: 1<<2 | 1<<3}
+
+//ParseRule reads a rule in B/S notation, such as B3/S23 for Conway's Game of Life,
+//B36/S23 for HighLife or B2/S for Seeds. The S/B order, S23/B3, is accepted too.
+//Generations rules, such as B2/S/C3, are refused: the strips are packed one bit to a
+//cell, so cells can only be dead or alive. The SingleThread and Concurrent versions run them.
+func ParseRule(s string) (Rule, error) {
+	rule := Rule{}
+	parts := strings.Split(strings.ToUpper(strings.TrimSpace(s)), "/")
+	if len(parts) == 3 {
+		return rule, errors.New(fmt.Sprintf("rule %q is a Generations rule, which the distributed engine cannot run as its cells are only dead or alive", s))
+	}
+	if len(parts) != 2 {
+		return rule, errors.New(fmt.Sprintf("rule %q is not of the form B3/S23", s))
+	}
+	seen := map[byte]bool{}
+	for _, part := range parts {
+		if len(part) == 0 || (part[0] != 'B' && part[0] != 'S') || seen[part[0]] {
+			return rule, errors.New(fmt.Sprintf("rule %q is not of the form B3/S23", s))
+		}
+		seen[part[0]] = true
+		counts := uint16(0)
+		for _, digit := range part[1:] {
+			if digit < '0' || digit > '8' {
+				return rule, errors.New(fmt.Sprintf("rule %q has a neighbour count that is not between 0 and 8", s))
+			}
+			counts |= 1 << uint(digit-'0')
+		}
+		if part[0] == 'B' {
+			rule.Birth = counts
+		} else {
+			rule.Survival = counts
+		}
+	}
+	if rule == (Rule{}) {
+		return rule, errors.New(fmt.Sprintf("rule %q kills every cell", s))
+	}
+	return rule, nil
+}
+
+//NextState decides whether a cell is alive on the next turn
+func (r Rule) NextState(alive bool, neighbours int) bool {
+	if r == (Rule{}) {
+		r = Conway
+	}
+	if alive {
+		return r.Survival&(1<<uint(neighbours)) != 0
+	}
+	return r.Birth&(1<<uint(neighbours)) != 0
+}
+
+func (r Rule) String() string {
+	if r == (Rule{}) {
+		r = Conway
+	}
+	s := "B"
+	for n := 0; n <= 8; n++ {
+		if r.Birth&(1<<uint(n)) != 0 {
+			s += fmt.Sprint(n)
+		}
+	}
+	s += "/S"
+	for n := 0; n <= 8; n++ {
+		if r.Survival&(1<<uint(n)) != 0 {
+			s += fmt.Sprint(n)
+		}
+	}
+	return s
+}
+
+//Set parses a rule given on the command line, so that a Rule can be used with flag.Var
+func (r *Rule) Set(s string) error {
+	rule, err := ParseRule(s)
+	if err != nil {
+		return err
+	}
+	*r = rule
+	return nil
+}
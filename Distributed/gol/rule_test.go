@@ -0,0 +1,162 @@
+package gol
+
+import (
+	"math/rand"
+	"testing"
+)
+
+//Executes one turn on a whole world, split between p.Threads worker nodes that
+//are sent their halos by the distributor, or a single worker node by default
+func step(p Params, world [][]byte) [][]byte {
+	if p.Threads == 0 {
+		p.Threads = 1
+	}
+	boards := make([]BitBoard, p.Threads)
+	edges := make([]filler, p.Threads)
+	for t := range edges {
+		startY, endY := stripBounds(p, t)
+		boards[t] = PackBoard(world[startY:endY], p.ImageWidth)
+		edges[t] = stripEdges(boards[t], t)
+	}
+	next := [][]byte{}
+	for t, halo := range buildHalos(p, edges) {
+		startY, endY := stripBounds(p, t)
+		request := HaloRequest{
+			LowerLine:   halo.lowerLine,
+			UpperLine:   halo.upperLine,
+			LeftColumn:  halo.leftColumn,
+			RightColumn: halo.rightColumn,
+		}
+		strip := NewBitBoard(p.ImageWidth, endY-startY)
+		CalculateStrip(p, boards[t], strip, startY, request)
+		next = append(next, strip.unpack()...)
+	}
+	return next
+}
+
+//Builds a world from rows of '#' for alive and '.' for dead cells
+func makeWorld(rows ...string) [][]byte {
+	world := make([][]byte, len(rows))
+	for y, row := range rows {
+		world[y] = make([]byte, len(row))
+		for x, c := range row {
+			if c == '#' {
+				world[y][x] = 255
+			}
+		}
+	}
+	return world
+}
+
+func assertEqualWorld(t *testing.T, name string, given, expected [][]byte) {
+	for y := range expected {
+		for x := range expected[y] {
+			if given[y][x] != expected[y][x] {
+				t.Errorf("%v: cell (%v, %v) is %v, expected %v", name, x, y, given[y][x], expected[y][x])
+			}
+		}
+	}
+}
+
+func mustParseRule(t *testing.T, s string) Rule {
+	rule, err := ParseRule(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rule
+}
+
+// TestParseRule checks that rules in B/S notation are read correctly, and that broken ones are rejected.
+func TestParseRule(t *testing.T) {
+	valid := map[string]Rule{
+		"B3/S23":       Conway,
+		"S23/B3":       Conway,
+		"b36/s23":      {Birth: 1<<3 | 1<<6, Survival: 1<<2 | 1<<3},
+		"B2/S":         {Birth: 1 << 2},
+		"B3678/S34678": {Birth: 1<<3 | 1<<6 | 1<<7 | 1<<8, Survival: 1<<3 | 1<<4 | 1<<6 | 1<<7 | 1<<8},
+	}
+	for s, expected := range valid {
+		rule := mustParseRule(t, s)
+		if rule != expected {
+			t.Errorf("%q parsed as %v, expected %v", s, rule, expected)
+		}
+	}
+	//Generations rules need more states than one bit holds
+	for _, s := range []string{"", "B3", "B9/S23", "B3/B3", "X3/S23", "B/S", "B2/S/C3", "B2/S/3"} {
+		if _, err := ParseRule(s); err == nil {
+			t.Errorf("%q should not parse", s)
+		}
+	}
+	if (Rule{}).String() != "B3/S23" {
+		t.Errorf("the zero rule should be Conway's, got %v", Rule{})
+	}
+}
+
+// TestSeeds checks that under Seeds (B2/S) a block dies, giving birth to the 8 cells beside its edges.
+func TestSeeds(t *testing.T) {
+	p := Params{ImageWidth: 8, ImageHeight: 8, Rule: mustParseRule(t, "B2/S")}
+	world := makeWorld(
+		"........",
+		"........",
+		"........",
+		"...##...",
+		"...##...",
+		"........",
+		"........",
+		"........")
+	expected := makeWorld(
+		"........",
+		"........",
+		"...##...",
+		"..#..#..",
+		"..#..#..",
+		"...##...",
+		"........",
+		"........")
+	assertEqualWorld(t, "Seeds", step(p, world), expected)
+}
+
+// TestHighLife checks that HighLife (B36/S23) only differs from Conway's Game of Life
+// by giving birth to cells with 6 alive neighbours.
+func TestHighLife(t *testing.T) {
+	world := makeWorld(
+		"........",
+		"........",
+		"..###...",
+		"........",
+		"..###...",
+		"........",
+		"........",
+		"........")
+	p := Params{ImageWidth: 8, ImageHeight: 8}
+	expected := step(p, world)
+	expected[3][3] = 255
+	p.Rule = mustParseRule(t, "B36/S23")
+	assertEqualWorld(t, "HighLife", step(p, world), expected)
+}
+
+// TestDayAndNight checks that Day & Night (B3678/S34678) treats dead and alive cells alike,
+// so that inverting a random world and then executing a turn gives the inverse of its next turn.
+func TestDayAndNight(t *testing.T) {
+	p := Params{ImageWidth: 16, ImageHeight: 16, Rule: mustParseRule(t, "B3678/S34678")}
+	random := rand.New(rand.NewSource(1))
+	world := make([][]byte, p.ImageHeight)
+	inverse := make([][]byte, p.ImageHeight)
+	for y := range world {
+		world[y] = make([]byte, p.ImageWidth)
+		inverse[y] = make([]byte, p.ImageWidth)
+		for x := range world[y] {
+			if random.Intn(2) == 0 {
+				world[y][x] = 255
+			}
+			inverse[y][x] = 255 - world[y][x]
+		}
+	}
+	next := step(p, world)
+	for y := range next {
+		for x := range next[y] {
+			next[y][x] = 255 - next[y][x]
+		}
+	}
+	assertEqualWorld(t, "Day & Night", step(p, inverse), next)
+}
@@ -0,0 +1,44 @@
+package gol
+
+import (
+	"fmt"
+	"net"
+	"net/rpc"
+	"sync"
+	"time"
+)
+
+//How long ServeRPC waits for clients to hang up once it has been told to shut down
+const disconnectTimeout = 5 * time.Second
+
+//ServeRPC serves the registered RPC methods on a listener until shutdown is closed, then
+//waits for connected clients to hang up so that their last replies are delivered. Used by
+//both the engine and the worker nodes.
+func ServeRPC(listener net.Listener, shutdown <-chan bool) {
+	connections := sync.WaitGroup{}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			connections.Add(1)
+			go func() {
+				rpc.ServeConn(conn)
+				connections.Done()
+			}()
+		}
+	}()
+	<-shutdown
+	listener.Close()
+	done := make(chan bool)
+	go func() {
+		connections.Wait()
+		done <- true
+	}()
+	select {
+	case <-done:
+	case <-time.After(disconnectTimeout):
+		fmt.Println("Timed out waiting for clients to disconnect")
+	}
+}
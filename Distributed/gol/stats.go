@@ -0,0 +1,128 @@
+package gol
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+//Columns of a statistics csv file. The first two are those of the csv files in check/alive.
+var statsColumns = []string{"completed_turns", "alive_cells", "births", "deaths", "x", "y", "width", "height"}
+
+func (event TurnStats) csvRow() []string {
+	row := []string{}
+	for _, n := range []int{event.CompletedTurns, event.AliveCells, event.Births, event.Deaths, event.X, event.Y, event.Width, event.Height} {
+		row = append(row, strconv.Itoa(n))
+	}
+	return row
+}
+
+//statsBoard keeps count of the alive cells of a board along each of its rows and columns,
+//so that the statistics of a turn come from the cells it flipped
+type statsBoard struct {
+	alive   map[util.Cell]bool
+	rows    map[int]int
+	columns map[int]int
+}
+
+func newStatsBoard(alive []util.Cell) statsBoard {
+	b := statsBoard{alive: map[util.Cell]bool{}, rows: map[int]int{}, columns: map[int]int{}}
+	b.flip(alive)
+	return b
+}
+
+//Flips cells of the board, returning the number of them born and the number that died
+func (b statsBoard) flip(flipped []util.Cell) (int, int) {
+	births, deaths := 0, 0
+	for _, cell := range flipped {
+		if !b.alive[cell] {
+			b.alive[cell] = true
+			b.rows[cell.Y]++
+			b.columns[cell.X]++
+			births++
+			continue
+		}
+		delete(b.alive, cell)
+		if b.rows[cell.Y]--; b.rows[cell.Y] == 0 {
+			delete(b.rows, cell.Y)
+		}
+		if b.columns[cell.X]--; b.columns[cell.X] == 0 {
+			delete(b.columns, cell.X)
+		}
+		deaths++
+	}
+	return births, deaths
+}
+
+//Flips the cells of a turn, returning the statistics of the board after it
+func (b statsBoard) turn(turn int, flipped []util.Cell) TurnStats {
+	stats := TurnStats{CompletedTurns: turn}
+	stats.Births, stats.Deaths = b.flip(flipped)
+	stats.AliveCells = len(b.alive)
+	if len(b.alive) > 0 {
+		minX, maxX := span(b.columns)
+		minY, maxY := span(b.rows)
+		stats.X, stats.Y, stats.Width, stats.Height = minX, minY, maxX-minX+1, maxY-minY+1
+	}
+	return stats
+}
+
+//Returns the smallest and largest of the keys of a map
+func span(counts map[int]int) (int, int) {
+	first := true
+	min, max := 0, 0
+	for i := range counts {
+		if first || i < min {
+			min = i
+		}
+		if first || i > max {
+			max = i
+		}
+		first = false
+	}
+	return min, max
+}
+
+//Writes the statistics of the turns that arrive to the Stats file, as JSON Lines if it
+//ends in .jsonl and csv otherwise, so that writing does not hold up the reports from the
+//engine. Runs until the channel is closed, then sends on done once the file has been written.
+func writeStats(p Params, in <-chan []TurnStats, done chan<- bool) {
+	defer func() { done <- true }()
+	_ = os.MkdirAll(filepath.Dir(p.Stats), os.ModePerm)
+	file, err := os.Create(p.Stats)
+	if err != nil {
+		fmt.Println("Error: could not write statistics.", err)
+		for range in {
+		}
+		return
+	}
+	defer file.Close()
+	buffered := bufio.NewWriter(file)
+
+	var write func(TurnStats)
+	flush := func() {}
+	if strings.HasSuffix(p.Stats, ".jsonl") {
+		encoder := json.NewEncoder(buffered)
+		write = func(s TurnStats) { util.Check(encoder.Encode(s)) }
+	} else {
+		writer := csv.NewWriter(buffered)
+		util.Check(writer.Write(statsColumns))
+		write = func(s TurnStats) { util.Check(writer.Write(s.csvRow())) }
+		flush = writer.Flush
+	}
+
+	for rows := range in {
+		for _, row := range rows {
+			write(row)
+		}
+	}
+	flush()
+	util.Check(buffered.Flush())
+}
@@ -12,6 +12,15 @@ var Initialise = "Engine.Initialise"
 var Report = "Engine.Report"
 var Tick = "Engine.Tick"
 var KeyPress = "Engine.KeyPress"
+var Frames = "Engine.Frames"
+var EditCells = "Engine.Edit"
+var Seek = "Engine.Seek"
+
+var WorkerInitialise = "Worker.Initialise"
+var WorkerTurn = "Worker.Turn"
+var WorkerAlive = "Worker.Alive"
+var WorkerRelease = "Worker.Release"
+var WorkerShutdown = "Worker.Shutdown"
 
 type InitParams struct {
 	Alive  []util.Cell
@@ -20,10 +29,12 @@ type InitParams struct {
 
 //Structure used by controller to send initial GoL parameters
 //to server. Contains initially alive cells, image dimensions
-//and turns to be executed
+//and turns to be executed. SessionID picks the game to continue
+//or restart, 0 for a new game or the latest one.
 type InitRequest struct {
 	Params         *InitParams
 	ShouldContinue int
+	SessionID      int
 }
 
 /*
@@ -49,19 +60,45 @@ type TickReport struct {
 	Alive      []util.Cell
 	CellsCount int
 	ReportType ReportType
+	State      State
+	//Period of the cycle found, for a CycleFound report
+	Period int
+	//Statistics of the turns since the last Statistics report
+	Stats []TurnStats
+	//Telemetry of the turns since the last Measured report
+	Telemetry Telemetry
 }
 
+//Returned by Initialise. The controller is subscribed to the game it started
+//or continued, and holds the controlling role if no other client does.
 type StatusReport struct {
-	Alive []util.Cell
-	Turns int
+	Alive        []util.Cell
+	Turns        int
+	SessionID    int
+	SubscriberID int
+	Controlling  bool
+}
+
+//Structure used by a client to attach to a running game without starting it.
+//Observers never take the controlling role.
+type SubscribeRequest struct {
+	SessionID int
+	Observe   bool
+}
+
+type SubscribeReport struct {
+	SessionID    int
+	SubscriberID int
+	Controlling  bool
 }
 
 type ReportRequest struct {
-	//
+	SubscriberID int
 }
 
 type KeyPressRequest struct {
-	Key rune
+	Key          rune
+	SubscriberID int
 }
 
 type KeyPressReport struct {
@@ -69,3 +106,97 @@ type KeyPressReport struct {
 	Turns int
 	State State
 }
+
+//Structure used by the controlling client to change cells of a paused game
+type EditRequest struct {
+	Edit         Edit
+	SubscriberID int
+}
+
+//Returned by Edit. Flipped holds the cells that the edit changed, which is
+//none if the game was not paused.
+type EditReport struct {
+	Flipped []util.Cell
+	Turns   int
+}
+
+//Structure used by the controlling client to move a paused game to another turn.
+//It is answered with a KeyPressReport once the game has paused on the turn.
+type SeekRequest struct {
+	Turn         int
+	SubscriberID int
+}
+
+//Identifies one strip of one game on a worker node, so that a single
+//worker server can hold strips from several games at once
+type StripKey struct {
+	Job   int64
+	Strip int
+}
+
+//Structure used by the broker to hand a worker node its strip of the world
+type StripRequest struct {
+	Key    StripKey
+	StartY int
+	World  [][]byte
+	Params Params
+}
+
+type StripReport struct {
+	//
+}
+
+//Structure used by the broker to send a worker the cells just outside its strip,
+//packed one bit to a cell. UpperLine and LowerLine are the lines above and below the
+//strip, and LeftColumn and RightColumn the columns beside it, including the corners.
+type HaloRequest struct {
+	Key         StripKey
+	Turn        int
+	LowerLine   []uint64
+	UpperLine   []uint64
+	LeftColumn  []uint64
+	RightColumn []uint64
+	//Set to have the cells flipped by the turn sent back, which only live viewers and
+	//the statistics need
+	Flipped bool
+}
+
+//Structure returned by a worker after a turn. Contains the new top and bottom lines
+//and the first and last columns of the strip, from which the broker builds the
+//cells just outside every strip on the next turn. The alive cells are not sent, only
+//their number and the change to the board's hash that the cycle detector keeps.
+type HaloReport struct {
+	LowerLine   []uint64
+	UpperLine   []uint64
+	LeftColumn  []uint64
+	RightColumn []uint64
+	Count       int
+	Hash        uint64
+	Flipped     []util.Cell
+}
+
+//Returned by Alive. Holds the alive cells of a strip, which the broker only asks for
+//when it needs the board, e.g. to save it or once the last turn is done.
+type StripAliveReport struct {
+	Alive []util.Cell
+}
+
+//One turn of the game as seen by a live viewer. Holds the cells flipped by the
+//turn, or the whole board if Full is set, e.g. after the viewer has missed frames.
+type Frame struct {
+	Turn    int
+	Flipped []util.Cell
+	Alive   []util.Cell
+	Full    bool
+}
+
+//Structure used by a live viewer to ask for the frames completed since its last request.
+//Resync asks the engine for a full frame of the board.
+type FramesRequest struct {
+	Resync       bool
+	SubscriberID int
+}
+
+type FramesReport struct {
+	Frames []Frame
+}
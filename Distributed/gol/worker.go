@@ -1,6 +1,9 @@
 package gol
 
 import (
+	"fmt"
+	"time"
+
 	"uk.ac.bris.cs/gameoflife/util"
 )
 
@@ -10,6 +13,11 @@ type workerParams struct {
 	ImageWidth  int
 	ImageHeight int
 	Turns       int
+	StartTurn   int
+	Paused      bool
+	Flipped     bool
+	Timeout     time.Duration
+	Rule        Rule
 }
 
 type workerChannels struct {
@@ -17,76 +25,92 @@ type workerChannels struct {
 	distributorEvents <-chan Event
 	globalFiller      chan<- filler
 	workerFiller      <-chan filler
-	finishedChannel   <-chan int
+	finishedChannel   <-chan release
 	keyPresses        <-chan rune
 	killChan          chan bool
+	fetch             <-chan bool
+	fetched           chan<- stripAlive
 }
 
-//Used to send the top and bottom arrays of each worker's world to the distributor,
-//as well as receive them from it
-type filler struct {
-	lowerLine []byte
-	upperLine []byte
-	workerID  int
+//Sent by the distributor to every worker once all of them have finished a turn,
+//to let them go on to the next one
+type release struct {
+	//Turn to go on from, which is past any cycles that the distributor skipped
+	turn int
+	//Set to pause before the next turn, so that every worker stops on the same turn
+	pause bool
+	//Set to send the cells flipped by the next turn
+	flipped bool
+}
+
+//Sent by a worker that the distributor asked for the alive cells of its strip
+type stripAlive struct {
+	workerID int
+	alive    []util.Cell
+	err      error
 }
 
-func worker(world [][]byte, p workerParams, c workerChannels, workerID int) ([][]byte, int) {
+//Used to send the edges of each worker's world to the distributor, as well as receive
+//the cells just outside the world from it, packed one bit to a cell. A worker sends its
+//top row as lowerLine and its bottom row as upperLine, along with its first and last
+//columns. It receives the lines above and below its world, and the columns beside it,
+//which are two cells longer than the world to include the corners.
+type filler struct {
+	lowerLine   []uint64
+	upperLine   []uint64
+	leftColumn  []uint64
+	rightColumn []uint64
+	workerID    int
+}
 
-	isPaused := false
-	//For all initially alive cells send a CellFlipped Event.
-	for y, elem := range world {
-		for x, cell := range elem {
-			if cell == 255 {
-				d := util.Cell{X: x, Y: y + p.StartY}
-				cellFlip := CellFlipped{CompletedTurns: 0, Cell: d}
-				c.events <- cellFlip
+//Runs a worker on this machine. A worker that panics is reported as failed, so that the
+//distributor starts the workers again from the last completed turn.
+func localWorker(world [][]byte, p workerParams, c workerChannels, workerID int) {
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Println("Error: worker", workerID, "failed:", r)
+			select {
+			case c.events <- WorkerFailed{CompletedTurns: p.StartTurn, WorkerID: workerID}:
+			case <-c.killChan:
 			}
 		}
-	}
+	}()
+	worker(world, p, c, workerID)
+}
 
-	turn := 0
-	aliveCells := calculateAliveCells(p, world, workerID)
+func worker(world [][]byte, p workerParams, c workerChannels, workerID int) (BitBoard, int) {
+
+	isPaused := p.Paused
+	flipped := p.Flipped
+	turn := p.StartTurn
+	board := PackBoard(world, p.ImageWidth)
+	next := NewBitBoard(p.ImageWidth, p.ImageHeight)
+	alive := func() ([]util.Cell, error) {
+		return calculateAliveCells(p, board, workerID), nil
+	}
 
 	//Executes all turns of the Game of Life.
 	for {
 		//TODO: Semaphores
 		//Send top and bottom arrays to distributor
 		if !isPaused {
-			if turn > p.Turns || (turn == 0 && p.Turns == 0) {
+			if turn >= p.Turns {
 				break
 			}
-			aliveCells = []util.Cell{}
-			c.globalFiller <- filler{lowerLine: world[0], upperLine: world[p.ImageHeight-1], workerID: workerID}
-			//Receive lines outside world's boundaries for use in this worker
-			receivedFiller := <-c.workerFiller
-			receivedFiller2 := <-c.workerFiller
-			//Decide which filler delivered which line
-			upperLine := []byte{}
-			lowerLine := []byte{}
-			if receivedFiller.upperLine != nil {
-				upperLine = receivedFiller.upperLine
-				lowerLine = receivedFiller2.lowerLine
-			} else {
-				upperLine = receivedFiller2.upperLine
-				lowerLine = receivedFiller.lowerLine
+			halo, ok := exchangeLines(stripEdges(board, workerID), c)
+			if !ok {
+				return board, turn
 			}
 			//Execute turn of game
-			world, aliveCells = calculateNextState(workerID, p, world, c, turn, upperLine, lowerLine)
+			count, hash, cells := calculateNextState(workerID, p, board, next, halo, flipped)
+			board, next = next, board
 			//Send completion event to distributor
-			c.events <- WorkerTurnComplete{CompletedTurns: turn, Alive: aliveCells}
-			//fmt.Println("Worker", workerID, "completed turn", turn)
-			canContinue := false
-			for {
-				select {
-				case x := <-c.finishedChannel:
-					turn = x
-					canContinue = true
-				}
-				if canContinue {
-					break
-				}
+			r, ok := completeTurn(WorkerTurnComplete{CompletedTurns: turn, CellsCount: count, Hash: hash, Flipped: cells, WorkerID: workerID}, c, alive)
+			if !ok {
+				return board, turn
 			}
-			turn++
+			turn, isPaused, flipped = r.turn, r.pause, r.flipped
+			//fmt.Println("Worker", workerID, "completed turn", turn)
 		}
 		select {
 		case k := <-c.keyPresses:
@@ -94,20 +118,118 @@ func worker(world [][]byte, p workerParams, c workerChannels, workerID int) ([][
 			case 'p':
 				isPaused = !isPaused
 			case 's':
-				c.events <- WorkerSaveImage{CompletedTurns: turn, Alive: aliveCells}
+				c.events <- WorkerSaveImage{CompletedTurns: turn, Alive: calculateAliveCells(p, board, workerID)}
 			case 'q':
-				c.events <- WorkerSaveImage{CompletedTurns: turn, Alive: aliveCells}
+				c.events <- WorkerSaveImage{CompletedTurns: turn, Alive: calculateAliveCells(p, board, workerID)}
 				isPaused = true
 			case 'r':
 				isPaused = false
 			}
+		case <-c.fetch:
+			sendAlive(c, workerID, alive)
 		case <-c.killChan:
-			return world, turn
+			return board, turn
 		default:
 		}
 	}
-	c.events <- WorkerFinalTurnComplete{CompletedTurns: turn, Alive: aliveCells}
-	return world, turn
+	c.events <- WorkerFinalTurnComplete{CompletedTurns: turn, Alive: calculateAliveCells(p, board, workerID)}
+	return board, turn
+}
+
+//Sends the edges of a strip to the distributor, and receives the cells just
+//outside the strip's boundaries once every worker has sent its edges.
+//Returns false if the worker was killed while waiting.
+func exchangeLines(edges filler, c workerChannels) (filler, bool) {
+	select {
+	case c.globalFiller <- edges:
+	case <-c.killChan:
+		return filler{}, false
+	}
+	//Receive lines outside world's boundaries for use in this worker
+	select {
+	case halo := <-c.workerFiller:
+		return halo, true
+	case <-c.killChan:
+		return filler{}, false
+	}
+}
+
+//Returns the rows and columns on the edges of a worker's world
+func stripEdges(world BitBoard, workerID int) filler {
+	h := world.height
+	leftColumn := make([]uint64, wordsFor(h))
+	rightColumn := make([]uint64, wordsFor(h))
+	for y, row := range world.rows {
+		setBit(leftColumn, y, getBit(row, 0))
+		setBit(rightColumn, y, getBit(row, world.width-1))
+	}
+	return filler{
+		lowerLine:   append([]uint64{}, world.rows[0]...),
+		upperLine:   append([]uint64{}, world.rows[h-1]...),
+		leftColumn:  leftColumn,
+		rightColumn: rightColumn,
+		workerID:    workerID,
+	}
+}
+
+//Tells the distributor that this worker has finished a turn, then waits for all other
+//workers to finish it too, sending the alive cells of the strip if they are asked for
+//in the meantime. Returns false if the worker was killed while waiting.
+func completeTurn(e WorkerTurnComplete, c workerChannels, alive func() ([]util.Cell, error)) (release, bool) {
+	select {
+	case c.events <- e:
+	case <-c.killChan:
+		return release{}, false
+	}
+	for {
+		select {
+		case r := <-c.finishedChannel:
+			return r, true
+		case <-c.fetch:
+			sendAlive(c, e.WorkerID, alive)
+		case <-c.killChan:
+			return release{}, false
+		}
+	}
+}
+
+//Sends the distributor the alive cells of a worker's strip, which it asked for
+func sendAlive(c workerChannels, workerID int, alive func() ([]util.Cell, error)) {
+	cells, err := alive()
+	c.fetched <- stripAlive{workerID: workerID, alive: cells, err: err}
+}
+
+//CalculateStrip executes one turn on a strip of the world starting at row startY into next,
+//given the cells just outside the strip. Returns the edges of the new strip and its number
+//of alive cells for the distributor, along with the cells flipped if they were asked for.
+//Used by remote worker nodes.
+func CalculateStrip(p Params, world BitBoard, next BitBoard, startY int, req HaloRequest) HaloReport {
+	workerParams := workerParams{
+		StartY:      startY,
+		EndY:        startY + world.height,
+		ImageWidth:  p.ImageWidth,
+		ImageHeight: world.height,
+		Turns:       p.Turns,
+		Rule:        p.Rule,
+	}
+	halo := filler{lowerLine: req.LowerLine, upperLine: req.UpperLine, leftColumn: req.LeftColumn, rightColumn: req.RightColumn}
+	count, hash, flipped := calculateNextState(0, workerParams, world, next, halo, req.Flipped)
+	edges := stripEdges(next, 0)
+	return HaloReport{
+		LowerLine:   edges.lowerLine,
+		UpperLine:   edges.upperLine,
+		LeftColumn:  edges.leftColumn,
+		RightColumn: edges.rightColumn,
+		Count:       count,
+		Hash:        hash,
+		Flipped:     flipped,
+	}
+}
+
+//StripAlive returns the alive cells of a strip of the world starting at row startY.
+//Used by remote worker nodes when the distributor asks for the board.
+func StripAlive(world BitBoard, startY int) []util.Cell {
+	return setCells(world.rows, startY)
 }
 
 func createNewWorld(world [][]byte, p workerParams) [][]byte {
@@ -129,112 +251,17 @@ func duplicateArray(array []byte, p workerParams) []byte {
 	return newArray
 }
 
-func calculateNextState(id int, p workerParams, world [][]byte, c workerChannels,
-	completedTurns int, upperLine []byte, lowerLine []byte) ([][]byte, []util.Cell) {
-	aliveCells := []util.Cell{}
-	h := p.ImageHeight
-	w := p.ImageWidth
-	nworld := make([][]byte, h, w)
-	for y, a := range world {
-		nA := make([]byte, w)
-		for x, b := range a {
-			nB := byte(0)
-			ln := calculateAliveNeighbours(id, h, w, world, x, y, upperLine, lowerLine)
-			if b == 0 {
-				if ln == 3 {
-					nB = 255
-					aliveCells = append(aliveCells, util.Cell{X: x, Y: y + p.StartY})
-					sendFlippedEvent(x, y+p.StartY, completedTurns, c)
-				} else {
-					nB = 0
-				}
-			} else {
-				if ln < 2 || ln > 3 {
-					nB = 0
-					sendFlippedEvent(x, y+p.StartY, completedTurns, c)
-				} else {
-					aliveCells = append(aliveCells, util.Cell{X: x, Y: y + p.StartY})
-					nB = 255
-				}
-			}
-			nA[x] = nB
-		}
-		nworld[y] = nA
-	}
-	return nworld, aliveCells
-}
-
-func sendFlippedEvent(x int, y int, completedTurns int, c workerChannels) {
-	cell := util.Cell{X: x, Y: y}
-	c.events <- CellFlipped{CompletedTurns: completedTurns, Cell: cell}
-}
-
-func calculateAliveCells(p workerParams, world [][]byte, workerID int) []util.Cell {
-	alive := []util.Cell{}
-	for y := 0; y < p.ImageHeight; y++ {
-		for x := 0; x < p.ImageWidth; x++ {
-			if world[y][x] == 255 {
-				coord := util.Cell{X: x, Y: y + p.StartY}
-				alive = append(alive, coord)
-			}
-		}
+//Executes one turn of a strip into next. Returns the number of cells alive after the turn and
+//the change to the hash of the board, along with the cells that flipped if they are asked for.
+func calculateNextState(id int, p workerParams, world BitBoard, next BitBoard, halo filler, flipped bool) (int, uint64, []util.Cell) {
+	world.step(next, p.Rule, halo)
+	var cells []util.Cell
+	if flipped {
+		cells = next.changedCells(world, p.StartY)
 	}
-	return alive
+	return next.count(), next.changedHash(world, p.StartY), cells
 }
 
-func calculateAliveNeighbours(id int, h int, w int, world [][]byte, x int, y int, upperLine []byte, lowerLine []byte) int {
-	ans := 0
-	up := y + 1
-	down := y - 1
-
-	right := (x + 1) % w
-	left := (x - 1) % w
-	if left < 0 {
-		left = left + w
-	}
-
-	neighbours := make([][]byte, 3)
-	neighbours[1] = []byte{world[y][left], world[y][x], world[y][right]}
-	if y == 0 {
-		neighbours[2] = []byte{upperLine[left], upperLine[x], upperLine[right]}
-	} else {
-		neighbours[2] = []byte{world[down][left], world[down][x], world[down][right]}
-	}
-	if y == h-1 {
-		neighbours[0] = []byte{lowerLine[left], lowerLine[x], lowerLine[right]}
-	} else {
-		neighbours[0] = []byte{world[up][left], world[up][x], world[up][right]}
-	}
-
-	up = 0
-	down = 2
-	left = 0
-	right = 2
-	middle := 1
-
-	if neighbours[up][middle] == 255 {
-		ans++
-	}
-	if neighbours[down][middle] == 255 {
-		ans++
-	}
-	if neighbours[middle][left] == 255 {
-		ans++
-	}
-	if neighbours[middle][right] == 255 {
-		ans++
-	}
-	if neighbours[down][right] == 255 {
-		ans++
-	}
-	if neighbours[up][right] == 255 {
-		ans++
-	}
-	if neighbours[up][left] == 255 {
-		ans++
-	}
-	if neighbours[down][left] == 255 {
-		ans++
-	}
-	return ans
+func calculateAliveCells(p workerParams, world BitBoard, workerID int) []util.Cell {
+	return setCells(world.rows, p.StartY)
 }
@@ -0,0 +1,72 @@
+package gol
+
+import (
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestLocalWorkerPanic gives a local worker a strip with a row longer than the board is
+// wide, so that packing it panics, and checks that the worker is reported as failed
+// rather than taking the engine down with it.
+func TestLocalWorkerPanic(t *testing.T) {
+	world := [][]byte{make([]byte, 200)}
+	world[0][150] = 255
+	events := make(chan Event, 1)
+	c := workerChannels{events: events, killChan: make(chan bool)}
+	localWorker(world, workerParams{ImageWidth: 8, ImageHeight: 1, Turns: 1}, c, 2)
+	select {
+	case event := <-events:
+		failed, ok := event.(WorkerFailed)
+		if !ok || failed.WorkerID != 2 || failed.Remote {
+			t.Errorf("worker sent %#v, expected a local WorkerFailed from worker 2", event)
+		}
+	default:
+		t.Error("worker did not report its failure")
+	}
+}
+
+// TestCalculateStripReport runs a blinker on a worker node's strip, checking that the report
+// holds the number of alive cells and the change to the board's hash rather than the cells,
+// and that the flipped cells are only sent when they are asked for.
+func TestCalculateStripReport(t *testing.T) {
+	p := Params{ImageWidth: 8, ImageHeight: 8, Threads: 1, Rule: Conway}
+	blinker := []util.Cell{{X: 2, Y: 3}, {X: 3, Y: 3}, {X: 4, Y: 3}}
+	world := make([][]byte, p.ImageHeight)
+	for y := range world {
+		world[y] = make([]byte, p.ImageWidth)
+	}
+	for _, cell := range blinker {
+		world[cell.Y][cell.X] = 255
+	}
+	board := PackBoard(world, p.ImageWidth)
+	next := NewBitBoard(p.ImageWidth, p.ImageHeight)
+	hash := boardHash(blinker)
+
+	for turn, flipped := range []bool{false, true} {
+		halo := buildHalos(p, []filler{stripEdges(board, 0)})[0]
+		request := HaloRequest{
+			LowerLine:   halo.lowerLine,
+			UpperLine:   halo.upperLine,
+			LeftColumn:  halo.leftColumn,
+			RightColumn: halo.rightColumn,
+			Flipped:     flipped,
+		}
+		report := CalculateStrip(p, board, next, 0, request)
+		board, next = next, board
+		alive := StripAlive(board, 0)
+		hash ^= report.Hash
+		if report.Count != 3 || report.Count != len(alive) {
+			t.Errorf("turn %v: count is %v, expected %v", turn, report.Count, len(alive))
+		}
+		if hash != boardHash(alive) {
+			t.Errorf("turn %v: hash does not match the board", turn)
+		}
+		if !flipped && report.Flipped != nil {
+			t.Errorf("turn %v: sent flipped cells %v that were not asked for", turn, report.Flipped)
+		}
+		if flipped && len(report.Flipped) != 4 {
+			t.Errorf("turn %v: flipped %v, expected 4 cells", turn, report.Flipped)
+		}
+	}
+}
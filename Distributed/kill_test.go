@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+//Waits for a server process to exit by itself, failing the test if it does not
+func assertExits(t *testing.T, name string, cmd *exec.Cmd) {
+	exited := make(chan error)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+	select {
+	case err := <-exited:
+		if err != nil {
+			t.Errorf("%v exited with %v", name, err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Errorf("%v did not exit within 10 seconds", name)
+	}
+}
+
+// TestKill presses k during a 512x512 run on two worker nodes, then checks that the final
+// board is saved and that the controller, broker and workers all stop.
+func TestKill(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gol")
+	util.Check(err)
+	defer os.RemoveAll(dir)
+
+	workers := []*exec.Cmd{
+		startServer(t, dir, "worker", "-port", "8061"),
+		startServer(t, dir, "worker", "-port", "8062"),
+	}
+	engine := startServer(t, dir, "engine", "-port", "8042", "-workers", "127.0.0.1:8061,127.0.0.1:8062")
+	defer stopServer(engine)
+	for _, w := range workers {
+		defer stopServer(w)
+	}
+
+	p := gol.ClientParams{
+		Turns:       100000000,
+		Threads:     4,
+		ImageWidth:  512,
+		ImageHeight: 512,
+		BrokerAddr:  "127.0.0.1:8042",
+		OutputDir:   dir,
+	}
+	events := make(chan gol.Event)
+	keyPresses := make(chan rune, 10)
+	gol.Run(p, events, keyPresses)
+
+	states := []gol.StateChange{}
+	for event := range events {
+		switch e := event.(type) {
+		case gol.AliveCellsCount:
+			if len(states) == 0 && len(keyPresses) == 0 {
+				keyPresses <- 'k'
+			}
+		case gol.StateChange:
+			states = append(states, e)
+		}
+	}
+	if len(states) != 1 || states[0].NewState != gol.Quitting {
+		t.Fatalf("expected a single Quitting state change, got %v", states)
+	}
+
+	assertExits(t, "engine", engine)
+	for i, w := range workers {
+		assertExits(t, fmt.Sprintf("worker %v", i), w)
+	}
+
+	final := states[0]
+	cellsFromImage := util.ReadAliveCells(
+		filepath.Join(dir, fmt.Sprintf("%vx%vx%v.pgm", p.ImageWidth, p.ImageHeight, final.CompletedTurns)),
+		p.ImageWidth,
+		p.ImageHeight,
+	)
+	assertEqualBoard(t, cellsFromImage, final.Alive, gol.ClientToEngineParams(p))
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestLiveView draws a 64x64 game with two worker nodes from the CellFlipped events of the
+// live viewer, and checks the number of alive cells on every TurnComplete against the CSV.
+// The first frames are drawn slowly so that the engine has to skip frames and resync.
+func TestLiveView(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gol")
+	util.Check(err)
+	defer os.RemoveAll(dir)
+
+	workers := []*exec.Cmd{
+		startServer(t, dir, "worker", "-port", "8081"),
+		startServer(t, dir, "worker", "-port", "8082"),
+	}
+	engine := startServer(t, dir, "engine", "-port", "8046", "-workers", "127.0.0.1:8081,127.0.0.1:8082")
+	defer stopServer(engine)
+	for _, w := range workers {
+		defer stopServer(w)
+	}
+
+	p := gol.ClientParams{
+		Turns:       5000,
+		Threads:     4,
+		ImageWidth:  64,
+		ImageHeight: 64,
+		BrokerAddr:  "127.0.0.1:8046",
+		LiveView:    true,
+		OutputDir:   dir,
+	}
+	expected := readAliveCounts(p.ImageWidth, p.ImageHeight)
+	events := make(chan gol.Event)
+	gol.Run(p, events, nil)
+
+	board := map[util.Cell]bool{}
+	frames := 0
+	lastTurn := -1
+	for event := range events {
+		switch e := event.(type) {
+		case gol.CellFlipped:
+			board[e.Cell] = !board[e.Cell]
+		case gol.TurnComplete:
+			if e.CompletedTurns <= lastTurn {
+				t.Fatalf("frame for turn %v drawn after turn %v", e.CompletedTurns, lastTurn)
+			}
+			lastTurn = e.CompletedTurns
+			frames++
+			if frames < 20 {
+				time.Sleep(10 * time.Millisecond)
+			}
+			count, ok := expected[e.CompletedTurns]
+			if !ok {
+				continue
+			}
+			alive := 0
+			for _, isAlive := range board {
+				if isAlive {
+					alive++
+				}
+			}
+			if alive != count {
+				t.Fatalf("drawn board has %v alive cells on turn %v, expected %v", alive, e.CompletedTurns, count)
+			}
+		}
+	}
+	if frames == 0 {
+		t.Fatal("no frames were drawn")
+	}
+}
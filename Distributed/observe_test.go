@@ -0,0 +1,103 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+//Reads events until one of the wanted type arrives, failing the test if events is closed first
+func waitForEvent(t *testing.T, name string, events chan gol.Event, want func(gol.Event) bool) gol.Event {
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				t.Fatalf("%v: events closed while waiting", name)
+			}
+			if want(event) {
+				return event
+			}
+		case <-timeout:
+			t.Fatalf("%v: timed out waiting for an event", name)
+		}
+	}
+}
+
+func isAliveCellsCount(event gol.Event) bool {
+	_, ok := event.(gol.AliveCellsCount)
+	return ok
+}
+
+func isStateChange(event gol.Event) bool {
+	_, ok := event.(gol.StateChange)
+	return ok
+}
+
+// TestObservers attaches an observer to a running 64x64 game. The observer should receive
+// AliveCellsCount events and the controller's state changes, but not be able to press keys.
+// When the controller presses k, the observer should be given the final board.
+func TestObservers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gol")
+	util.Check(err)
+	defer os.RemoveAll(dir)
+
+	engine := startServer(t, dir, "engine", "-port", "8048")
+	defer stopServer(engine)
+
+	p := gol.ClientParams{
+		Turns:       100000000,
+		Threads:     4,
+		ImageWidth:  64,
+		ImageHeight: 64,
+		BrokerAddr:  "127.0.0.1:8048",
+		OutputDir:   dir,
+	}
+	events := make(chan gol.Event, 1000)
+	keyPresses := make(chan rune, 10)
+	gol.Run(p, events, keyPresses)
+	waitForEvent(t, "controller", events, isAliveCellsCount)
+
+	o := p
+	o.Observe = true
+	observerEvents := make(chan gol.Event, 1000)
+	observerKeyPresses := make(chan rune, 10)
+	gol.Run(o, observerEvents, observerKeyPresses)
+	waitForEvent(t, "observer", observerEvents, isAliveCellsCount)
+
+	//Rejected, so the controller's key press below must pause the game
+	observerKeyPresses <- 'p'
+	time.Sleep(500 * time.Millisecond)
+	keyPresses <- 'p'
+	paused := waitForEvent(t, "controller", events, isStateChange).(gol.StateChange)
+	if paused.NewState != gol.Paused {
+		t.Fatalf("controller: expected the game to be paused, got %v", paused.NewState)
+	}
+	observed := waitForEvent(t, "observer", observerEvents, isStateChange).(gol.StateChange)
+	if observed.NewState != gol.Paused {
+		t.Fatalf("observer: expected the game to be paused, got %v", observed.NewState)
+	}
+
+	keyPresses <- 'k'
+	final := waitForEvent(t, "controller", events, isStateChange).(gol.StateChange)
+	if final.NewState != gol.Quitting {
+		t.Fatalf("controller: expected the game to quit, got %v", final.NewState)
+	}
+	observed = waitForEvent(t, "observer", observerEvents, isStateChange).(gol.StateChange)
+	if observed.NewState != gol.Quitting {
+		t.Fatalf("observer: expected the game to quit, got %v", observed.NewState)
+	}
+	finalTurn := waitForEvent(t, "observer", observerEvents, func(event gol.Event) bool {
+		_, ok := event.(gol.FinalTurnComplete)
+		return ok
+	}).(gol.FinalTurnComplete)
+	if finalTurn.CompletedTurns != final.CompletedTurns {
+		t.Errorf("observer: final board is from turn %v, expected %v", finalTurn.CompletedTurns, final.CompletedTurns)
+	}
+	assertEqualBoard(t, finalTurn.Alive, final.Alive, gol.ClientToEngineParams(p))
+	assertExits(t, "engine", engine)
+}
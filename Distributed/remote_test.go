@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+//startServer builds one of the server binaries and runs it with the given flags.
+//The server is stopped by stopServer.
+func startServer(t *testing.T, dir string, pkg string, args ...string) *exec.Cmd {
+	binary := filepath.Join(dir, pkg)
+	if _, err := os.Stat(binary); os.IsNotExist(err) {
+		build := exec.Command("go", "build", "-o", binary, "./"+pkg)
+		build.Stdout = os.Stdout
+		build.Stderr = os.Stderr
+		util.Check(build.Run())
+	}
+	cmd := exec.Command(binary, args...)
+	util.Check(cmd.Start())
+	//Give the server time to start listening
+	time.Sleep(500 * time.Millisecond)
+	return cmd
+}
+
+func stopServer(cmd *exec.Cmd) {
+	if cmd.ProcessState == nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}
+}
+
+// TestRemoteWorkers tests 16x16, 64x64 and 512x512 images on 0, 1 and 100 turns with the world
+// split between three worker nodes on localhost.
+func TestRemoteWorkers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gol")
+	util.Check(err)
+	defer os.RemoveAll(dir)
+
+	workers := []*exec.Cmd{}
+	for _, port := range []string{"8051", "8052", "8053"} {
+		workers = append(workers, startServer(t, dir, "worker", "-port", port))
+	}
+	engine := startServer(t, dir, "engine", "-port", "8041", "-workers", "127.0.0.1:8051,127.0.0.1:8052,127.0.0.1:8053")
+	defer stopServer(engine)
+	for _, w := range workers {
+		defer stopServer(w)
+	}
+
+	tests := []gol.ClientParams{
+		{ImageWidth: 16, ImageHeight: 16},
+		{ImageWidth: 64, ImageHeight: 64},
+		{ImageWidth: 512, ImageHeight: 512},
+	}
+	for _, p := range tests {
+		for _, turns := range []int{0, 1, 100} {
+			p.Turns = turns
+			expectedAlive := util.ReadAliveCells(
+				"check/images/"+fmt.Sprintf("%vx%vx%v.pgm", p.ImageWidth, p.ImageHeight, turns),
+				p.ImageWidth,
+				p.ImageHeight,
+			)
+			for _, threads := range []int{1, 3, 8} {
+				p.Threads = threads
+				p.BrokerAddr = "127.0.0.1:8041"
+				p.OutputDir = dir
+				testName := fmt.Sprintf("%dx%dx%d-%d", p.ImageWidth, p.ImageHeight, p.Turns, p.Threads)
+				t.Run(testName, func(t *testing.T) {
+					events := make(chan gol.Event)
+					gol.Run(p, events, nil)
+					var cells []util.Cell
+					for event := range events {
+						switch e := event.(type) {
+						case gol.FinalTurnComplete:
+							cells = e.Alive
+						}
+					}
+					assertEqualBoard(t, cells, expectedAlive, gol.ClientToEngineParams(p))
+				})
+			}
+		}
+	}
+}
@@ -1,44 +1,98 @@
 package sdl
 
 import (
+	"math"
+
 	"github.com/veandco/go-sdl2/sdl"
 	"uk.ac.bris.cs/gameoflife/util"
 )
 
+//Sides of the window in pixels are kept between these, whatever the size of the board
+const (
+	minWindowSize = 512
+	maxWindowSize = 1024
+)
+
+//Most pixels a cell can be zoomed in to, as a power of 2
+const maxZoom = 6
+
+//Fewest pixels the mouse is moved while the button is held down for a drag instead of a click
+const minDrag = 4
+
+//Window shows a part of the board, zoomed in or out by a power of 2. Cells of a board
+//that is zoomed out are shown as the mean gray level of each square of cells under a pixel.
 type Window struct {
+	//Width and Height of the board in cells
 	Width, Height int32
 	window        *sdl.Window
 	renderer      *sdl.Renderer
 	texture       *sdl.Texture
 	pixels        []byte
+	//Gray level of each cell of the board
+	cells []byte
+	//Sums of the gray levels of the squares of 2^k by 2^k cells, for each zoom level -k
+	sums [][]uint32
+	//Width and height of the window in pixels
+	windowWidth, windowHeight int
+	//Pixels per cell as a power of 2, which is negative when zoomed out
+	zoom int
+	//Cell at the top left corner of the window, which may be off the board
+	viewX, viewY float64
+	dragging     bool
+	//Pixels the mouse has moved since the button was held down
+	dragged int32
 }
 
 func filterEvent(e sdl.Event, userdata interface{}) bool {
-	return e.GetType() == sdl.KEYDOWN || e.GetType() == sdl.QUIT
+	switch e.GetType() {
+	case sdl.KEYDOWN, sdl.QUIT, sdl.MOUSEBUTTONDOWN, sdl.MOUSEBUTTONUP, sdl.MOUSEMOTION, sdl.MOUSEWHEEL:
+		return true
+	}
+	return false
 }
 
+//NewWindow opens a window for a board of the given size. Boards too big or too small for
+//the screen are zoomed out or in by a power of 2, and can then be zoomed and panned around.
 func NewWindow(width, height int32) *Window {
+	w := &Window{
+		Width:  width,
+		Height: height,
+		cells:  make([]byte, width*height),
+	}
+	//Zoom out until the board fits the largest window, keeping the sums for each zoom level
+	side := int(width)
+	if height > width {
+		side = int(height)
+	}
+	for k := 1; side>>uint(k-1) > maxWindowSize; k++ {
+		w.sums = append(w.sums, make([]uint32, w.levelWidth(k)*w.levelHeight(k)))
+	}
+	//Then zoom in while the window is small
+	zoom := -len(w.sums)
+	for zoom < maxZoom && w.zoomed(side, zoom) < minWindowSize && w.zoomed(side, zoom+1) <= maxWindowSize {
+		zoom++
+	}
+	w.windowWidth, w.windowHeight = w.zoomed(int(width), zoom), w.zoomed(int(height), zoom)
+
 	err := sdl.Init(sdl.INIT_EVERYTHING)
 	util.Check(err)
-	window, err := sdl.CreateWindow("GOL GUI", sdl.WINDOWPOS_CENTERED, sdl.WINDOWPOS_CENTERED, width, height, sdl.WINDOW_SHOWN)
+	window, err := sdl.CreateWindow("GOL GUI", sdl.WINDOWPOS_CENTERED, sdl.WINDOWPOS_CENTERED, int32(w.windowWidth), int32(w.windowHeight), sdl.WINDOW_SHOWN)
 	util.Check(err)
 	renderer, err := sdl.CreateRenderer(window, -1, sdl.WINDOW_SHOWN)
 	util.Check(err)
 	sdl.SetHint(sdl.HINT_RENDER_SCALE_QUALITY, "linear")
-	err = renderer.SetLogicalSize(width, height)
+	err = renderer.SetLogicalSize(int32(w.windowWidth), int32(w.windowHeight))
 	util.Check(err)
-	texture, err := renderer.CreateTexture(sdl.PIXELFORMAT_ARGB8888, sdl.TEXTUREACCESS_STATIC, width, height)
+	texture, err := renderer.CreateTexture(sdl.PIXELFORMAT_ARGB8888, sdl.TEXTUREACCESS_STATIC, int32(w.windowWidth), int32(w.windowHeight))
 	util.Check(err)
 
 	sdl.SetEventFilterFunc(filterEvent, nil)
-	return &Window{
-		width,
-		height,
-		window,
-		renderer,
-		texture,
-		make([]byte, width*height*4),
-	}
+	w.window = window
+	w.renderer = renderer
+	w.texture = texture
+	w.pixels = make([]byte, w.windowWidth*w.windowHeight*4)
+	w.fitBoard()
+	return w
 }
 
 func (w *Window) Destroy() {
@@ -51,8 +105,181 @@ func (w *Window) Destroy() {
 	sdl.Quit()
 }
 
+//Returns a length of cells in pixels at a zoom level
+func (w *Window) zoomed(cells int, zoom int) int {
+	if zoom >= 0 {
+		return cells << uint(zoom)
+	}
+	return (cells + 1<<uint(-zoom) - 1) >> uint(-zoom)
+}
+
+//Returns the number of squares across the board at zoom level -k
+func (w *Window) levelWidth(k int) int {
+	return w.zoomed(int(w.Width), -k)
+}
+
+func (w *Window) levelHeight(k int) int {
+	return w.zoomed(int(w.Height), -k)
+}
+
+//Zooms and centres the board to fit the window
+func (w *Window) fitBoard() {
+	w.zoom = -len(w.sums)
+	for w.zoom < maxZoom && w.zoomed(int(w.Width), w.zoom+1) <= w.windowWidth && w.zoomed(int(w.Height), w.zoom+1) <= w.windowHeight {
+		w.zoom++
+	}
+	w.viewX, w.viewY = 0, 0
+	w.clampView()
+}
+
+//Returns the number of pixels across each cell
+func (w *Window) scale() float64 {
+	return math.Ldexp(1, w.zoom)
+}
+
+//Keeps the board in view. A board smaller than the window is centred in it.
+func (w *Window) clampView() {
+	clamp := func(view float64, cells int, pixels int) float64 {
+		visible := float64(pixels) / w.scale()
+		if visible >= float64(cells) {
+			return (float64(cells) - visible) / 2
+		}
+		return math.Max(0, math.Min(view, float64(cells)-visible))
+	}
+	w.viewX = clamp(w.viewX, int(w.Width), w.windowWidth)
+	w.viewY = clamp(w.viewY, int(w.Height), w.windowHeight)
+}
+
+//Zooms in or out by a number of powers of 2, keeping the cell under a pixel of the window in place
+func (w *Window) zoomAt(steps int, x, y int32) {
+	zoom := w.zoom + steps
+	if zoom < -len(w.sums) {
+		zoom = -len(w.sums)
+	}
+	if zoom > maxZoom {
+		zoom = maxZoom
+	}
+	cellX, cellY := w.viewX+float64(x)/w.scale(), w.viewY+float64(y)/w.scale()
+	w.zoom = zoom
+	w.viewX, w.viewY = cellX-float64(x)/w.scale(), cellY-float64(y)/w.scale()
+	w.clampView()
+}
+
+//Moves the view by a number of pixels of the window
+func (w *Window) pan(dx, dy int32) {
+	w.viewX += float64(dx) / w.scale()
+	w.viewY += float64(dy) / w.scale()
+	w.clampView()
+}
+
+//HandleEvent zooms and pans the view for the arrow keys, + and -, dragging and the mouse wheel,
+//and fits the board to the window for f. It returns false for any other event, including
+//the release of the left button at the end of a click.
+func (w *Window) HandleEvent(event sdl.Event) bool {
+	switch e := event.(type) {
+	case *sdl.KeyboardEvent:
+		step := int32(w.windowWidth / 8)
+		switch e.Keysym.Sym {
+		case sdl.K_LEFT:
+			w.pan(-step, 0)
+		case sdl.K_RIGHT:
+			w.pan(step, 0)
+		case sdl.K_UP:
+			w.pan(0, -step)
+		case sdl.K_DOWN:
+			w.pan(0, step)
+		case sdl.K_EQUALS, sdl.K_PLUS, sdl.K_KP_PLUS:
+			w.zoomAt(1, int32(w.windowWidth/2), int32(w.windowHeight/2))
+		case sdl.K_MINUS, sdl.K_KP_MINUS:
+			w.zoomAt(-1, int32(w.windowWidth/2), int32(w.windowHeight/2))
+		case sdl.K_f:
+			w.fitBoard()
+		default:
+			return false
+		}
+	case *sdl.MouseWheelEvent:
+		x, y, _ := sdl.GetMouseState()
+		w.zoomAt(int(e.Y), x, y)
+	case *sdl.MouseButtonEvent:
+		if e.Button != sdl.BUTTON_LEFT {
+			return false
+		}
+		w.dragging = e.Type == sdl.MOUSEBUTTONDOWN
+		if w.dragging {
+			w.dragged = 0
+		} else if w.dragged < minDrag {
+			return false
+		}
+	case *sdl.MouseMotionEvent:
+		if !w.dragging {
+			return true
+		}
+		w.dragged += abs(e.XRel) + abs(e.YRel)
+		w.pan(-e.XRel, -e.YRel)
+	default:
+		return false
+	}
+	w.RenderFrame()
+	return true
+}
+
+func abs(x int32) int32 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+//CellAt returns the cell under a pixel of the window, or false if the pixel is off the board.
+//When zoomed out, it is the top left cell of the square of cells under the pixel.
+func (w *Window) CellAt(x, y int32) (int, int, bool) {
+	k := 0
+	if w.zoom < 0 {
+		k = -w.zoom
+	}
+	column := w.square(w.viewX, int(x), k, w.levelWidth(k))
+	row := w.square(w.viewY, int(y), k, w.levelHeight(k))
+	if column < 0 || row < 0 {
+		return 0, 0, false
+	}
+	return column << uint(k), row << uint(k), true
+}
+
+//RenderFrame draws the part of the board in view. Pixels off the board are dark blue.
 func (w *Window) RenderFrame() {
-	err := w.texture.Update(nil, w.pixels, int(w.Width*4))
+	k := 0
+	if w.zoom < 0 {
+		k = -w.zoom
+	}
+	levelWidth, levelHeight := w.levelWidth(k), w.levelHeight(k)
+	//Square of cells under each column and row of pixels, or -1 off the board
+	columns := make([]int, w.windowWidth)
+	for x := range columns {
+		columns[x] = w.square(w.viewX, x, k, levelWidth)
+	}
+	rows := make([]int, w.windowHeight)
+	for y := range rows {
+		rows[y] = w.square(w.viewY, y, k, levelHeight)
+	}
+
+	for y, row := range rows {
+		for x, column := range columns {
+			i := 4 * (y*w.windowWidth + x)
+			if row < 0 || column < 0 {
+				w.pixels[i+0], w.pixels[i+1], w.pixels[i+2], w.pixels[i+3] = 0x40, 0x20, 0x20, 0xFF
+				continue
+			}
+			var level byte
+			if k == 0 {
+				level = w.cells[row*levelWidth+column]
+			} else {
+				level = byte(w.sums[k-1][row*levelWidth+column] >> uint(2*k))
+			}
+			w.pixels[i+0], w.pixels[i+1], w.pixels[i+2], w.pixels[i+3] = level, level, level, level
+		}
+	}
+
+	err := w.texture.Update(nil, w.pixels, w.windowWidth*4)
 	util.Check(err)
 	err = w.renderer.Clear()
 	util.Check(err)
@@ -61,28 +288,50 @@ func (w *Window) RenderFrame() {
 	w.renderer.Present()
 }
 
+//Returns the square of 2^k cells under a pixel of the window, or -1 if it is off the board
+func (w *Window) square(view float64, pixel int, k int, squares int) int {
+	var square int
+	if k == 0 {
+		square = int(math.Floor(view + float64(pixel)/w.scale()))
+	} else {
+		square = int(math.Floor(view))>>uint(k) + pixel
+	}
+	if square < 0 || square >= squares {
+		return -1
+	}
+	return square
+}
+
 func (w *Window) PollEvent() sdl.Event {
 	return sdl.PollEvent()
 }
 
+//Sets the gray level of a cell, keeping the sums of the squares it is in up to date
+func (w *Window) setCell(x, y int, level uint8) {
+	i := y*int(w.Width) + x
+	difference := uint32(level) - uint32(w.cells[i])
+	w.cells[i] = level
+	for k := range w.sums {
+		shift := uint(k + 1)
+		w.sums[k][(y>>shift)*w.levelWidth(k+1)+(x>>shift)] += difference
+	}
+}
+
 func (w *Window) SetPixel(x, y int) {
-	width := int(w.Width)
-	w.pixels[4*(y*width+x)+0] = 0xFF
-	w.pixels[4*(y*width+x)+1] = 0xFF
-	w.pixels[4*(y*width+x)+2] = 0xFF
-	w.pixels[4*(y*width+x)+3] = 0xFF
+	w.setCell(x, y, 0xFF)
 }
 
 func (w *Window) FlipPixel(x, y int) {
-	width := int(w.Width)
-	w.pixels[4*(y*width+x)+0] = ^w.pixels[4*(y*width+x)+0]
-	w.pixels[4*(y*width+x)+1] = ^w.pixels[4*(y*width+x)+1]
-	w.pixels[4*(y*width+x)+2] = ^w.pixels[4*(y*width+x)+2]
-	w.pixels[4*(y*width+x)+3] = ^w.pixels[4*(y*width+x)+3]
+	w.setCell(x, y, ^w.cells[y*int(w.Width)+x])
 }
 
 func (w *Window) ClearPixels() {
-	for i := range w.pixels {
-		w.pixels[i] = 0
+	for i := range w.cells {
+		w.cells[i] = 0
+	}
+	for _, sums := range w.sums {
+		for i := range sums {
+			sums[i] = 0
+		}
 	}
 }
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestSessions runs a 512x512 and a 64x64 game side by side on one engine, and checks
+// that starting the second game does not stop the first.
+func TestSessions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gol")
+	util.Check(err)
+	defer os.RemoveAll(dir)
+
+	engine := startServer(t, dir, "engine", "-port", "8047")
+	defer stopServer(engine)
+
+	tests := []gol.ClientParams{
+		{Turns: 100, Threads: 4, ImageWidth: 512, ImageHeight: 512, BrokerAddr: "127.0.0.1:8047", OutputDir: dir},
+		{Turns: 100, Threads: 2, ImageWidth: 64, ImageHeight: 64, BrokerAddr: "127.0.0.1:8047", OutputDir: dir},
+	}
+	results := make([][]util.Cell, len(tests))
+	wg := sync.WaitGroup{}
+	for i, p := range tests {
+		events := make(chan gol.Event)
+		gol.Run(p, events, nil)
+		wg.Add(1)
+		go func(i int, events chan gol.Event) {
+			defer wg.Done()
+			for event := range events {
+				switch e := event.(type) {
+				case gol.FinalTurnComplete:
+					results[i] = e.Alive
+				}
+			}
+		}(i, events)
+	}
+	wg.Wait()
+
+	for i, p := range tests {
+		expectedAlive := util.ReadAliveCells(
+			"check/images/"+fmt.Sprintf("%vx%vx%v.pgm", p.ImageWidth, p.ImageHeight, p.Turns),
+			p.ImageWidth,
+			p.ImageHeight,
+		)
+		assertEqualBoard(t, results[i], expectedAlive, gol.ClientToEngineParams(p))
+	}
+}
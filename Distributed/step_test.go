@@ -0,0 +1,125 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+//Returns the alive cells of a glider on a 16x16 torus after a number of turns
+func gliderAfter(turns int) []util.Cell {
+	alive := map[util.Cell]bool{{X: 1, Y: 0}: true, {X: 2, Y: 1}: true, {X: 0, Y: 2}: true, {X: 1, Y: 2}: true, {X: 2, Y: 2}: true}
+	//The glider moves one cell diagonally every 4 turns, so comes back after 64
+	for turn := 0; turn < turns%64; turn++ {
+		next := map[util.Cell]bool{}
+		for y := 0; y < 16; y++ {
+			for x := 0; x < 16; x++ {
+				neighbours := 0
+				for dy := -1; dy <= 1; dy++ {
+					for dx := -1; dx <= 1; dx++ {
+						if (dx != 0 || dy != 0) && alive[util.Cell{X: (x + dx + 16) % 16, Y: (y + dy + 16) % 16}] {
+							neighbours++
+						}
+					}
+				}
+				cell := util.Cell{X: x, Y: y}
+				if neighbours == 3 || neighbours == 2 && alive[cell] {
+					next[cell] = true
+				}
+			}
+		}
+		alive = next
+	}
+	cells := []util.Cell{}
+	for cell := range alive {
+		cells = append(cells, cell)
+	}
+	return cells
+}
+
+// TestStepBack pauses a glider on a worker node, then seeks, steps forward and steps back
+// through its turns, checking the board the engine reports after each move. The strips on
+// the worker node must be replaced by the board of the turn moved to.
+func TestStepBack(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gol")
+	util.Check(err)
+	defer os.RemoveAll(dir)
+	input := filepath.Join(dir, "glider.cells")
+	util.Check(ioutil.WriteFile(input, []byte(".O.\n..O\nOOO\n"), 0644))
+
+	workers := []*exec.Cmd{startServer(t, dir, "worker", "-port", "8092")}
+	engine := startServer(t, dir, "engine", "-port", "8050", "-workers", "127.0.0.1:8092")
+	defer stopServer(engine)
+	for _, w := range workers {
+		defer stopServer(w)
+	}
+
+	p := gol.ClientParams{
+		Turns:       100,
+		Threads:     4,
+		ImageWidth:  16,
+		ImageHeight: 16,
+		BrokerAddr:  "127.0.0.1:8050",
+		Input:       input,
+		OutputDir:   dir,
+	}
+	events := make(chan gol.Event)
+	keyPresses := make(chan rune, 10)
+	seeks := make(chan int, 10)
+	keyPresses <- 'p'
+	gol.RunInteractive(p, events, keyPresses, nil, seeks)
+
+	moves := []struct {
+		name string
+		move func()
+		turn int
+	}{
+		{"seek 0", func() { seeks <- 0 }, 0},
+		{"step", func() { keyPresses <- 'n' }, 1},
+		{"step", func() { keyPresses <- 'n' }, 2},
+		{"step", func() { keyPresses <- 'n' }, 3},
+		{"step back", func() { keyPresses <- 'b' }, 2},
+		{"seek 10", func() { seeks <- 10 }, 10},
+		{"seek 7", func() { seeks <- 7 }, 7},
+	}
+	moved := 0
+	var final gol.FinalTurnComplete
+	for event := range events {
+		switch e := event.(type) {
+		case gol.StateChange:
+			if e.NewState != gol.Paused {
+				break
+			}
+			name := "pause"
+			if moved > 0 {
+				name = moves[moved-1].name
+				if e.CompletedTurns != moves[moved-1].turn {
+					t.Errorf("%v reached turn %v, expected %v", name, e.CompletedTurns, moves[moved-1].turn)
+				}
+			}
+			if expected := gliderAfter(e.CompletedTurns); !sameCells(e.Alive, expected) {
+				t.Errorf("after %v, board on turn %v is %v, expected %v", name, e.CompletedTurns, e.Alive, expected)
+			}
+			if moved == len(moves) {
+				keyPresses <- 'p'
+			} else {
+				moves[moved].move()
+			}
+			moved++
+		case gol.FinalTurnComplete:
+			final = e
+		}
+	}
+
+	if moved <= len(moves) {
+		t.Fatalf("the game finished after %v of %v moves", moved, len(moves))
+	}
+	if expected := gliderAfter(p.Turns); !sameCells(final.Alive, expected) {
+		t.Errorf("final board is %v, expected %v", final.Alive, expected)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestTelemetry runs the 512x512 board on two worker nodes, reporting every 200
+// milliseconds, and checks that the engine times the strip of every worker.
+func TestTelemetry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gol")
+	util.Check(err)
+	defer os.RemoveAll(dir)
+
+	workers := []*exec.Cmd{
+		startServer(t, dir, "worker", "-port", "8094"),
+		startServer(t, dir, "worker", "-port", "8095"),
+	}
+	engine := startServer(t, dir, "engine", "-port", "8057", "-workers", "127.0.0.1:8094,127.0.0.1:8095")
+	defer stopServer(engine)
+	for _, w := range workers {
+		defer stopServer(w)
+	}
+
+	p := gol.ClientParams{
+		Turns:          100000000,
+		Threads:        4,
+		ImageWidth:     512,
+		ImageHeight:    512,
+		BrokerAddr:     "127.0.0.1:8057",
+		OutputDir:      dir,
+		ReportInterval: 200 * time.Millisecond,
+	}
+	events := make(chan gol.Event)
+	keyPresses := make(chan rune, 10)
+	start := time.Now()
+	gol.Run(p, events, keyPresses)
+
+	reports := []gol.Telemetry{}
+	for event := range events {
+		if e, ok := event.(gol.Telemetry); ok {
+			reports = append(reports, e)
+			if len(reports) == 3 {
+				keyPresses <- 'k'
+			}
+		}
+	}
+
+	if len(reports) < 3 {
+		t.Fatalf("%v telemetry reports, expected at least 3", len(reports))
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("3 reports took %v, expected one every %v", elapsed, p.ReportInterval)
+	}
+	for _, report := range reports[:3] {
+		if report.TurnsPerSecond <= 0 {
+			t.Errorf("reported %v turns per second", report.TurnsPerSecond)
+		}
+		if len(report.TurnTimes) != p.Threads || len(report.HaloWaits) != p.Threads {
+			t.Fatalf("reported %v strip times and %v halo waits, expected %v of each", len(report.TurnTimes), len(report.HaloWaits), p.Threads)
+		}
+		for w := range report.TurnTimes {
+			if report.TurnTimes[w] <= 0 {
+				t.Errorf("strip %v took %v", w, report.TurnTimes[w])
+			}
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package tui
+
+import (
+	"strings"
+)
+
+//Bits of a braille character for each dot of its 2x4 grid, indexed by [y][x]
+var brailleDots = [4][2]rune{
+	{0x01, 0x08},
+	{0x02, 0x10},
+	{0x04, 0x20},
+	{0x40, 0x80},
+}
+
+//Screen draws a board in the terminal with braille characters, each showing 2x4 dots.
+//When the board is too big for the terminal, each dot stands for a square of Scale by
+//Scale cells, and is lit when any of them is not dead. Braille has no shades, so the
+//dying cells of a Generations rule are lit like alive ones, as SDL draws them in gray.
+type Screen struct {
+	Width, Height int
+	Scale         int
+	levels        []uint8
+	//Number of cells under each dot that are not dead
+	dots          []int
+	columns, rows int
+}
+
+//NewScreen returns a screen for a board, scaled to fit into the given number of columns and rows of characters
+func NewScreen(width, height, columns, rows int) *Screen {
+	if columns < 1 {
+		columns = 1
+	}
+	if rows < 1 {
+		rows = 1
+	}
+	scale := 1
+	for (width+2*scale-1)/(2*scale) > columns || (height+4*scale-1)/(4*scale) > rows {
+		scale++
+	}
+	dotColumns := (width + scale - 1) / scale
+	dotRows := (height + scale - 1) / scale
+	return &Screen{
+		Width:   width,
+		Height:  height,
+		Scale:   scale,
+		levels:  make([]uint8, width*height),
+		dots:    make([]int, dotColumns*dotRows),
+		columns: (dotColumns + 1) / 2,
+		rows:    (dotRows + 3) / 4,
+	}
+}
+
+//SetCell gives a cell the gray level of a CellFlipped event: 255 if alive, 0 if dead and
+//in between if dying. Cells outside the board, which the sparse engine can send, are not drawn.
+func (s *Screen) SetCell(x, y int, level uint8) {
+	if x < 0 || y < 0 || x >= s.Width || y >= s.Height {
+		return
+	}
+	i := y*s.Width + x
+	was, is := s.levels[i] > 0, level > 0
+	s.levels[i] = level
+	if was == is {
+		return
+	}
+	dot := (y/s.Scale)*s.dotColumns() + x/s.Scale
+	if is {
+		s.dots[dot]++
+	} else {
+		s.dots[dot]--
+	}
+}
+
+func (s *Screen) dotColumns() int {
+	return (s.Width + s.Scale - 1) / s.Scale
+}
+
+//Render returns the board as lines of braille characters
+func (s *Screen) Render() []string {
+	dotColumns := s.dotColumns()
+	dotRows := len(s.dots) / dotColumns
+	lines := make([]string, s.rows)
+	var line strings.Builder
+	for row := range lines {
+		line.Reset()
+		for column := 0; column < s.columns; column++ {
+			char := rune(0x2800)
+			for dy := 0; dy < 4; dy++ {
+				for dx := 0; dx < 2; dx++ {
+					x, y := 2*column+dx, 4*row+dy
+					if x < dotColumns && y < dotRows && s.dots[y*dotColumns+x] > 0 {
+						char |= brailleDots[dy][dx]
+					}
+				}
+			}
+			line.WriteRune(char)
+		}
+		lines[row] = line.String()
+	}
+	return lines
+}
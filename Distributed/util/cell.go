@@ -1,56 +1,19 @@
 package util
 
-import (
-	"io/ioutil"
-	"strconv"
-	"strings"
-)
-
 // Cell is used as the return type for the testing framework.
 type Cell struct {
 	X, Y int
 }
 
+//ReadAliveCells reads the cells of a netpbm image that are not dead, panicking if the
+//image cannot be read or is not the given size
 func ReadAliveCells(path string, width, height int) []Cell {
 	//data, ioError := ioutil.ReadFile("check/images/" + fmt.Sprintf("%vx%vx%v.pgm", width, height, turns))
-	data, ioError := ioutil.ReadFile(path)
+	image, ioError := ReadNetpbm(path)
 	Check(ioError)
 
-	fields := strings.Fields(string(data))
-
-	if fields[0] != "P5" {
-		panic("Not a pgm file")
-	}
-
-	imageWidth, _ := strconv.Atoi(fields[1])
-	if imageWidth != width {
-		panic("Incorrect width")
-	}
-
-	imageHeight, _ := strconv.Atoi(fields[2])
-	if imageHeight != height {
-		panic("Incorrect height")
+	if image.Width != width || image.Height != height {
+		panic(SizeError{image.Width, image.Height, width, height})
 	}
-
-	maxval, _ := strconv.Atoi(fields[3])
-	if maxval != 255 {
-		panic("Incorrect maxval/bit depth")
-	}
-
-	image := []byte(fields[4])
-
-	var cells []Cell
-	for y := 0; y < height; y++ {
-		for x := 0; x < width; x++ {
-			cell := image[0]
-			if cell != 0 {
-				cells = append(cells, Cell{
-					X: x,
-					Y: y,
-				})
-			}
-			image = image[1:]
-		}
-	}
-	return cells
-}
\ No newline at end of file
+	return image.AliveCells(1)
+}
@@ -0,0 +1,250 @@
+package util
+
+import (
+	"fmt"
+	"io/ioutil"
+)
+
+//Netpbm is an image read from a P1, P2, P4 or P5 netpbm file. Its pixels are scaled to gray
+//levels from 0 to 255 whatever the file's maxval. Bitmaps are drawn with 1 for black ink,
+//which is read as an alive cell, so their pixels are 255 for 1 and 0 for 0.
+type Netpbm struct {
+	Width  int
+	Height int
+	Pixels [][]byte
+}
+
+//FormatError is returned for a file that is not a P1, P2, P4 or P5 netpbm image
+type FormatError struct {
+	Magic string
+}
+
+func (e FormatError) Error() string {
+	return fmt.Sprintf("not a P1, P2, P4 or P5 netpbm image, it starts with %q", e.Magic)
+}
+
+//HeaderError is returned when the width, height or maxval of a header is missing or invalid
+type HeaderError struct {
+	Field string
+	Value string
+}
+
+func (e HeaderError) Error() string {
+	if e.Value == "" {
+		return fmt.Sprintf("netpbm header is missing its %v", e.Field)
+	}
+	return fmt.Sprintf("netpbm header has an invalid %v %q", e.Field, e.Value)
+}
+
+//PixelError is returned when the pixels stop early or do not fit the maxval
+type PixelError struct {
+	X, Y   int
+	Reason string
+}
+
+func (e PixelError) Error() string {
+	return fmt.Sprintf("netpbm pixel (%v, %v) %v", e.X, e.Y, e.Reason)
+}
+
+//SizeError is returned when an image is not the size it was expected to be
+type SizeError struct {
+	Width, Height                 int
+	ExpectedWidth, ExpectedHeight int
+}
+
+func (e SizeError) Error() string {
+	return fmt.Sprintf("image is %vx%v, expected %vx%v", e.Width, e.Height, e.ExpectedWidth, e.ExpectedHeight)
+}
+
+//netpbmReader reads the fields of a netpbm file, skipping whitespace and # comments between them
+type netpbmReader struct {
+	data []byte
+	i    int
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r' || b == '\v' || b == '\f'
+}
+
+func (r *netpbmReader) skip() {
+	for r.i < len(r.data) {
+		switch {
+		case isSpace(r.data[r.i]):
+			r.i++
+		case r.data[r.i] == '#':
+			for r.i < len(r.data) && r.data[r.i] != '\n' && r.data[r.i] != '\r' {
+				r.i++
+			}
+		default:
+			return
+		}
+	}
+}
+
+//Returns the next field, or "" at the end of the data
+func (r *netpbmReader) field() string {
+	r.skip()
+	start := r.i
+	for r.i < len(r.data) && !isSpace(r.data[r.i]) && r.data[r.i] != '#' {
+		r.i++
+	}
+	return string(r.data[start:r.i])
+}
+
+//Returns the next field as a number from min to max, or ok false
+func (r *netpbmReader) number(min int, max int) (int, string, bool) {
+	s := r.field()
+	if s == "" || len(s) > 6 {
+		return 0, s, false
+	}
+	n := 0
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return 0, s, false
+		}
+		n = n*10 + int(c-'0')
+	}
+	return n, s, n >= min && n <= max
+}
+
+//ParseNetpbm reads a P1, P2, P4 or P5 netpbm image. Comments may appear anywhere in
+//the header, and between the pixels of the plain P1 and P2 formats.
+func ParseNetpbm(data []byte) (Netpbm, error) {
+	magic := string(data[:min(2, len(data))])
+	if magic != "P1" && magic != "P2" && magic != "P4" && magic != "P5" {
+		return Netpbm{}, FormatError{magic}
+	}
+	r := &netpbmReader{data: data, i: 2}
+	//The largest size that cannot overflow a slice, even on 32 bit machines
+	const maxSize = 1 << 15
+	width, value, ok := r.number(1, maxSize)
+	if !ok {
+		return Netpbm{}, HeaderError{"width", value}
+	}
+	height, value, ok := r.number(1, maxSize)
+	if !ok {
+		return Netpbm{}, HeaderError{"height", value}
+	}
+	maxval := 1
+	if magic == "P2" || magic == "P5" {
+		maxval, value, ok = r.number(1, 65535)
+		if !ok {
+			return Netpbm{}, HeaderError{"maxval", value}
+		}
+	}
+	//A binary raster starts after the single whitespace character that ends the header
+	if magic == "P4" || magic == "P5" {
+		if r.i >= len(data) || !isSpace(data[r.i]) {
+			return Netpbm{}, PixelError{0, 0, "is missing"}
+		}
+		r.i++
+	}
+
+	image := Netpbm{Width: width, Height: height, Pixels: make([][]byte, height)}
+	for y := range image.Pixels {
+		image.Pixels[y] = make([]byte, width)
+	}
+	switch magic {
+	case "P1":
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				//Bits of a plain bitmap need not be separated
+				r.skip()
+				if r.i >= len(data) {
+					return Netpbm{}, PixelError{x, y, "is missing"}
+				}
+				switch data[r.i] {
+				case '0':
+				case '1':
+					image.Pixels[y][x] = 255
+				default:
+					return Netpbm{}, PixelError{x, y, fmt.Sprintf("is %q, not 0 or 1", data[r.i])}
+				}
+				r.i++
+			}
+		}
+	case "P2":
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				n, value, ok := r.number(0, maxval)
+				if value == "" {
+					return Netpbm{}, PixelError{x, y, "is missing"}
+				}
+				if !ok {
+					return Netpbm{}, PixelError{x, y, fmt.Sprintf("is %q, not from 0 to %v", value, maxval)}
+				}
+				image.Pixels[y][x] = scale(n, maxval)
+			}
+		}
+	case "P4":
+		rowBytes := (width + 7) / 8
+		if len(data)-r.i < rowBytes*height {
+			return Netpbm{}, PixelError{0, (len(data) - r.i) / rowBytes, "is missing"}
+		}
+		for y := 0; y < height; y++ {
+			row := data[r.i+y*rowBytes:]
+			for x := 0; x < width; x++ {
+				if row[x/8]&(0x80>>uint(x%8)) != 0 {
+					image.Pixels[y][x] = 255
+				}
+			}
+		}
+	case "P5":
+		sampleBytes := 1
+		if maxval > 255 {
+			sampleBytes = 2
+		}
+		if len(data)-r.i < sampleBytes*width*height {
+			missing := (len(data) - r.i) / sampleBytes
+			return Netpbm{}, PixelError{missing % width, missing / width, "is missing"}
+		}
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				n := int(data[r.i])
+				if sampleBytes == 2 {
+					n = n<<8 | int(data[r.i+1])
+				}
+				if n > maxval {
+					return Netpbm{}, PixelError{x, y, fmt.Sprintf("is %v, more than the maxval %v", n, maxval)}
+				}
+				image.Pixels[y][x] = scale(n, maxval)
+				r.i += sampleBytes
+			}
+		}
+	}
+	return image, nil
+}
+
+//ReadNetpbm reads a netpbm image from a file
+func ReadNetpbm(path string) (Netpbm, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Netpbm{}, err
+	}
+	return ParseNetpbm(data)
+}
+
+//AliveCells returns the cells whose gray level is at least the threshold
+func (image Netpbm) AliveCells(threshold byte) []Cell {
+	var cells []Cell
+	for y, row := range image.Pixels {
+		for x, level := range row {
+			if level >= threshold {
+				cells = append(cells, Cell{X: x, Y: y})
+			}
+		}
+	}
+	return cells
+}
+
+//Scales a sample from 0 to maxval to a gray level from 0 to 255, rounding to the nearest
+func scale(n int, maxval int) byte {
+	return byte((n*255 + maxval/2) / maxval)
+}
+
+func min(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/rpc"
+	"sync"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+//A strip of the world held by this worker node
+type strip struct {
+	world gol.BitBoard
+	//Board that the next turn is written to, swapped with world after every turn
+	next   gol.BitBoard
+	startY int
+	params gol.Params
+}
+
+type Worker struct {
+	strips   map[gol.StripKey]*strip
+	lock     sync.Mutex
+	shutdown chan bool
+}
+
+func (w *Worker) getStrip(key gol.StripKey) (*strip, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	s, ok := w.strips[key]
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("no strip %d of job %d on this worker", key.Strip, key.Job))
+	}
+	return s, nil
+}
+
+//Receive a strip of the world from the broker
+func (w *Worker) Initialise(req gol.StripRequest, res *gol.StripReport) (err error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.strips[req.Key] = &strip{
+		world:  gol.PackBoard(req.World, req.Params.ImageWidth),
+		next:   gol.NewBitBoard(req.Params.ImageWidth, len(req.World)),
+		startY: req.StartY,
+		params: req.Params,
+	}
+	fmt.Println("Received strip", req.Key.Strip, "of", len(req.World), "lines")
+	return err
+}
+
+//Execute one turn on a strip, given the lines just outside it
+func (w *Worker) Turn(req gol.HaloRequest, res *gol.HaloReport) (err error) {
+	s, err := w.getStrip(req.Key)
+	if err != nil {
+		return err
+	}
+	*res = gol.CalculateStrip(s.params, s.world, s.next, s.startY, req)
+	s.world, s.next = s.next, s.world
+	return err
+}
+
+//Send the alive cells of a strip, which the broker asks for when it needs the board
+func (w *Worker) Alive(req gol.StripKey, res *gol.StripAliveReport) (err error) {
+	s, err := w.getStrip(req)
+	if err != nil {
+		return err
+	}
+	res.Alive = gol.StripAlive(s.world, s.startY)
+	return err
+}
+
+//Forget a strip once its game has finished
+func (w *Worker) Release(req gol.StripKey, res *gol.StripReport) (err error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	delete(w.strips, req)
+	return err
+}
+
+//Exit the worker node once the broker has disconnected
+func (w *Worker) Shutdown(req gol.StripKey, res *gol.StripReport) (err error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	select {
+	case <-w.shutdown:
+	default:
+		close(w.shutdown)
+	}
+	return err
+}
+
+// main is the function called when starting a worker node with 'go run .'
+func main() {
+	pAddr := flag.String("port", "8040", "Port to listen on")
+	flag.Parse()
+	w := &Worker{strips: make(map[gol.StripKey]*strip), shutdown: make(chan bool)}
+	rpc.Register(w)
+	listener, err := net.Listen("tcp", ":"+*pAddr)
+	util.Check(err)
+	gol.ServeRPC(listener, w.shutdown)
+	fmt.Println("Worker shut down")
+}
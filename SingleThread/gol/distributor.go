@@ -13,6 +13,8 @@ type distributorChannels struct {
 	input     <-chan uint8
 	output    chan<- uint8
 	filename  chan<- string
+	//Receives the error instead of the input when it cannot be read
+	inputError <-chan error
 }
 
 // distributor divides the work between workers and interacts with other goroutines.
@@ -48,17 +50,22 @@ func distributor(p Params, c distributorChannels) {
 		for j := 0; j < p.ImageWidth; j++ {
 			select {
 			case b := <-c.input:
-				world[i][j] = b
+				world[i][j] = p.Rule.Level(p.Rule.State(b))
+			case err := <-c.inputError:
+				c.events <- ErrorOccurred{0, err}
+				c.events <- StateChange{0, Quitting}
+				close(c.events)
+				return
 			}
 		}
 	}
 
 	//For all initially alive cells send a CellFlipped Event.
-	for i, elem := range world {
-		for j, cell := range elem {
-			if cell == 255 {
-				d := util.Cell{X: i, Y: j}
-				cellFlip := CellFlipped{CompletedTurns: 0, Cell: d}
+	for y, row := range world {
+		for x, cell := range row {
+			if cell != 0 {
+				d := util.Cell{X: x, Y: y}
+				cellFlip := CellFlipped{CompletedTurns: 0, Cell: d, Value: cell}
 				c.events <- cellFlip
 			}
 		}
@@ -93,22 +100,10 @@ func calculateNextState(p Params, world [][]byte, c distributorChannels, complet
 	for y, a := range world {
 		nA := make([]byte, w)
 		for x, b := range a {
-			nB := byte(0)
-			ln := calculateAliveNeighbours(h, w, world, x, y)
-			if b == 0 {
-				if ln == 3 {
-					nB = 255
-					sendFlippedEvent(x, y, completedTurns, c)
-				} else {
-					nB = 0
-				}
-			} else {
-				if ln < 2 || ln > 3 {
-					nB = 0
-					sendFlippedEvent(x, y, completedTurns, c)
-				} else {
-					nB = 255
-				}
+			ln := calculateAliveNeighbours(p, world, x, y)
+			nB := p.Rule.Next(b, ln)
+			if nB != b {
+				sendFlippedEvent(x, y, nB, completedTurns, c)
 			}
 			nA[x] = nB
 		}
@@ -117,9 +112,9 @@ func calculateNextState(p Params, world [][]byte, c distributorChannels, complet
 	return nworld
 }
 
-func sendFlippedEvent(x int, y int, completedTurns int, c distributorChannels) {
+func sendFlippedEvent(x int, y int, value byte, completedTurns int, c distributorChannels) {
 	cell := util.Cell{X: x, Y: y}
-	c.events <- CellFlipped{CompletedTurns: completedTurns, Cell: cell}
+	c.events <- CellFlipped{CompletedTurns: completedTurns, Cell: cell, Value: value}
 }
 
 func calculateAliveCells(p Params, world [][]byte) []util.Cell {
@@ -135,42 +130,27 @@ func calculateAliveCells(p Params, world [][]byte) []util.Cell {
 	return alive
 }
 
-func calculateAliveNeighbours(h int, w int, world [][]byte, x int, y int) int {
+//Counts the alive neighbours of a cell. Neighbours beyond the edges of the world are found through the topology,
+//which only cells on the edges need.
+func calculateAliveNeighbours(p Params, world [][]byte, x int, y int) int {
 	ans := 0
-	up := (x + 1) % h
-	down := (x - 1) % h
-	if down < 0 {
-		down = down + h
-	}
-	right := (y + 1) % w
-	left := (y - 1) % w
-	if left < 0 {
-		left = left + w
-	}
-
-	if world[left][x] == 255 {
-		ans++
-	}
-	if world[right][x] == 255 {
-		ans++
-	}
-	if world[y][down] == 255 {
-		ans++
-	}
-	if world[y][up] == 255 {
-		ans++
-	}
-	if world[right][down] == 255 {
-		ans++
-	}
-	if world[right][up] == 255 {
-		ans++
-	}
-	if world[left][up] == 255 {
-		ans++
-	}
-	if world[left][down] == 255 {
-		ans++
+	if x >= 1 && x < p.ImageWidth-1 && y >= 1 && y < p.ImageHeight-1 {
+		//Only alive cells, stored as 255, count, as dying cells are below it
+		above, row, below := world[y-1], world[y], world[y+1]
+		ans = int(above[x-1]/255) + int(above[x]/255) + int(above[x+1]/255) + int(row[x-1]/255) +
+			int(row[x+1]/255) + int(below[x-1]/255) + int(below[x]/255) + int(below[x+1]/255)
+		return ans
+	}
+	for dy := -1; dy <= 1; dy++ {
+		for dx := -1; dx <= 1; dx++ {
+			if dx == 0 && dy == 0 {
+				continue
+			}
+			nx, ny, ok := p.Topology.Wrap(x+dx, y+dy, p.ImageWidth, p.ImageHeight)
+			if ok && world[ny][nx] == 255 {
+				ans++
+			}
+		}
 	}
 	return ans
 }
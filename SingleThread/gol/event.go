@@ -28,6 +28,13 @@ type ImageOutputComplete struct { // implements Event
 	Filename       string
 }
 
+// ErrorOccurred is an Event notifying the user about an error that stops the game,
+// such as an input image that cannot be read. A Quitting StateChange follows it.
+type ErrorOccurred struct { // implements Event
+	CompletedTurns int
+	Err            error
+}
+
 // State represents a change in the state of execution.
 type State int
 
@@ -50,6 +57,9 @@ type StateChange struct { // implements Event
 type CellFlipped struct { // implements Event
 	CompletedTurns int
 	Cell           util.Cell
+	//Gray level of the cell after the change: 255 if alive, 0 if dead,
+	//and in between for the dying states of a Generations rule
+	Value uint8
 }
 
 // TurnComplete is an Event notifying the GUI about turn completion.
@@ -106,6 +116,14 @@ func (event ImageOutputComplete) GetCompletedTurns() int {
 	return event.CompletedTurns
 }
 
+func (event ErrorOccurred) String() string {
+	return fmt.Sprintf("Error: %v", event.Err)
+}
+
+func (event ErrorOccurred) GetCompletedTurns() int {
+	return event.CompletedTurns
+}
+
 func (event CellFlipped) String() string {
 	return fmt.Sprintf("")
 }
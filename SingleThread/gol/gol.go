@@ -6,6 +6,21 @@ type Params struct {
 	Threads     int
 	ImageWidth  int
 	ImageHeight int
+	Rule        Rule
+	Topology    Topology
+	//Pattern file to load, as a pgm, png, rle or cells file. Empty to load images/<W>x<H>.pgm
+	Input string
+	//Where the top left corner of the loaded pattern is placed on the board
+	OffsetX int
+	OffsetY int
+	//Format that images are saved in
+	Format Format
+	//Gray level from which pixels of a netpbm or png input are alive. 0 for DefaultThreshold
+	Threshold uint8
+	//Directory that images are saved in. Empty to save them in out
+	OutputDir string
+	//Template of the names images are saved under, see DefaultOutputName
+	OutputName string
 }
 
 // Run starts the processing of Game of Life. It should initialise channels and goroutines.
@@ -14,6 +29,7 @@ func Run(p Params, events chan<- Event, keyPresses <-chan rune) {
 	ioCommand := make(chan ioCommand)
 	ioIdle := make(chan bool)
 	input := make(chan byte)
+	inputError := make(chan error)
 	output := make(chan byte)
 	filename := make(chan string)
 
@@ -24,6 +40,7 @@ func Run(p Params, events chan<- Event, keyPresses <-chan rune) {
 		input,
 		output,
 		filename,
+		inputError,
 	}
 	go distributor(p, distributorChannels)
 
@@ -33,6 +50,8 @@ func Run(p Params, events chan<- Event, keyPresses <-chan rune) {
 		filename: filename,
 		output:   output,
 		input:    input,
+		//Sends the error instead of the input when it cannot be read
+		inputError: inputError,
 	}
 	go startIo(p, ioChannels)
 
@@ -0,0 +1,98 @@
+package gol
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+//Topology decides how the edges of the board are joined together.
+//The zero Topology is a torus, which wraps around both axes.
+type Topology uint8
+
+const (
+	//Torus wraps around both the left and right edges and the top and bottom edges
+	Torus Topology = iota
+	//Plane has no neighbours beyond its edges, as if it had a border of dead cells
+	Plane
+	//Cylinder wraps around the left and right edges only
+	Cylinder
+	//KleinBottle wraps around the left and right edges, and around the top and
+	//bottom edges with a twist, so that a cell leaving the bottom enters the top mirrored
+	KleinBottle
+	//ProjectivePlane wraps around both pairs of edges with a twist
+	ProjectivePlane
+)
+
+var topologyNames = []string{"torus", "plane", "cylinder", "klein", "projective"}
+
+//ParseTopology reads a topology from its name: torus, plane, cylinder, klein or projective
+func ParseTopology(s string) (Topology, error) {
+	for i, name := range topologyNames {
+		if strings.ToLower(strings.TrimSpace(s)) == name {
+			return Topology(i), nil
+		}
+	}
+	return Torus, errors.New(fmt.Sprintf("unknown topology %q, must be one of %v", s, strings.Join(topologyNames, ", ")))
+}
+
+func (t Topology) String() string {
+	if int(t) < len(topologyNames) {
+		return topologyNames[t]
+	}
+	return fmt.Sprintf("Topology(%d)", t)
+}
+
+//Set parses a topology given on the command line, so that a Topology can be used with flag.Var
+func (t *Topology) Set(s string) error {
+	topology, err := ParseTopology(s)
+	if err != nil {
+		return err
+	}
+	*t = topology
+	return nil
+}
+
+//Wrap maps a cell that may be just beyond the edge of a board of the given size onto
+//the board. Returns false if the topology has nothing beyond that edge. A cell beyond a
+//corner of a projective plane crosses both twisted edges, which lands on the same cell
+//whichever edge is crossed first.
+func (t Topology) Wrap(x, y, w, h int) (int, int, bool) {
+	outX := x < 0 || x >= w
+	outY := y < 0 || y >= h
+	if !outX && !outY {
+		return x, y, true
+	}
+	switch t {
+	case Plane:
+		return x, y, false
+	case Cylinder:
+		if outY {
+			return x, y, false
+		}
+		return wrap(x, w), y, true
+	case KleinBottle:
+		x = wrap(x, w)
+		if outY {
+			return w - 1 - x, wrap(y, h), true
+		}
+		return x, y, true
+	case ProjectivePlane:
+		if outX && outY {
+			return w - 1 - wrap(x, w), h - 1 - wrap(y, h), true
+		}
+		if outX {
+			return wrap(x, w), h - 1 - y, true
+		}
+		return w - 1 - x, wrap(y, h), true
+	}
+	return wrap(x, w), wrap(y, h), true
+}
+
+func wrap(i, n int) int {
+	i = i % n
+	if i < 0 {
+		i += n
+	}
+	return i
+}
@@ -0,0 +1,202 @@
+package gol
+
+import (
+	"fmt"
+	"testing"
+)
+
+//Executes one turn the slow way, looking up every neighbour through the topology
+func referenceStep(p Params, world [][]byte) [][]byte {
+	next := make([][]byte, p.ImageHeight)
+	for y := range next {
+		next[y] = make([]byte, p.ImageWidth)
+		for x := range next[y] {
+			n := 0
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					nx, ny, ok := p.Topology.Wrap(x+dx, y+dy, p.ImageWidth, p.ImageHeight)
+					if (dx != 0 || dy != 0) && ok && world[ny][nx] == 255 {
+						n++
+					}
+				}
+			}
+			next[y][x] = p.Rule.Next(world[y][x], n)
+		}
+	}
+	return next
+}
+
+//Places a glider heading down and to the right, with its top left corner at (x, y)
+func glider(p Params, x int, y int) [][]byte {
+	world := make([][]byte, p.ImageHeight)
+	for i := range world {
+		world[i] = make([]byte, p.ImageWidth)
+	}
+	for _, cell := range [][2]int{{1, 0}, {2, 1}, {0, 2}, {1, 2}, {2, 2}} {
+		world[y+cell[1]][x+cell[0]] = 255
+	}
+	return world
+}
+
+func mirror(world [][]byte, mirrorX bool, mirrorY bool) [][]byte {
+	h := len(world)
+	w := len(world[0])
+	mirrored := make([][]byte, h)
+	for y := range mirrored {
+		mirrored[y] = make([]byte, w)
+		for x := range mirrored[y] {
+			fromX, fromY := x, y
+			if mirrorX {
+				fromX = w - 1 - x
+			}
+			if mirrorY {
+				fromY = h - 1 - y
+			}
+			mirrored[y][x] = world[fromY][fromX]
+		}
+	}
+	return mirrored
+}
+
+func countAlive(world [][]byte) int {
+	count := 0
+	for _, row := range world {
+		for _, cell := range row {
+			if cell == 255 {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// TestParseTopology checks that every topology is read back from its name.
+func TestParseTopology(t *testing.T) {
+	for _, topology := range []Topology{Torus, Plane, Cylinder, KleinBottle, ProjectivePlane} {
+		parsed, err := ParseTopology(topology.String())
+		if err != nil || parsed != topology {
+			t.Errorf("%v parsed as %v, %v", topology, parsed, err)
+		}
+	}
+	if _, err := ParseTopology("sphere"); err == nil {
+		t.Errorf("sphere should not parse")
+	}
+}
+
+// TestWrap checks where the cells just beyond each edge and corner of a 4x3 board lie.
+func TestWrap(t *testing.T) {
+	type cell struct{ x, y int }
+	tests := []struct {
+		topology Topology
+		from     cell
+		to       cell
+		ok       bool
+	}{
+		{Torus, cell{-1, 1}, cell{3, 1}, true},
+		{Torus, cell{1, 3}, cell{1, 0}, true},
+		{Torus, cell{-1, -1}, cell{3, 2}, true},
+		{Plane, cell{-1, 1}, cell{}, false},
+		{Plane, cell{1, 3}, cell{}, false},
+		{Plane, cell{2, 2}, cell{2, 2}, true},
+		{Cylinder, cell{4, 1}, cell{0, 1}, true},
+		{Cylinder, cell{1, -1}, cell{}, false},
+		{KleinBottle, cell{4, 0}, cell{0, 0}, true},
+		{KleinBottle, cell{0, 3}, cell{3, 0}, true},
+		{KleinBottle, cell{1, -1}, cell{2, 2}, true},
+		{KleinBottle, cell{-1, -1}, cell{0, 2}, true},
+		{ProjectivePlane, cell{-1, 0}, cell{3, 2}, true},
+		{ProjectivePlane, cell{4, 1}, cell{0, 1}, true},
+		{ProjectivePlane, cell{0, 3}, cell{3, 0}, true},
+		{ProjectivePlane, cell{4, 3}, cell{3, 2}, true},
+		{ProjectivePlane, cell{-1, 3}, cell{0, 2}, true},
+	}
+	for _, test := range tests {
+		x, y, ok := test.topology.Wrap(test.from.x, test.from.y, 4, 3)
+		if ok != test.ok || (ok && (x != test.to.x || y != test.to.y)) {
+			t.Errorf("%v: (%v, %v) wrapped to (%v, %v), %v; expected (%v, %v), %v",
+				test.topology, test.from.x, test.from.y, x, y, ok, test.to.x, test.to.y, test.ok)
+		}
+	}
+}
+
+// TestProjectiveNeighbours checks the neighbours of corner and edge cells of a 4x3 projective
+// plane against ones worked out by hand. Crossing the left or right edge mirrors the row, and
+// crossing the top or bottom edge mirrors the column. Beyond a corner both edges are crossed,
+// so each corner cell is its own neighbour there, and the corner cell opposite it is its
+// neighbour across both of the edges it touches.
+func TestProjectiveNeighbours(t *testing.T) {
+	type cell struct{ x, y int }
+	tests := []struct {
+		from       cell
+		neighbours []cell
+	}{
+		{cell{0, 0}, []cell{{0, 0}, {3, 2}, {2, 2}, {3, 2}, {1, 0}, {3, 1}, {0, 1}, {1, 1}}},
+		{cell{3, 2}, []cell{{2, 1}, {3, 1}, {0, 1}, {2, 2}, {0, 0}, {1, 0}, {0, 0}, {3, 2}}},
+		{cell{2, 0}, []cell{{2, 2}, {1, 2}, {0, 2}, {1, 0}, {3, 0}, {1, 1}, {2, 1}, {3, 1}}},
+		{cell{0, 1}, []cell{{3, 2}, {0, 0}, {1, 0}, {3, 1}, {1, 1}, {3, 0}, {0, 2}, {1, 2}}},
+	}
+	for _, test := range tests {
+		expected := map[cell]int{}
+		for _, n := range test.neighbours {
+			expected[n]++
+		}
+		found := map[cell]int{}
+		for dy := -1; dy <= 1; dy++ {
+			for dx := -1; dx <= 1; dx++ {
+				if dx == 0 && dy == 0 {
+					continue
+				}
+				x, y, ok := ProjectivePlane.Wrap(test.from.x+dx, test.from.y+dy, 4, 3)
+				if !ok {
+					t.Errorf("(%v, %v) has no neighbour at (%v, %v)", test.from.x, test.from.y, test.from.x+dx, test.from.y+dy)
+				}
+				found[cell{x, y}]++
+			}
+		}
+		for n, count := range expected {
+			if found[n] != count {
+				t.Errorf("(%v, %v) has (%v, %v) as a neighbour %v times, expected %v", test.from.x, test.from.y, n.x, n.y, found[n], count)
+			}
+		}
+	}
+}
+
+// TestGliders sends gliders across the edges of a 16x16 board of each topology. One glider reaches
+// the side of the board first, the other the bottom. Every turn must match a reference implementation. After 64 turns, when a glider has travelled the width and the
+// height of the board, it must have come back as the topology says, or have hit a dead edge and become a block.
+func TestGliders(t *testing.T) {
+	tests := []struct {
+		topology Topology
+		//The board after 64 turns, from the board before, or nil if the glider becomes a block
+		expected func(world [][]byte) [][]byte
+	}{
+		{Torus, func(world [][]byte) [][]byte { return world }},
+		{Plane, nil},
+		{Cylinder, nil},
+		{KleinBottle, func(world [][]byte) [][]byte { return mirror(world, true, false) }},
+		{ProjectivePlane, func(world [][]byte) [][]byte { return mirror(world, true, true) }},
+	}
+	for _, test := range tests {
+		p := Params{ImageWidth: 16, ImageHeight: 16, Topology: test.topology}
+		for _, corner := range [][2]int{{8, 2}, {2, 8}} {
+			name := fmt.Sprintf("%v glider from (%v, %v)", test.topology, corner[0], corner[1])
+			start := glider(p, corner[0], corner[1])
+			world := start
+			for turn := 1; turn <= 64; turn++ {
+				expected := referenceStep(p, world)
+				world = step(p, world)
+				assertEqualWorld(t, name, world, expected)
+				if t.Failed() {
+					t.Fatalf("%v: differs from the reference on turn %v", name, turn)
+				}
+			}
+			if test.expected != nil {
+				assertEqualWorld(t, name, world, test.expected(start))
+			} else if countAlive(world) != 4 {
+				t.Errorf("%v: expected a block, got %v alive cells", name, countAlive(world))
+			} else {
+				assertEqualWorld(t, name, step(p, world), world)
+			}
+		}
+	}
+}
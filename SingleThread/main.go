@@ -3,9 +3,11 @@ package main
 import (
 	"flag"
 	"fmt"
+	"os"
 	"runtime"
 	"uk.ac.bris.cs/gameoflife/gol"
 	"uk.ac.bris.cs/gameoflife/sdl"
+	"uk.ac.bris.cs/gameoflife/tui"
 )
 
 // main is the function called when starting Game of Life with 'go run .'
@@ -22,14 +24,14 @@ func main() {
 	flag.IntVar(
 		&params.ImageWidth,
 		"w",
-		512,
-		"Specify the width of the image. Defaults to 512.")
+		0,
+		"Specify the width of the image. Defaults to the width of the input pattern, or 512.")
 
 	flag.IntVar(
 		&params.ImageHeight,
 		"h",
-		512,
-		"Specify the height of the image. Defaults to 512.")
+		0,
+		"Specify the height of the image. Defaults to the height of the input pattern, or 512.")
 
 	flag.IntVar(
 		&params.Turns,
@@ -37,15 +39,113 @@ func main() {
 		10000000000,
 		"Specify the number of turns to process. Defaults to 10000000000.")
 
+	flag.Var(
+		&params.Rule,
+		"rule",
+		"Specify the rule in B/S notation, e.g. B36/S23 for HighLife. Defaults to B3/S23, Conway's Game of Life.")
+
+	flag.Var(
+		&params.Topology,
+		"topology",
+		"Specify how the edges of the board join: torus, plane, cylinder, klein or projective. Defaults to torus.")
+
+	flag.StringVar(
+		&params.Input,
+		"input",
+		"",
+		"Specify a pattern file to load, as a .pgm, .png, .rle or .cells file. Defaults to images/<width>x<height>.pgm.")
+
+	flag.IntVar(
+		&params.OffsetX,
+		"offsetx",
+		0,
+		"Specify the column the left edge of the loaded pattern is placed on. Defaults to 0.")
+
+	flag.IntVar(
+		&params.OffsetY,
+		"offsety",
+		0,
+		"Specify the row the top edge of the loaded pattern is placed on. Defaults to 0.")
+
+	threshold := flag.Int(
+		"threshold",
+		gol.DefaultThreshold,
+		"Specify the gray level from 1 to 255 from which pixels of a .pgm, .pbm or .png input are alive. Defaults to 128.")
+
+	flag.Var(
+		&params.Format,
+		"format",
+		"Specify the format that images are saved in: pgm, png, rle or cells. Defaults to pgm.")
+
+	flag.StringVar(
+		&params.OutputDir,
+		"out",
+		"out",
+		"Specify the directory that images are saved in. Defaults to out.")
+
+	flag.StringVar(
+		&params.OutputName,
+		"name",
+		gol.DefaultOutputName,
+		"Specify the names images are saved under, filling in {width}, {height}, {turns}, {x}, {y}, {rule} and {input}. Defaults to "+gol.DefaultOutputName+".")
+
+	headless := flag.Bool(
+		"headless",
+		false,
+		"Specify whether to draw the board in the terminal instead of an SDL window, reading keys from stdin. Defaults to false.")
+	flag.BoolVar(headless, "tui", false, "Same as -headless.")
+
 	flag.Parse()
 
+	if *threshold < 1 || *threshold > 255 {
+		fmt.Println("Error: the threshold must be from 1 to 255, not", *threshold)
+		os.Exit(1)
+	}
+	params.Threshold = uint8(*threshold)
+
+	//An RLE pattern brings its own rule, unless another is asked for
+	ruleGiven := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "rule" {
+			ruleGiven = true
+		}
+	})
+	if rule, ok := gol.PatternRule(params.Input); ok && !ruleGiven {
+		params.Rule = rule
+	}
+
+	//A board size that is not given is taken from the input pattern
+	if params.ImageWidth == 0 || params.ImageHeight == 0 {
+		width, height, err := gol.BoardSize(params.Input, params.OffsetX, params.OffsetY)
+		if err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		if params.ImageWidth == 0 {
+			params.ImageWidth = width
+		}
+		if params.ImageHeight == 0 {
+			params.ImageHeight = height
+		}
+	}
+
 	fmt.Println("Threads:", params.Threads)
 	fmt.Println("Width:", params.ImageWidth)
 	fmt.Println("Height:", params.ImageHeight)
+	fmt.Println("Rule:", params.Rule)
+	fmt.Println("Topology:", params.Topology)
+	if params.Input != "" {
+		fmt.Println("Input:", params.Input, "at", params.OffsetX, params.OffsetY)
+	}
+	fmt.Println("Format:", params.Format)
 
 	keyPresses := make(chan rune, 10)
 	events := make(chan gol.Event, 1000)
 
 	gol.Run(params, events, keyPresses)
-	sdl.Start(params, events, keyPresses)
+	if *headless {
+		tui.Start(params, events, keyPresses)
+	} else {
+		sdl.Start(params, events, keyPresses)
+	}
 }
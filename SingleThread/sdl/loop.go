@@ -12,7 +12,7 @@ func Start(p gol.Params, events <-chan gol.Event, keyPresses chan<- rune) {
 sdlLoop:
 	for {
 		event := w.PollEvent()
-		if event != nil {
+		if event != nil && !w.HandleEvent(event) {
 			switch e := event.(type) {
 			case *sdl.KeyboardEvent:
 				switch e.Keysym.Sym {
@@ -35,7 +35,7 @@ sdlLoop:
 			}
 			switch e := event.(type) {
 			case gol.CellFlipped:
-				w.FlipPixel(e.Cell.X, e.Cell.Y)
+				w.ShadePixel(e.Cell.X, e.Cell.Y, e.Value)
 			case gol.TurnComplete:
 				w.RenderFrame()
 			default: